@@ -2,15 +2,29 @@ package main
 
 import (
 	"atc-simulator/internal/game/aircraft"
+	"atc-simulator/internal/game/flightplan"
+	"atc-simulator/internal/game/phraseology"
 	"atc-simulator/internal/game/simulation"
+	"atc-simulator/internal/game/trajectory"
+	"atc-simulator/internal/network/client"
+	"atc-simulator/internal/network/protocol"
+	"atc-simulator/internal/network/server"
 	"atc-simulator/internal/ui"
+	"atc-simulator/internal/voice"
 	"atc-simulator/pkg/types"
+	"cmp"
+	"errors"
+	"flag"
 	"fmt"
 	"image/color"
 	_ "image/png"
+	"maps"
 	"math"
-	"strconv"
+	"net"
+	"os"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
@@ -28,69 +42,382 @@ type Camera struct {
 type Game struct {
 	width, height int
 	camera        *Camera
-	sim           *simulation.Simulation
+	client        *client.Client
 	aircraftImage *ebiten.Image
 
 	selectedAircraftID types.AircraftID
 	commandInput       *ui.TextInput
+
+	// Outcome of the last command, shown next to the input box.
+	lastResult *protocol.CommandResult
+
+	// The last command line tried out with a leading "?". Its trajectory and
+	// conflicts are shown until it is issued with Enter or discarded with
+	// Escape.
+	probe *protocol.ProbeResult
+
+	// Whether the runway sequence ladder is shown, toggled with F8.
+	showLadders bool
+
+	// Speaks radio messages newer than lastSpokenID, nil when muted.
+	speaker      *voice.Speaker
+	lastSpokenID int
+
+	// Push-to-talk voice input, nil when off. A recognised command waits in
+	// the input box for confirmation.
+	listener *voice.Listener
+	heard    *voice.Heard
+
+	// Radio panel: the filtered log, scrolled back radioScroll messages from
+	// the newest. Exports are written in radioExportFormat.
+	radioFilter       simulation.RadioFilter
+	radioScroll       int
+	radioShown        int
+	radioNotice       string
+	radioExportFormat string
 }
 
-func NewGame(screenWidth, screenHeight int) *Game {
+const (
+	radioPanelX, radioPanelY = 5, 190
+	radioPanelWidth          = 620
+	radioLineHeight          = 22
+	radioPanelLines          = 10
+)
+
+func NewGame(screenWidth, screenHeight int, c *client.Client, speaker *voice.Speaker, listener *voice.Listener) *Game {
 	game := &Game{
-		sim:    simulation.NewSimulation(60.0),
-		camera: &Camera{0, 0, 0, 0, 1.0},
-		width:  screenWidth,
-		height: screenHeight,
+		client:       c,
+		camera:       &Camera{0, 0, 0, 0, 1.0},
+		width:        screenWidth,
+		height:       screenHeight,
+		speaker:      speaker,
+		lastSpokenID: -1,
+		listener:     listener,
+		showLadders:  true,
+
+		radioExportFormat: "txt",
 	}
 
-	game.sim.WorldToScreen = game.worldToScreen
-	game.sim.ScreenToWorld = game.screenToWorld
-
 	var err error
 	game.aircraftImage, _, err = ebitenutil.NewImageFromFile("internal/assets/images/aircraft.png")
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	game.commandInput = ui.NewTextInput(10, screenHeight-48, screenWidth/2, 30, func(cmd string) {
-		game.parseAndExecuteCommand(cmd)
-	})
+	game.commandInput = ui.NewTextInput(10, screenHeight-48, screenWidth/2, 30, game.submitCommand)
 
 	return game
 }
 
+func (g *Game) submitCommand(text string) {
+	g.heard = nil
+	g.probe = nil
+	if text == "" {
+		return
+	}
+	if trial, ok := strings.CutPrefix(text, "?"); ok {
+		g.probeCommand(strings.TrimSpace(trial))
+		return
+	}
+	if err := g.client.SendCommand(g.selectedAircraftID, text); err != nil {
+		g.lastResult = &protocol.CommandResult{Text: text, Error: err.Error()}
+		g.restoreCommand(text)
+	}
+}
+
+// handleResults shows the server's answers. A rejected command goes back into
+// the input box so it can be corrected, unless something new was typed.
+func (g *Game) handleResults() {
+	for {
+		select {
+		case result := <-g.client.Results():
+			g.lastResult = &result
+			if result.Error != "" {
+				g.restoreCommand(result.Text)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// probeCommand asks the server what the command would lead to.
+func (g *Game) probeCommand(text string) {
+	if err := g.client.SendProbe(g.selectedAircraftID, text); err != nil {
+		g.lastResult = &protocol.CommandResult{Text: text, Error: err.Error()}
+		g.restoreCommand("? " + text)
+	}
+}
+
+// handleProbes shows the server's answers to probes. A probed command goes
+// back into the input box to be issued with Enter, or discarded with Escape;
+// one that could not be probed goes back to be corrected.
+func (g *Game) handleProbes() {
+	for {
+		select {
+		case result := <-g.client.Probes():
+			if result.Probe == nil {
+				g.lastResult = &result.CommandResult
+				g.restoreCommand("? " + result.Text)
+				continue
+			}
+			g.probe = &result
+			g.restoreCommand(result.Text)
+		default:
+			if g.probe != nil && inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+				if g.commandInput.Text == g.probe.Text {
+					g.commandInput.Text = ""
+					g.commandInput.IsActive = false
+				}
+				g.probe = nil
+			}
+			return
+		}
+	}
+}
+
+func (g *Game) restoreCommand(text string) {
+	if g.commandInput.Text == "" {
+		g.commandInput.Text = text
+		g.commandInput.IsActive = true
+	}
+}
+
 func (g *Game) Update() error {
-	dt := 1.0 / float64(ebiten.TPS())
-	g.sim.Update(dt)
+	if err := g.client.Err(); err != nil {
+		return fmt.Errorf("lost connection to server: %w", err)
+	}
 
+	g.handleResults()
+	g.handleProbes()
+	g.speakRadio()
+	g.pushToTalk()
+	g.handleRadioPanel()
 	g.handleInput()
 	g.commandInput.Update()
 
 	return nil
 }
 
+// speakRadio hands new radio messages to the speaker. Messages already in the
+// log on connecting, or before a replay seek, are not spoken.
+func (g *Game) speakRadio() {
+	if g.speaker == nil {
+		return
+	}
+	g.client.View(func(st *client.State) {
+		lastID := 0
+		if len(st.RadioLog) > 0 {
+			lastID = st.RadioLog[len(st.RadioLog)-1].ID
+		}
+		if g.lastSpokenID < 0 || lastID < g.lastSpokenID {
+			g.lastSpokenID = lastID
+			return
+		}
+		for _, msg := range st.RadioLog {
+			if msg.ID > g.lastSpokenID {
+				g.speaker.Say(string(msg.Callsign), msg.Message, msg.Blocked)
+			}
+		}
+		g.lastSpokenID = lastID
+	})
+}
+
+// pushToTalk records while the right control key is held. What is recognised
+// is put in the input box to be sent with Enter or discarded with Escape.
+func (g *Game) pushToTalk() {
+	if g.listener == nil {
+		return
+	}
+
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyControlRight):
+		if err := g.listener.Start(); err != nil {
+			g.heard = &voice.Heard{Err: err}
+		}
+	case inpututil.IsKeyJustReleased(ebiten.KeyControlRight):
+		var v phraseology.Vocabulary
+		g.client.View(func(st *client.State) { v = vocabulary(st) })
+		g.listener.Stop(v)
+	}
+
+	select {
+	case heard := <-g.listener.Heard():
+		g.heard = &heard
+		if heard.Err == nil {
+			g.commandInput.Text = heard.Command
+			g.commandInput.IsActive = true
+		}
+	default:
+	}
+
+	if g.heard != nil && inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		if g.heard.Err == nil && g.commandInput.Text == g.heard.Command {
+			g.commandInput.Text = ""
+			g.commandInput.IsActive = false
+		}
+		g.heard = nil
+	}
+}
+
+// handleRadioPanel scrolls with Page Up/Down, Home and End, filters the panel
+// with F5 (selected aircraft) and F6 (urgent calls) and exports the whole log
+// with F7.
+func (g *Game) handleRadioPanel() {
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyPageUp):
+		g.radioScroll += radioPanelLines
+	case inpututil.IsKeyJustPressed(ebiten.KeyPageDown):
+		g.radioScroll -= radioPanelLines
+	case inpututil.IsKeyJustPressed(ebiten.KeyHome):
+		g.radioScroll = math.MaxInt32
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnd):
+		g.radioScroll = 0
+	case inpututil.IsKeyJustPressed(ebiten.KeyF5):
+		if g.radioFilter.Callsign == "" {
+			g.radioFilter.Callsign = g.selectedAircraftID
+		} else {
+			g.radioFilter.Callsign = ""
+		}
+		g.radioScroll = 0
+	case inpututil.IsKeyJustPressed(ebiten.KeyF6):
+		g.radioFilter.UrgentOnly = !g.radioFilter.UrgentOnly
+		g.radioScroll = 0
+	case inpututil.IsKeyJustPressed(ebiten.KeyF7):
+		g.exportRadioLog()
+	}
+
+	g.client.View(func(st *client.State) {
+		shown := len(g.radioFilter.Filter(st.RadioLog))
+		// Stay on the same messages while new ones come in.
+		if g.radioScroll > 0 && shown > g.radioShown {
+			g.radioScroll += shown - g.radioShown
+		}
+		g.radioScroll = max(0, min(g.radioScroll, shown-radioPanelLines))
+		g.radioShown = shown
+	})
+}
+
+func (g *Game) overRadioPanel(x, y int) bool {
+	return x >= radioPanelX && x < radioPanelX+radioPanelWidth &&
+		y >= radioPanelY && y < radioPanelY+(radioPanelLines+1)*radioLineHeight
+}
+
+// exportRadioLog writes every transmission heard this session to a file in
+// the working directory.
+func (g *Game) exportRadioLog() {
+	var msgs []simulation.RadioMessage
+	g.client.View(func(st *client.State) { msgs = slices.Clone(st.RadioLog) })
+
+	path := fmt.Sprintf("radio-%s.%s", time.Now().Format("20060102-150405"), g.radioExportFormat)
+	f, err := os.Create(path)
+	if err == nil {
+		err = simulation.WriteRadioLog(f, g.radioExportFormat, msgs)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		g.radioNotice = "export failed: " + err.Error()
+		return
+	}
+	g.radioNotice = fmt.Sprintf("%d transmissions written to %s", len(msgs), path)
+}
+
+// vocabulary is what the controller may name on the radio right now.
+func vocabulary(st *client.State) phraseology.Vocabulary {
+	var v phraseology.Vocabulary
+	for id := range st.Aircrafts {
+		v.Callsigns = append(v.Callsigns, string(id))
+	}
+	for name := range st.Airspace.Waypoints {
+		v.Waypoints = append(v.Waypoints, name)
+	}
+	for _, airport := range st.Airspace.Airports {
+		for name := range airport.Runways {
+			v.Runways = append(v.Runways, name)
+		}
+	}
+	for _, pos := range st.Airspace.Positions {
+		v.Stations = append(v.Stations, pos.Name)
+	}
+	slices.Sort(v.Callsigns)
+	slices.Sort(v.Waypoints)
+	slices.Sort(v.Runways)
+	slices.Sort(v.Stations)
+	return v
+}
+
 func (g *Game) Draw(screen *ebiten.Image) {
 	screen.Fill(color.RGBA{0, 0, 0, 255})
 
-	g.drawAirspace(screen)
+	g.client.View(func(st *client.State) {
+		g.drawAirspace(screen, st)
+		if tr, ok := st.Trajectories[g.selectedAircraftID]; ok {
+			g.drawTrajectory(screen, tr, color.RGBA{0, 200, 200, 160})
+		}
+		if g.probe != nil {
+			g.drawProbe(screen, st, g.probe.Probe)
+		}
 
-	for _, ac := range g.sim.Aircrafts {
-		g.drawAircraft(screen, ac)
-	}
+		for _, ac := range st.Aircrafts {
+			g.drawAircraft(screen, st, ac)
+		}
+
+		g.drawUI(screen, st)
+		g.drawStats(screen, st)
+		g.drawRadioComms(screen, st)
+		g.drawHandoffs(screen, st)
+		if g.showLadders {
+			g.drawLadders(screen, st)
+		}
+		if st.IsObserver() {
+			g.drawCommandLog(screen, st)
+		}
+		if st.Paused {
+			ebitenutil.DebugPrintAt(screen, "SIMULATION PAUSED", g.width/2-50, 10)
+		}
+		if st.Replay != nil {
+			g.drawReplayStatus(screen, st.Replay)
+		}
+	})
+}
 
-	g.drawUI(screen)
-	g.drawStats(screen)
-	g.drawRadioComms(screen, 100)
+func (g *Game) drawReplayStatus(screen *ebiten.Image, r *protocol.ReplayStatus) {
+	clock := func(tick int) string {
+		seconds := int(float64(tick-r.StartTick) / r.TickRate)
+		return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+	}
+	state := "PAUSED"
+	if r.Playing {
+		state = fmt.Sprintf("PLAYING x%.1f", r.Speed)
+	}
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("REPLAY %s / %s %s", clock(r.Tick), clock(r.EndTick), state), g.width/2-90, 26)
 }
 
-func (g *Game) drawStats(screen *ebiten.Image) {
+func (g *Game) drawStats(screen *ebiten.Image, st *client.State) {
 	statsString := fmt.Sprintf(
-		"FPS: %.2f\nScale: %.2f\nTraffic: %d\nHandoffs: %d\nMissed Handoffs: %d",
+		"FPS: %.2f\nPosition: %s %s %s\nWind: %03.0f/%.0f VIS %.0fkm\nScale: %.2f\nTraffic: %d, %.0f/h\nHandoffs: %d\nMissed Handoffs: %d\nSector Transfers: %d\nHearback: %d caught, %d missed\nDepartures: %d, %d waiting\nDeparture Delay: %s avg, %s max\nPilot Satisfaction: %.0f%%",
 		ebiten.ActualFPS(),
+		st.Name,
+		st.Role,
+		strings.Join(st.Positions, ","),
+		st.Weather.WindDirection,
+		st.Weather.WindSpeed,
+		st.Weather.VisibilityKm,
 		g.camera.Scale,
-		len(g.sim.Aircrafts),
-		g.sim.HandOffs,
-		g.sim.MissedHandoffs,
+		st.Stats.Traffic,
+		st.Stats.FlowRate,
+		st.Stats.HandOffs,
+		st.Stats.MissedHandoffs,
+		st.Stats.SectorTransfers,
+		st.Stats.ReadbacksCaught,
+		st.Stats.ReadbacksMissed,
+		st.Stats.Departures,
+		st.Stats.DepartureQueue,
+		clock(st.Stats.DepartureDelay)[1:],
+		clock(st.Stats.MaxDepartureDelay)[1:],
+		st.Stats.PilotSatisfaction,
 	)
 
 	ebitenutil.DebugPrintAt(screen, statsString, 10, 10)
@@ -118,14 +445,20 @@ func (g *Game) handleInput() {
 		hitRadiusWorld := 15.0
 
 		// Check for aircraft hit
-		for _, ac := range g.sim.Aircrafts {
-			// A simple bounding box check (adjust size based on your aircraft sprite)
-			if clickedPos.DistanceTo(ac.Position) < hitRadiusWorld {
-				g.selectedAircraftID = ac.ID
-				// log.Printf("Selected aircraft: %s", g.selectedAircraftID)
-				break
+		g.client.View(func(st *client.State) {
+			for _, ac := range st.Aircrafts {
+				// A simple bounding box check (adjust size based on your aircraft sprite)
+				if clickedPos.DistanceTo(ac.Position) < hitRadiusWorld {
+					g.selectedAircraftID = ac.ID
+					// log.Printf("Selected aircraft: %s", g.selectedAircraftID)
+					break
+				}
 			}
-		}
+		})
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyF8) {
+		g.showLadders = !g.showLadders
 	}
 
 	if ebiten.IsKeyPressed(ebiten.KeyF11) {
@@ -133,8 +466,15 @@ func (g *Game) handleInput() {
 	}
 
 	_, wy := ebiten.Wheel()
-	if wy != 0 {
-		cursorX, cursorY := ebiten.CursorPosition()
+	cursorX, cursorY := ebiten.CursorPosition()
+	if wy != 0 && g.overRadioPanel(cursorX, cursorY) {
+		// Over the radio panel the wheel scrolls it instead of zooming.
+		if wy > 0 {
+			g.radioScroll++
+		} else {
+			g.radioScroll--
+		}
+	} else if wy != 0 {
 		worldX, worldY := g.screenToWorld(float64(cursorX), float64(cursorY))
 
 		oldScale := g.camera.Scale
@@ -178,7 +518,44 @@ func (g *Game) worldToScreen(wx, wy float64) (sx, sy float64) {
 	return
 }
 
-func (g *Game) drawAircraft(screen *ebiten.Image, ac *aircraft.Aircraft) {
+// drawTrajectory draws the route the aircraft is predicted to fly, with the
+// fixes it passes and its top of descent.
+func (g *Game) drawTrajectory(screen *ebiten.Image, tr *trajectory.Trajectory, lineColor color.RGBA) {
+	for i := 1; i < len(tr.Points); i++ {
+		x1, y1 := g.worldToScreen(tr.Points[i-1].Position.X, tr.Points[i-1].Position.Y)
+		x2, y2 := g.worldToScreen(tr.Points[i].Position.X, tr.Points[i].Position.Y)
+		vector.StrokeLine(screen, float32(x1), float32(y1), float32(x2), float32(y2), float32(1*g.camera.Scale), lineColor, false)
+	}
+	for _, p := range tr.Points {
+		if p.Fix != "" {
+			x, y := g.worldToScreen(p.Position.X, p.Position.Y)
+			vector.DrawFilledCircle(screen, float32(x), float32(y), float32(3*g.camera.Scale), lineColor, false)
+		}
+	}
+	if tod := tr.TopOfDescent; tod != nil {
+		x, y := g.worldToScreen(tod.Position.X, tod.Position.Y)
+		vector.StrokeCircle(screen, float32(x), float32(y), float32(4*g.camera.Scale), float32(1*g.camera.Scale), lineColor, false)
+		ebitenutil.DebugPrintAt(screen, "TOD", int(x)+6, int(y)-6)
+	}
+}
+
+// drawProbe draws the trajectory a probed command would lead to in magenta,
+// and joins the positions of each pair of aircraft where they would lose
+// separation.
+func (g *Game) drawProbe(screen *ebiten.Image, st *client.State, probe *simulation.Probe) {
+	g.drawTrajectory(screen, probe.Trajectory, color.RGBA{255, 0, 255, 200})
+	conflictColor := color.RGBA{255, 60, 60, 255}
+	for _, c := range probe.Conflicts {
+		x1, y1 := g.worldToScreen(c.Position.X, c.Position.Y)
+		x2, y2 := g.worldToScreen(c.OtherPosition.X, c.OtherPosition.Y)
+		vector.StrokeLine(screen, float32(x1), float32(y1), float32(x2), float32(y2), float32(1*g.camera.Scale), conflictColor, false)
+		vector.StrokeCircle(screen, float32(x1), float32(y1), float32(6*g.camera.Scale), float32(2*g.camera.Scale), conflictColor, false)
+		vector.StrokeCircle(screen, float32(x2), float32(y2), float32(6*g.camera.Scale), float32(2*g.camera.Scale), conflictColor, false)
+		ebitenutil.DebugPrintAt(screen, fmt.Sprintf("%s %s", c.Other, clock(c.Time-st.GameTimeSeconds)), int(x2)+8, int(y2)-8)
+	}
+}
+
+func (g *Game) drawAircraft(screen *ebiten.Image, st *client.State, ac *protocol.AircraftView) {
 	screenX, screenY := g.worldToScreen(ac.Position.X, ac.Position.Y)
 
 	rotation := ac.Heading * math.Pi / 180.0
@@ -188,6 +565,9 @@ func (g *Game) drawAircraft(screen *ebiten.Image, ac *aircraft.Aircraft) {
 	op.GeoM.Rotate(rotation)
 	op.GeoM.Scale(g.camera.Scale, g.camera.Scale)
 	op.GeoM.Translate(screenX, screenY)
+	if !st.IsObserver() && !st.OwnsSector(ac.ControllingSector) {
+		op.ColorScale.ScaleAlpha(0.4)
+	}
 
 	screen.DrawImage(g.aircraftImage, op)
 
@@ -200,20 +580,27 @@ func (g *Game) drawAircraft(screen *ebiten.Image, ac *aircraft.Aircraft) {
 
 	currentWayPoint := "-"
 	currentWayPointDistance := 10000.0
-	if ac.DirectToWaypoint != nil {
-		currentWayPoint = ac.DirectToWaypoint.Name
-		currentWayPointDistance = ac.Position.DistanceTo(ac.DirectToWaypoint.Position)
-	} else if ac.FlightPlan != nil && ac.FlightPlan.CurrentSegmentIndex < len(ac.FlightPlan.Route) {
-		wpName := ac.FlightPlan.Route[ac.FlightPlan.CurrentSegmentIndex].WaypointName
-		wp := g.sim.Airspace.Waypoints[wpName]
-		currentWayPoint = wp.Name
-		currentWayPointDistance = ac.Position.DistanceTo(wp.Position)
+	if ac.DirectTo != nil {
+		currentWayPoint = ac.DirectTo.Name
+		currentWayPointDistance = ac.Position.DistanceTo(ac.DirectTo.Position)
+	} else if seg := ac.FlightPlan.CurrentSegment(); seg != nil && seg.Type == flightplan.SegmentTypeLanding {
+		currentWayPoint = seg.AirportID + "/" + seg.RunwayName
+		if airport, ok := st.Airspace.Airports[seg.AirportID]; ok {
+			if rwy, ok := airport.Runways[seg.RunwayName]; ok {
+				currentWayPointDistance = ac.Position.DistanceTo(rwy.Threshold)
+			}
+		}
+	} else if seg != nil {
+		if wp, ok := st.Airspace.Waypoints[seg.WaypointName]; ok {
+			currentWayPoint = wp.Name
+			currentWayPointDistance = ac.Position.DistanceTo(wp.Position)
+		}
 	}
 
 	tagText := ""
 	if currentWayPointDistance < 1000.0 {
 		tagText = fmt.Sprintf(
-			"%s\nALT:%.0f (%.0f)\nSPD:%.0f (%.0f)\nHDG:%.0f (%.0f)\nWP: %s (%.0f)\nSTS: %s",
+			"%s\nALT:%.0f (%.0f)\nSPD:%.0f (%.0f)\nHDG:%.0f (%.0f)\nWP: %s (%.0f)\nSTS: %s\nSEC: %s%s%s",
 			ac.ID,
 			ac.Altitude,
 			ac.TargetAltitude,
@@ -223,11 +610,14 @@ func (g *Game) drawAircraft(screen *ebiten.Image, ac *aircraft.Aircraft) {
 			ac.TargetHeading,
 			currentWayPoint,
 			currentWayPointDistance,
-			aircraft.StateStringMap[ac.State],
+			ac.State,
+			sectorTag(st, ac),
+			procedureTag(ac),
+			routeTag(ac),
 		)
 	} else {
 		tagText = fmt.Sprintf(
-			"%s\nALT:%.0f (%.0f)\nSPD:%.0f (%.0f)\nHDG:%.0f (%.0f)\nWP: %s\nSTS: %s",
+			"%s\nALT:%.0f (%.0f)\nSPD:%.0f (%.0f)\nHDG:%.0f (%.0f)\nWP: %s\nSTS: %s\nSEC: %s%s%s",
 			ac.ID,
 			ac.Altitude,
 			ac.TargetAltitude,
@@ -236,7 +626,10 @@ func (g *Game) drawAircraft(screen *ebiten.Image, ac *aircraft.Aircraft) {
 			ac.Heading,
 			ac.TargetHeading,
 			currentWayPoint,
-			aircraft.StateStringMap[ac.State],
+			ac.State,
+			sectorTag(st, ac),
+			procedureTag(ac),
+			routeTag(ac),
 		)
 	}
 
@@ -261,6 +654,31 @@ func (g *Game) drawAircraft(screen *ebiten.Image, ac *aircraft.Aircraft) {
 		)
 	}
 
+	if ac.Emergency != "" {
+		ebitenutil.DebugPrintAt(screen, "MAYDAY "+ac.Emergency, int(screenX)+10, int(screenY)-36)
+		vector.StrokeCircle(
+			screen,
+			float32(screenX),
+			float32(screenY),
+			float32(14*g.camera.Scale),
+			float32(2*g.camera.Scale),
+			color.RGBA{255, 80, 0, 255},
+			false,
+		)
+	}
+
+	if ac.ConstraintWarning != "" {
+		vector.StrokeCircle(
+			screen,
+			float32(screenX),
+			float32(screenY),
+			float32(18*g.camera.Scale),
+			float32(1*g.camera.Scale),
+			color.RGBA{255, 200, 0, 255},
+			false,
+		)
+	}
+
 	// Conflict highlight (also relative to screenX, screenY)
 	if ac.IsConflicting {
 		conflictingRadius := 10.0 * g.camera.Scale
@@ -275,16 +693,230 @@ func (g *Game) drawAircraft(screen *ebiten.Image, ac *aircraft.Aircraft) {
 	}
 }
 
-func (g *Game) drawAirspace(screen *ebiten.Image) {
+// sectorTag describes which sector works the aircraft and any handoff in progress.
+func sectorTag(st *client.State, ac *protocol.AircraftView) string {
+	tag := ac.ControllingSector
+	if ac.Frequency != "" {
+		tag += " " + ac.Frequency
+	}
+	if offer, ok := st.HandoffOffer(ac.ID); ok {
+		tag += fmt.Sprintf(" > %s %s", offer.ToSector, simulation.HandoffStateStringMap[offer.State])
+	}
+	return tag
+}
+
+// procedureTag shows the SID or STAR being flown and the constraints at the
+// next fix, e.g. "\nPRC: APIPO1A NOSEL 10000-/250 VIA".
+func procedureTag(ac *protocol.AircraftView) string {
+	seg := ac.FlightPlan.CurrentSegment()
+	if seg == nil || seg.Procedure == "" {
+		return ""
+	}
+	tag := "\nPRC: " + seg.Procedure + " " + seg.WaypointName
+	if c := seg.Altitude.String(); c != "" {
+		tag += " " + c
+	}
+	if c := seg.Speed.String(); c != "" {
+		tag += "/" + c
+	}
+	if ac.Via {
+		tag += " VIA"
+	}
+	if ac.ConstraintWarning != "" {
+		tag += "\nCSTR: " + ac.ConstraintWarning
+	}
+	return tag
+}
+
+// routeTag shows the rest of the route, e.g. "\nRTE: CIPKA FILKA KBLR/RWY27",
+// led by VEC while the aircraft is on vectors.
+func routeTag(ac *protocol.AircraftView) string {
+	fp := ac.FlightPlan
+	if fp == nil {
+		return ""
+	}
+	var fixes []string
+	if ac.Vectored {
+		fixes = append(fixes, "VEC")
+	}
+	for _, seg := range fp.Route[min(max(fp.CurrentSegmentIndex, 0), len(fp.Route)):] {
+		if seg.Type == flightplan.SegmentTypeLanding {
+			fixes = append(fixes, seg.AirportID+"/"+seg.RunwayName)
+		} else {
+			fixes = append(fixes, seg.WaypointName)
+		}
+	}
+	if len(fixes) == 0 {
+		return ""
+	}
+	return "\nRTE: " + strings.Join(fixes, " ")
+}
+
+func (g *Game) drawHandoffs(screen *ebiten.Image, st *client.State) {
+	screenWidth := screen.Bounds().Dx()
+	lineHeight := 16
+
+	lines := []string{"HANDOFFS"}
+	for _, offer := range st.HandoffOffers {
+		lines = append(lines, fmt.Sprintf("%s %s>%s %s", offer.Callsign, offer.FromSector, offer.ToSector, simulation.HandoffStateStringMap[offer.State]))
+	}
+	for _, po := range st.PointOuts {
+		status := "PENDING"
+		if po.Acknowledged {
+			status = "ACK"
+		}
+		lines = append(lines, fmt.Sprintf("PO %s %s>%s %s", po.Callsign, po.FromSector, po.ToSector, status))
+	}
+	for _, req := range st.Requests {
+		if st.IsObserver() || st.OwnsSector(req.Sector) {
+			lines = append(lines, fmt.Sprintf("REQ %s %s %s %.0fs", req.Callsign, simulation.RequestKindStringMap[req.Kind], req.Clearance, st.GameTimeSeconds-req.RaisedAt))
+		}
+	}
+	slices.Sort(lines[1:])
+
+	for i, line := range lines {
+		ebitenutil.DebugPrintAt(screen, line, screenWidth-260, 10+i*lineHeight)
+	}
+}
+
+const (
+	ladderMinutes     = 20 // shown ahead of now
+	ladderColumnWidth = 220
+	ladderAxisOffset  = 80 // departures on the left of the axis, arrivals on the right
+)
+
+// drawLadders draws the landing and takeoff sequence of each runway as a
+// ladder, now at the bottom and ladderMinutes ahead at the top. Arrivals are
+// shown on the right at their scheduled landing time with the time they are
+// to lose and how; a line on the left leads back to the time they would land
+// at if left alone. Departures are shown on the left at their takeoff slot,
+// with their wake category.
+func (g *Game) drawLadders(screen *ebiten.Image, st *client.State) {
+	screenWidth, screenHeight := screen.Bounds().Dx(), screen.Bounds().Dy()
+	top, bottom := 260, screenHeight-120
+	scale := float64(bottom-top) / (ladderMinutes * 60)
+	y := func(t float64) float32 {
+		return float32(bottom) - float32((t-st.GameTimeSeconds)*scale)
+	}
+	axisColor := color.RGBA{160, 160, 160, 255}
+	delayColor := color.RGBA{255, 200, 0, 255}
+
+	arrivals := make(map[[2]string]simulation.ArrivalSequence)
+	for _, seq := range st.Arrivals {
+		arrivals[[2]string{seq.AirportID, seq.Runway}] = seq
+	}
+	departures := make(map[[2]string][]simulation.Departure)
+	for _, dep := range st.Departures {
+		key := [2]string{dep.AirportID, dep.Runway}
+		departures[key] = append(departures[key], dep)
+	}
+	runways := slices.Collect(maps.Keys(arrivals))
+	for key := range departures {
+		if _, ok := arrivals[key]; !ok {
+			runways = append(runways, key)
+		}
+	}
+	slices.SortFunc(runways, func(a, b [2]string) int {
+		return cmp.Or(cmp.Compare(a[0], b[0]), cmp.Compare(a[1], b[1]))
+	})
+
+	for i, key := range runways {
+		axis := float32(screenWidth - (len(runways)-i)*ladderColumnWidth + ladderAxisOffset)
+		ebitenutil.DebugPrintAt(screen, key[0]+" "+key[1], int(axis)-30, top-20)
+		vector.StrokeLine(screen, axis, float32(top), axis, float32(bottom), 1, axisColor, false)
+		for m := 0; m <= ladderMinutes; m++ {
+			tick := y(st.GameTimeSeconds + float64(m*60))
+			length := float32(3)
+			if m%5 == 0 {
+				length = 6
+				ebitenutil.DebugPrintAt(screen, fmt.Sprintf("%d", m), int(axis)-24, int(tick)-8)
+			}
+			vector.StrokeLine(screen, axis-length, tick, axis, tick, 1, axisColor, false)
+		}
+
+		for _, dep := range departures[key] {
+			if dep.SlotTime > st.GameTimeSeconds+ladderMinutes*60 {
+				break
+			}
+			slot := y(dep.SlotTime)
+			label := string(dep.Callsign) + " " + aircraft.WakeCategoryStringMap[dep.Wake]
+			vector.StrokeLine(screen, axis-6, slot, axis, slot, 2, color.White, false)
+			ebitenutil.DebugPrintAt(screen, label, int(axis)-8-6*len(label), int(slot)-8)
+		}
+		for _, a := range arrivals[key].Arrivals {
+			if a.ScheduledTime > st.GameTimeSeconds+ladderMinutes*60 {
+				break
+			}
+			scheduled := y(a.ScheduledTime)
+			label := string(a.Callsign)
+			if a.Advice != simulation.AdviceNone {
+				estimated := y(a.EstimatedTime)
+				vector.StrokeLine(screen, axis-10, estimated, axis, scheduled, 1, delayColor, false)
+				label += " " + adviceTag(a)
+			}
+			vector.StrokeLine(screen, axis, scheduled, axis+6, scheduled, 2, color.White, false)
+			ebitenutil.DebugPrintAt(screen, label, int(axis)+8, int(scheduled)-8)
+		}
+	}
+}
+
+// adviceTag is how an arrival is to lose its delay, e.g. "-01:30 S210",
+// "-04:00 VEC" or "-09:00 HOLD 3".
+func adviceTag(a simulation.Arrival) string {
+	delay := "-" + clock(a.Delay)[1:]
+	switch a.Advice {
+	case simulation.AdviceSpeed:
+		return fmt.Sprintf("%s S%.0f", delay, a.Speed)
+	case simulation.AdviceVectors:
+		return delay + " VEC"
+	case simulation.AdviceHold:
+		return fmt.Sprintf("%s HOLD %d", delay, a.Laps)
+	}
+	return delay
+}
+
+// drawCommandLog shows observers every command issued in the session.
+func (g *Game) drawCommandLog(screen *ebiten.Image, st *client.State) {
+	screenWidth, screenHeight := screen.Bounds().Dx(), screen.Bounds().Dy()
+	lineHeight := 16
+
+	for i, entry := range st.CommandLog {
+		line := fmt.Sprintf("%s %s: %s", entry.Controller, strings.Join(entry.Positions, ","), entry.Text)
+		if entry.Error != "" {
+			line += " (" + entry.Error + ")"
+		}
+		y := screenHeight - 60 - (len(st.CommandLog)-i)*lineHeight
+		ebitenutil.DebugPrintAt(screen, line, screenWidth/2+10, y)
+	}
+}
+
+func (g *Game) drawAirspace(screen *ebiten.Image, st *client.State) {
+	// Airways under everything else, named at the middle of their first leg.
+	for _, aw := range st.Airspace.Airways {
+		for i := 1; i < len(aw.Fixes); i++ {
+			from, okFrom := st.Airspace.Waypoints[aw.Fixes[i-1]]
+			to, okTo := st.Airspace.Waypoints[aw.Fixes[i]]
+			if !okFrom || !okTo {
+				continue
+			}
+			x1, y1 := g.worldToScreen(from.Position.X, from.Position.Y)
+			x2, y2 := g.worldToScreen(to.Position.X, to.Position.Y)
+			vector.StrokeLine(screen, float32(x1), float32(y1), float32(x2), float32(y2), float32(1*g.camera.Scale), color.RGBA{60, 90, 120, 255}, false)
+			if i == 1 {
+				ebitenutil.DebugPrintAt(screen, aw.Name, int((x1+x2)/2), int((y1+y2)/2))
+			}
+		}
+	}
+
 	// Convert Waypoint positions
-	for _, wp := range g.sim.Airspace.Waypoints {
+	for _, wp := range st.Airspace.Waypoints {
 		screenX, screenY := g.worldToScreen(wp.Position.X, wp.Position.Y)
 		vector.DrawFilledCircle(screen, float32(screenX), float32(screenY), float32(3*g.camera.Scale), color.RGBA{0, 255, 255, 255}, false)
 		ebitenutil.DebugPrintAt(screen, wp.Name, int(screenX)+5, int(screenY)+5)
 	}
 
 	// Draw Airports and Runways
-	for _, airport := range g.sim.Airspace.Airports {
+	for _, airport := range st.Airspace.Airports {
 		airportScreenX, airportScreenY := g.worldToScreen(airport.Position.X, airport.Position.Y)
 		vector.DrawFilledCircle(screen, float32(airportScreenX), float32(airportScreenY), float32(5*g.camera.Scale), color.RGBA{255, 255, 0, 255}, false)
 		ebitenutil.DebugPrintAt(screen, airport.ID, int(airportScreenX)+8, int(airportScreenY)+8)
@@ -316,8 +948,19 @@ func (g *Game) drawAirspace(screen *ebiten.Image) {
 	}
 
 	// Convert Sector bounds
-	sector := g.sim.Airspace.Sectors["SECTOR1"]
-	if sector != nil && len(sector.Bounds) >= 2 {
+	for _, name := range st.Airspace.SectorNames() {
+		sector := st.Airspace.Sectors[name]
+		if len(sector.Bounds) < 2 {
+			continue
+		}
+
+		sectorColor := color.RGBA{100, 100, 100, 255}
+		if st.OwnsSector(sector.Name) {
+			sectorColor = color.RGBA{0, 160, 80, 255}
+		} else if st.IsSectorStaffed(sector.Name) {
+			sectorColor = color.RGBA{200, 160, 0, 255}
+		}
+
 		for i := 0; i < len(sector.Bounds); i++ {
 			p1World := sector.Bounds[i]
 			p2World := sector.Bounds[(i+1)%len(sector.Bounds)]
@@ -330,41 +973,107 @@ func (g *Game) drawAirspace(screen *ebiten.Image) {
 				float32(p2ScreenX),
 				float32(p2ScreenY),
 				float32(1*g.camera.Scale),
-				color.RGBA{100, 100, 100, 255},
+				sectorColor,
 				false,
 			)
 		}
+
+		labelX, labelY := g.worldToScreen(sector.Bounds[len(sector.Bounds)-1].X, sector.Bounds[len(sector.Bounds)-1].Y)
+		controller := sector.Controller
+		if name, ok := st.Controllers[sector.Controller]; ok {
+			controller += " " + name
+		}
+		ebitenutil.DebugPrintAt(screen, fmt.Sprintf("%s %s (%s)", sector.Name, sector.Frequency, controller), int(labelX)+5, int(labelY)-20)
+	}
+}
+
+// drawCommandFeedback shows the outcome of the last command or probe to the
+// right of the input box and underlines the column a rejected command went
+// wrong at.
+func (g *Game) drawCommandFeedback(screen *ebiten.Image, st *client.State, x, y, width, height int) {
+	if p := g.probe; p != nil {
+		ebitenutil.DebugPrintAt(screen, probeTag(p.Probe, st.GameTimeSeconds), x+width+10, y+(height-16)/2)
+		return
+	}
+
+	r := g.lastResult
+	if r == nil {
+		return
+	}
+
+	const glyphWidth = 6
+	if r.Error == "" {
+		ebitenutil.DebugPrintAt(screen, "OK: "+r.Text, x+width+10, y+(height-16)/2)
+		return
+	}
+
+	vector.DrawFilledRect(screen, float32(x+width+4), float32(y), 4, float32(height), color.RGBA{255, 60, 60, 255}, false)
+	ebitenutil.DebugPrintAt(screen, "REJECTED: "+r.Error, x+width+10, y+(height-16)/2)
+	if r.ErrorColumn > 0 && g.commandInput.Text == r.Text {
+		vector.DrawFilledRect(screen, float32(x+5+(r.ErrorColumn-1)*glyphWidth), float32(y+height-7), glyphWidth, 2, color.RGBA{255, 60, 60, 255}, false)
+	}
+}
+
+func (g *Game) drawVoiceInput(screen *ebiten.Image, x, y int) {
+	switch {
+	case g.listener != nil && g.listener.Recording():
+		vector.DrawFilledCircle(screen, float32(x+4), float32(y+8), 4, color.RGBA{255, 60, 60, 255}, false)
+		ebitenutil.DebugPrintAt(screen, "TRANSMITTING", x+12, y)
+	case g.heard == nil:
+	case g.heard.Err != nil && g.heard.Speech == "":
+		ebitenutil.DebugPrintAt(screen, "VOICE: "+g.heard.Err.Error(), x, y)
+	case g.heard.Err != nil:
+		ebitenutil.DebugPrintAt(screen, fmt.Sprintf("HEARD: %s (%v)", g.heard.Speech, g.heard.Err), x, y)
+	default:
+		ebitenutil.DebugPrintAt(screen, fmt.Sprintf("HEARD: %s -- ENTER to send, ESC to discard", g.heard.Speech), x, y)
 	}
 }
 
-func (g *Game) drawUI(screen *ebiten.Image) {
+func (g *Game) drawUI(screen *ebiten.Image, st *client.State) {
 	screenWidth, screenHeight := screen.Bounds().Dx(), screen.Bounds().Dy()
 	lineHeight := 22
 	lines := 1
 
 	g.commandInput.Draw(screen, 10, screenHeight-40, screenWidth/2, 30)
+	g.drawCommandFeedback(screen, st, 10, screenHeight-40, screenWidth/2, 30)
+	g.drawVoiceInput(screen, 10+screenWidth/2+10, screenHeight-62)
 
 	selectedAcText := ""
 	if g.selectedAircraftID != "" {
-		if ac, ok := g.sim.Aircrafts[g.selectedAircraftID]; ok {
+		if ac, ok := st.Aircrafts[g.selectedAircraftID]; ok {
 			selectedAcText = fmt.Sprintf(
-				"AC: %s\nORIGIN: %s\nDEST: %s",
+				"AC: %s %s/%s\nORIGIN: %s\nDEST: %s\nSATISFACTION: %.0f%%",
 				string(ac.FlightPlan.Callsign),
+				cmp.Or(ac.Type, "ZZZZ"),
+				ac.Wake,
 				ac.FlightPlan.OriginAirportID,
 				ac.FlightPlan.DestinationAirportID,
+				ac.Satisfaction,
 			)
-			lines += 3
+			lines += 4
 
 			filedPlan := []string{}
 			for _, seg := range ac.FlightPlan.Route {
 				wpName := seg.WaypointName
-				if ac.FlightPlan.CurrentSegmentIndex < len(ac.FlightPlan.Route) && ac.DirectToWaypoint != nil && ac.DirectToWaypoint.Name == seg.WaypointName {
+				if ac.FlightPlan.CurrentSegmentIndex < len(ac.FlightPlan.Route) && ac.DirectTo != nil && ac.DirectTo.Name == seg.WaypointName {
 					wpName = "* " + wpName
 				}
 				filedPlan = append(filedPlan, wpName)
 			}
 			selectedAcText += "\nFP: " + strings.Join(filedPlan, " -- ")
 			lines++
+
+			if tr, ok := st.Trajectories[ac.ID]; ok {
+				selectedAcText += "\nETA: " + etaTag(tr, st.GameTimeSeconds)
+				lines++
+			}
+			if a, runway, ok := st.Arrival(ac.ID); ok {
+				selectedAcText += fmt.Sprintf("\nAMAN: #%d %s STA %s", a.Sequence, runway, clock(a.ScheduledTime-st.GameTimeSeconds))
+				if a.Advice != simulation.AdviceNone {
+					selectedAcText += " " + adviceTag(a)
+				}
+				lines++
+			}
 		}
 	}
 
@@ -373,176 +1082,223 @@ func (g *Game) drawUI(screen *ebiten.Image) {
 	}
 }
 
-func (g *Game) drawRadioComms(screen *ebiten.Image, yOffset int) {
-	radioLogX := 5
-	radioLogY := yOffset
-	lineHeight := 22
+// etaTag lists the predicted times to the fixes ahead and the top of descent,
+// e.g. "NIKAT +02:10 -- TOD +03:40 -- RUMIT +05:05".
+func etaTag(tr *trajectory.Trajectory, now float64) string {
+	var etas []string
+	todAdded := false
+	for _, p := range tr.Points {
+		if tod := tr.TopOfDescent; tod != nil && !todAdded && tod.Time <= p.Time {
+			etas = append(etas, "TOD "+clock(tod.Time-now))
+			todAdded = true
+		}
+		if p.Fix != "" {
+			etas = append(etas, p.Fix+" "+clock(p.Time-now))
+		}
+	}
+	if len(etas) == 0 {
+		return "-"
+	}
+	return strings.Join(etas, " -- ")
+}
+
+// probeTag sums up a probe: the conflicts it runs into, e.g. "PROBE AAL101
+// A 24000: DAL106 +03:20 3.2NM 800FT", or that there are none.
+func probeTag(probe *simulation.Probe, now float64) string {
+	tag := fmt.Sprintf("PROBE %s %s:", probe.Callsign, probe.Instructions)
+	if len(probe.Conflicts) == 0 {
+		tag += " no conflicts"
+	}
+	for _, c := range probe.Conflicts {
+		tag += fmt.Sprintf(" %s %s %.1fNM %.0fFT", c.Other, clock(c.Time-now), c.Distance(), math.Abs(c.Altitude-c.OtherAltitude))
+	}
+	return tag + " - ENTER to issue, ESC to discard"
+}
+
+// clock formats a time ahead in seconds as "+mm:ss".
+func clock(seconds float64) string {
+	s := max(int(seconds), 0)
+	return fmt.Sprintf("+%02d:%02d", s/60, s%60)
+}
+
+func (g *Game) drawRadioComms(screen *ebiten.Image, st *client.State) {
+	msgs := g.radioFilter.Filter(st.RadioLog)
+	end := max(0, len(msgs)-g.radioScroll)
+	start := max(0, end-radioPanelLines)
 
-	numMessagesToShow := 10
-	startIndex := 0
-	if len(g.sim.RadioLog) > numMessagesToShow {
-		startIndex = len(g.sim.RadioLog) - numMessagesToShow
+	header := fmt.Sprintf("RADIO %d-%d/%d", start+1, end, len(msgs))
+	if start == end {
+		header = fmt.Sprintf("RADIO 0/%d", len(msgs))
 	}
+	if g.radioFilter.Callsign != "" {
+		header += " [" + string(g.radioFilter.Callsign) + "]"
+	}
+	if g.radioFilter.UrgentOnly {
+		header += " [URGENT]"
+	}
+	header += "  PgUp/PgDn F5 callsign F6 urgent F7 export"
+	if g.radioNotice != "" {
+		header += "  " + g.radioNotice
+	}
+	ebitenutil.DebugPrintAt(screen, header, radioPanelX, radioPanelY)
 
-	for i := startIndex; i < len(g.sim.RadioLog); i++ {
-		msg := g.sim.RadioLog[i]
+	for i, msg := range msgs[start:end] {
 		callSign := msg.Callsign
 		if msg.IsUrgent {
 			callSign = "+" + msg.Callsign
 		}
-		displayLine := fmt.Sprintf("[%s] %s: %s", msg.Timestamp.Format("15:04:05"), callSign, msg.Message)
-		ebitenutil.DebugPrintAt(screen, displayLine, radioLogX, radioLogY+(i-startIndex)*lineHeight)
-	}
-}
-
-func (g *Game) handleHandoffCommand(callsign types.AircraftID) {
-	if ac, ok := g.sim.Aircrafts[callsign]; ok {
-		if g.sim.ClearHandoff(ac.ID) {
-			g.sim.AddRadioMessage(ac.ID, "Roger, good day.", false)
-			log.Printf("ATC issued HANDOFF to %s", callsign)
-		} else {
-			g.sim.AddRadioMessage("ATC", fmt.Sprintf("Unable to clear %s for handoff: not ready or already handed off.", callsign), true)
+		displayLine := fmt.Sprintf("[%s] %s %s: %s", msg.Timestamp.Format("15:04:05"), msg.Frequency, callSign, msg.Message)
+		if msg.Blocked {
+			displayLine = fmt.Sprintf("[%s] %s %s: -- BLOCKED --", msg.Timestamp.Format("15:04:05"), msg.Frequency, callSign)
 		}
-	} else {
-		log.Printf("Aircraft %s not found for HANDOFF command.", callsign)
+		ebitenutil.DebugPrintAt(screen, displayLine, radioPanelX, radioPanelY+(i+1)*radioLineHeight)
 	}
 }
 
-func (g *Game) handleLandingCommand(callsign types.AircraftID, runwayName string) {
-	if ac, ok := g.sim.Aircrafts[callsign]; ok {
-		if g.sim.ClearLanding(ac.ID, runwayName) {
-			g.sim.AddRadioMessage(ac.ID, fmt.Sprintf("Cleared to land runway %s, roger.", runwayName), false) // Aircraft acknowledges
-			log.Printf("ATC issued LANDING clearance to %s for %s", callsign, runwayName)
-		} else {
-			g.sim.AddRadioMessage("ATC", fmt.Sprintf("Unable to clear %s for landing on %s: runway invalid or aircraft not ready.", callsign, runwayName), true)
+func main() {
+	serverAddr := flag.String("server", "", "address of the simulation server, runs a local one when empty")
+	positions := flag.String("positions", "BLR_S_APP", "comma separated controller positions to staff")
+	name := flag.String("name", "", "controller name shown to other players")
+	role := flag.String("role", string(protocol.RoleController), "controller, spectator or instructor")
+	seed := flag.Uint64("seed", 0, "random seed for a local simulation, random when 0")
+	record := flag.String("record", "", "record the local session to this file")
+	replay := flag.String("replay", "", "watch a recorded session instead of playing")
+	snapshot := flag.String("snapshot", "", "resume a local session from a saved snapshot")
+	speech := flag.String("tts", "espeak-ng", "offline text-to-speech command for radio audio, empty to mute")
+	static := flag.Bool("static", true, "make spoken radio sound like it came over VHF")
+	sttModel := flag.String("stt-model", "", "whisper.cpp model for push-to-talk voice commands, off when empty")
+	stt := flag.String("stt", "whisper-cli", "offline speech recogniser for voice commands")
+	recorder := flag.String("ptt-record", voice.DefaultRecorder, "command recording the microphone to the WAV file appended to it")
+	radioExport := flag.String("radio-export", "txt", "format F7 exports the radio log in: "+strings.Join(simulation.RadioLogFormats, ", "))
+	flag.Parse()
+
+	ebiten.SetWindowSize(1280, 720)
+	ebiten.SetWindowTitle("ATC Simulator")
+	// ebiten.SetVsyncEnabled(true)
+	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeDisabled)
+
+	addr := *serverAddr
+	var localServer *server.Server
+	if addr == "" {
+		var err error
+		localServer, err = newLocalServer(1280, 720, *seed, *snapshot, *record, *replay)
+		if err != nil {
+			log.Fatal(err)
 		}
-	} else {
-		log.Printf("Aircraft %s not found for LANDING command.", callsign)
+		if addr, err = startLocalServer(localServer); err != nil {
+			log.Fatal(err)
+		}
+		defer localServer.Shutdown()
 	}
-}
 
-func (g *Game) parseAndExecuteCommand(cmd string) {
-	parts := strings.Fields(cmd) // Split by whitespace
-	if len(parts) < 2 {
-		log.Printf("Invalid command format: %s. Expected: [<Callsign>] <Command> <Value>", cmd)
-		return
+	hello := protocol.Hello{Name: *name, Role: protocol.Role(*role)}
+	if *replay != "" {
+		hello.Role = protocol.RoleSpectator
 	}
-
-	var aircraftID types.AircraftID
-	var commandType, valueStr string
-
-	if len(parts) == 2 && g.selectedAircraftID == "" {
-		log.Printf("No Aircraft selected")
-		return
-	} else if len(parts) == 2 {
-		aircraftID = g.selectedAircraftID
-		commandType = strings.ToUpper(parts[0])
-		valueStr = parts[1]
-	} else {
-		aircraftID = types.AircraftID(strings.ToUpper(parts[0]))
-		commandType = strings.ToUpper(parts[1])
-		valueStr = parts[2]
+	if hello.Role == protocol.RoleController {
+		hello.Positions = strings.Split(*positions, ",")
 	}
 
-	_, exists := g.sim.Aircrafts[aircraftID]
-	if !exists {
-		log.Printf("Aircraft %s not found.", aircraftID)
-		return
+	c, err := client.Dial(addr, hello)
+	if err != nil {
+		log.Fatal(err)
 	}
+	defer c.Close()
 
-	switch commandType {
-	// case "SEL", "SELECT":
-	// 	if valueStr != "" {
-	// 		g.handleSelectCommand(types.AircraftID(valueStr))
-	// 	} else {
-	// 		log.Printf("Invalid SELECT command. Usage: SEL <callsign>")
-	// 	}
-
-	case "H", "HEADING":
-		heading, err := strconv.ParseFloat(valueStr, 64)
-		if err != nil || heading < 0 || heading >= 360 {
-			log.Printf("Invalid heading value: %s. Must be 0-359.", valueStr)
-			return
-		}
-		if err = g.sim.IssueHeading(aircraftID, heading); err != nil {
-			log.Printf("Failed to Issue H %.0f to %s", heading, aircraftID)
+	var speaker *voice.Speaker
+	if *speech != "" {
+		if engine, err := voice.NewEspeak(*speech); err != nil {
+			log.Warnf("radio audio disabled: %v", err)
 		} else {
-			log.Printf("Issued H %.0f to %s", heading, aircraftID)
+			speaker = voice.NewSpeaker(engine, *static)
+			defer speaker.Close()
 		}
-	case "A", "ALT", "ALTITUDE":
-		var altitude float64
-		var err error
+	}
 
-		if strings.HasPrefix(valueStr, "FL") {
-			if val, ok := strings.CutPrefix(valueStr, "FL"); ok {
-				altitude, err = strconv.ParseFloat(val, 64)
-				altitude = altitude * 100.0 // convert flight level to feets
-			} else {
-				altitude, err = strconv.ParseFloat(valueStr, 64)
-			}
+	var listener *voice.Listener
+	if *sttModel != "" {
+		recognizer, err := voice.NewWhisper(*stt, *sttModel)
+		if err == nil {
+			listener, err = voice.NewListener(*recorder, recognizer)
+		}
+		if err != nil {
+			log.Warnf("voice commands disabled: %v", err)
 		} else {
-			altitude, err = strconv.ParseFloat(valueStr, 64)
+			defer listener.Close()
 		}
+	}
 
-		if err != nil || altitude < 0 { // Add more realistic altitude bounds
-			log.Printf("Invalid altitude value: %s. Must be positive.", valueStr)
-			return
-		}
-		if err = g.sim.IssueAltitude(aircraftID, altitude); err != nil {
-			log.Printf("Failed to Issued A %.0f to %s", altitude, aircraftID)
-		} else {
-			log.Printf("Issued A %.0f to %s", altitude, aircraftID)
+	if !slices.Contains(simulation.RadioLogFormats, *radioExport) {
+		log.Fatalf("unknown radio log format %q", *radioExport)
+	}
+
+	game := NewGame(1280, 720, c, speaker, listener)
+	game.radioExportFormat = *radioExport
+
+	if err := ebiten.RunGame(game); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// newLocalServer creates the server for a single player session: a fresh
+// simulation, one resumed from a snapshot, or a replay of a recording.
+func newLocalServer(worldWidth, worldHeight float64, seed uint64, snapshotPath, recordPath, replayPath string) (*server.Server, error) {
+	if replayPath != "" {
+		f, err := os.Open(replayPath)
+		if err != nil {
+			return nil, err
 		}
-	case "S", "SPD", "SPEED":
-		speed, err := strconv.ParseFloat(valueStr, 64)
-		if err != nil || speed < 0 { // Add realistic speed bounds
-			log.Printf("Invalid speed value: %s. Must be positive.", valueStr)
-			return
+		defer f.Close()
+
+		rec, err := simulation.LoadRecording(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", replayPath, err)
 		}
-		if err = g.sim.IssueSpeed(aircraftID, speed); err != nil {
-			log.Printf("Failed to Issued S %.0f to %s", speed, aircraftID)
-		} else {
-			log.Printf("Issued S %.0f to %s", speed, aircraftID)
+		replay, err := simulation.NewReplay(rec)
+		if err != nil {
+			return nil, err
 		}
-	case "D", "DIRECT":
-		waypointName := strings.ToUpper(valueStr)
-		wp, ok := g.sim.Airspace.Waypoints[waypointName]
-		if !ok {
-			log.Printf("Waypoint %s not found.", waypointName)
-			return
+		return server.NewReplay(replay), nil
+	}
+
+	var sim *simulation.Simulation
+	if snapshotPath != "" {
+		var err error
+		if sim, err = server.LoadSnapshotFile(snapshotPath); err != nil {
+			return nil, err
 		}
-		if err := g.sim.IssueDirectTo(aircraftID, wp); err != nil {
-			log.Printf("Failed to Issued D %s to %s", waypointName, aircraftID)
-		} else {
-			log.Printf("Issued D %s to %s", waypointName, aircraftID)
+	} else {
+		if seed == 0 {
+			seed = uint64(time.Now().UnixNano())
 		}
+		log.Printf("Starting local simulation with seed %d", seed)
+		sim = simulation.NewSimulation(60.0, worldWidth, worldHeight, seed)
+	}
 
-	case "HO", "HANDOFF":
-		if aircraftID != "" {
-			g.handleHandoffCommand(aircraftID)
-		} else {
-			log.Printf("Invalid HANDOFF command. Usage: HO <callsign>")
+	if recordPath != "" {
+		f, err := os.Create(recordPath)
+		if err != nil {
+			return nil, err
 		}
-	case "LAND", "LANDING":
-		if aircraftID != "" && strings.HasPrefix(valueStr, "RWY") {
-			g.handleLandingCommand(aircraftID, valueStr)
-		} else {
-			log.Printf("Invalid LAND command. Usage: LAND <callsign> RWY<number>")
+		if err := sim.StartRecording(f); err != nil {
+			f.Close()
+			return nil, err
 		}
-	default:
-		log.Printf("Unknown command type: %s", commandType)
 	}
+	return server.New(sim, sim.TickRate), nil
 }
 
-func main() {
-	ebiten.SetWindowSize(1280, 720)
-	ebiten.SetWindowTitle("ATC Simulator")
-	// ebiten.SetVsyncEnabled(true)
-	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeDisabled)
-
-	game := NewGame(1280, 720)
-
-	if err := ebiten.RunGame(game); err != nil {
-		log.Fatal(err)
+// startLocalServer runs srv on a loopback port and returns its address.
+func startLocalServer(srv *server.Server) (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
 	}
+
+	go func() {
+		if err := srv.Serve(l); err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Errorf("local server stopped: %v", err)
+		}
+	}()
+	return l.Addr().String(), nil
 }
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"atc-simulator/internal/game/simulation"
+	"atc-simulator/internal/network/server"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/labstack/gommon/log"
+)
+
+func main() {
+	addr := flag.String("addr", ":7400", "address to listen on")
+	tickRate := flag.Float64("tick", 60.0, "simulation ticks per second")
+	worldWidth := flag.Float64("width", 1280, "world width in pixels")
+	worldHeight := flag.Float64("height", 720, "world height in pixels")
+	seed := flag.Uint64("seed", 0, "random seed, random when 0")
+	pilotLatency := flag.Float64("pilot-latency", 2.5, "seconds pilots take to read back a clearance")
+	readbackErrors := flag.Float64("readback-errors", 0, "fraction of clearances pilots read back wrong, 0 to 1")
+	traffic := flag.String("traffic", "", "traffic generator configuration, JSON, the built-in day when empty")
+	record := flag.String("record", "", "record the session to this file")
+	replay := flag.String("replay", "", "play back a recorded session to spectators")
+	snapshot := flag.String("snapshot", "", "resume from a saved snapshot")
+	snapshotDir := flag.String("snapshots", "snapshots", "directory SAVE and LOAD use")
+	flag.Parse()
+
+	var srv *server.Server
+	if *replay != "" {
+		srv = newReplayServer(*replay)
+	} else {
+		var sim *simulation.Simulation
+		if *snapshot != "" {
+			var err error
+			if sim, err = server.LoadSnapshotFile(*snapshot); err != nil {
+				log.Fatal(err)
+			}
+			log.Printf("Resuming %s at %.0fs of game time", *snapshot, sim.GameTimeSeconds)
+		} else {
+			if *seed == 0 {
+				*seed = uint64(time.Now().UnixNano())
+			}
+			log.Printf("Starting simulation with seed %d", *seed)
+			sim = simulation.NewSimulation(*tickRate, *worldWidth, *worldHeight, *seed)
+			if err := sim.SetPilotLatency(*pilotLatency); err != nil {
+				log.Fatal(err)
+			}
+			if *readbackErrors > 0 {
+				if err := sim.SetReadbackErrors(*readbackErrors, sim.HearbackWindowSeconds); err != nil {
+					log.Fatal(err)
+				}
+			}
+			if *traffic != "" {
+				if err := loadTraffic(sim, *traffic); err != nil {
+					log.Fatal(err)
+				}
+			}
+		}
+
+		if *record != "" {
+			f, err := os.Create(*record)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := sim.StartRecording(f); err != nil {
+				log.Fatal(err)
+			}
+		}
+		srv = server.New(sim, sim.TickRate)
+	}
+	srv.SnapshotDir = *snapshotDir
+
+	go func() {
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt)
+		<-interrupt
+		srv.Shutdown()
+		os.Exit(0)
+	}()
+
+	if err := srv.ListenAndServe(*addr); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func newReplayServer(path string) *server.Server {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	rec, err := simulation.LoadRecording(f)
+	if err != nil {
+		log.Fatalf("failed to load %s: %v", path, err)
+	}
+	replay, err := simulation.NewReplay(rec)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Replaying %s: seed %d, %d events", path, rec.Header.Seed, len(rec.Events))
+	return server.NewReplay(replay)
+}
+
+func loadTraffic(sim *simulation.Simulation, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cfg, err := simulation.ReadTrafficConfig(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return sim.SetTraffic(cfg)
+}
@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"log"
 	"math"
-	"time"
 )
 
 type AircraftState int
@@ -34,6 +33,28 @@ var StateStringMap = map[AircraftState]string{
 	READY_FOR_HANDOFF: "READY_FOR_HANDOFF",
 }
 
+// WakeCategory is the ICAO wake turbulence category of the aircraft type. The
+// zero value is medium, which most airliners are.
+type WakeCategory int
+
+const (
+	WakeMedium WakeCategory = iota
+	WakeLight
+	WakeHeavy
+	WakeSuper
+)
+
+var WakeCategoryStringMap = map[WakeCategory]string{
+	WakeMedium: "M",
+	WakeLight:  "L",
+	WakeHeavy:  "H",
+	WakeSuper:  "J",
+}
+
+// FixCaptureRadius is how close in pixels an aircraft comes to a fix of its
+// route before it counts as passed and the next is flown to.
+const FixCaptureRadius = 30
+
 type Aircraft struct {
 	ID        types.AircraftID
 	Position  types.Vec2
@@ -49,26 +70,51 @@ type Aircraft struct {
 	ClearedForHandoff bool
 	ClearedForLanding bool
 
+	// AltitudeAssigned and SpeedAssigned are set while the aircraft holds an
+	// altitude or speed given by the controller. Otherwise it flies the
+	// profile of its route, see flyProfile.
+	AltitudeAssigned bool
+	SpeedAssigned    bool
+
+	// Via is set while cleared to climb or descend via a SID or STAR: the
+	// aircraft then meets the procedure's constraints by itself, going no
+	// further than ViaAltitude.
+	Via         bool
+	ViaAltitude float64
+
+	// Vectored is set while the aircraft flies a heading it was given rather
+	// than its route, until it is sent direct or told to resume own
+	// navigation.
+	Vectored bool
+
 	State AircraftState
+	Type  string       `json:",omitempty"` // ICAO type designator, e.g. "A320"
+	Wake  WakeCategory `json:",omitempty"`
 
 	MaxTurnRateDegPerSec        float64
 	MaxClimbRateFPM             float64
 	MaxDescentRateFPM           float64
 	AccelerationRateKnotsPerSec float64
 
-	IsConflicting bool
-	FlightPlan    *flightplan.FlightPlan
-	LandingRunway *airspace.Runway
-	Airspace      *airspace.Airspace
+	IsConflicting     bool
+	Emergency         string
+	ControllingSector string
+	Frequency         string // the one frequency the pilot is listening to
+	FlightPlan        *flightplan.FlightPlan
+	LandingRunway     *airspace.Runway   `json:"-"` // rewired from the airspace on restore
+	Airspace          *airspace.Airspace `json:"-"`
 
-	AddRadioMessageFunc func(callsign types.AircraftID, message string, isUrgent bool)
+	AddRadioMessageFunc func(callsign types.AircraftID, message string, isUrgent bool) `json:"-"`
 
-	SpawnTime               time.Time
-	LastRadioTime           time.Time
-	MessageDebounceTime     time.Duration
-	PreviousAltitudeRequest bool
-	PreviousSpeedRequest    bool
+	AgeSeconds              float64 // simulated seconds since spawn
+	LastRadioSeconds        float64 // AgeSeconds of the last transmission
 	PreviousWaypointReached string
+
+	// Satisfaction of the crew with the service they get, 0 to 100. Ignored
+	// and refused requests lower it.
+	Satisfaction float64
+
+	ghost bool // flown ahead for a prediction, see Ghost
 }
 
 func NewAircraft(id types.AircraftID, pos types.Vec2, heading, speed, altitude float64, state AircraftState, flightPlan *flightplan.FlightPlan, asp *airspace.Airspace, addRadioMessageFunc func(types.AircraftID, string, bool)) *Aircraft {
@@ -91,9 +137,7 @@ func NewAircraft(id types.AircraftID, pos types.Vec2, heading, speed, altitude f
 		AccelerationRateKnotsPerSec: 10.0 / 60.0,
 		Airspace:                    asp,
 		FlightPlan:                  flightPlan,
-		SpawnTime:                   time.Now(),
-		LastRadioTime:               time.Now(),
-		MessageDebounceTime:         5 * time.Second,
+		Satisfaction:                100,
 		AddRadioMessageFunc:         addRadioMessageFunc,
 	}
 
@@ -105,6 +149,7 @@ func NewAircraft(id types.AircraftID, pos types.Vec2, heading, speed, altitude f
 }
 
 func (ac *Aircraft) Update(dt float64) {
+	ac.AgeSeconds += dt
 	rateScale := dt / 60.0
 	if ac.Altitude < ac.TargetAltitude {
 		rate := math.Min(ac.MaxClimbRateFPM, (ac.TargetAltitude-ac.Altitude)/(rateScale))
@@ -133,12 +178,12 @@ func (ac *Aircraft) Update(dt float64) {
 	}
 
 	if ac.DirectToWaypoint != nil {
-		if ac.Position.DistanceTo(ac.DirectToWaypoint.Position) < 30 {
+		if ac.Position.DistanceTo(ac.DirectToWaypoint.Position) < FixCaptureRadius {
 			ac.DirectToWaypoint = nil
 
 			ac.FlightPlan.CurrentSegmentIndex++
 			if ac.FlightPlan.CurrentSegmentIndex >= len(ac.FlightPlan.Route) {
-				log.Printf("%s completed its flight plan in this sector.", ac.ID)
+				ac.Logf("%s completed its flight plan in this sector.", ac.ID)
 				ac.State = READY_FOR_HANDOFF
 			}
 			ac.TargetHeading = ac.Heading
@@ -147,7 +192,7 @@ func (ac *Aircraft) Update(dt float64) {
 		}
 	}
 
-	if ac.DirectToWaypoint == nil && ac.FlightPlan != nil && ac.FlightPlan.CurrentSegmentIndex < len(ac.FlightPlan.Route) {
+	if ac.DirectToWaypoint == nil && !ac.Vectored && ac.FlightPlan != nil && ac.FlightPlan.CurrentSegmentIndex < len(ac.FlightPlan.Route) {
 		nextSegment := ac.FlightPlan.Route[ac.FlightPlan.CurrentSegmentIndex]
 
 		switch nextSegment.Type {
@@ -156,17 +201,9 @@ func (ac *Aircraft) Update(dt float64) {
 				nextWaypoint, ok := ac.GetWaypoint(nextSegment.WaypointName)
 				if ok {
 					ac.SetDirectTo(nextWaypoint)
-
-					if ac.TargetAltitude == ac.Altitude {
-						ac.SetAltitude(nextSegment.TargetAltitude)
-					}
-					if ac.TargetSpeed == ac.Speed {
-						ac.SetSpeed(nextSegment.TargetSpeed)
-					}
-
-					log.Printf("%s now directing to %s (Segment %d)", ac.ID, nextSegment.WaypointName, ac.FlightPlan.CurrentSegmentIndex)
+					ac.Logf("%s now directing to %s (Segment %d)", ac.ID, nextSegment.WaypointName, ac.FlightPlan.CurrentSegmentIndex)
 				} else {
-					log.Printf("ERROR: Waypoint %s not found for %s's flight plan segment %d", nextSegment.WaypointName, ac.ID, ac.FlightPlan.CurrentSegmentIndex)
+					ac.Logf("ERROR: Waypoint %s not found for %s's flight plan segment %d", nextSegment.WaypointName, ac.ID, ac.FlightPlan.CurrentSegmentIndex)
 				}
 			}
 		case flightplan.SegmentTypeLanding:
@@ -175,25 +212,29 @@ func (ac *Aircraft) Update(dt float64) {
 					if runway, ok := airport.Runways[nextSegment.RunwayName]; ok {
 						ac.LandingRunway = runway
 						ac.SetDirectToRunway(runway.Name, runway.Threshold)
-						if ac.TargetAltitude == ac.Altitude {
+						if !ac.AltitudeAssigned {
 							ac.SetAltitude(nextSegment.TargetAltitude)
 						}
-						if ac.TargetSpeed == ac.Speed {
+						if !ac.SpeedAssigned {
 							ac.SetSpeed(nextSegment.TargetSpeed)
 						}
 						ac.State = APPROACH
-						log.Printf("%s cleared for approach to %s at %s. Directing to threshold %v", ac.ID, runway.Name, airport.ID, runway.Threshold)
+						ac.Logf("%s cleared for approach to %s at %s. Directing to threshold %v", ac.ID, runway.Name, airport.ID, runway.Threshold)
 					} else {
-						log.Printf("ERROR: Runway %s not found for airport %s in %s's flight plan", nextSegment.RunwayName, nextSegment.AirportID, ac.ID)
+						ac.Logf("ERROR: Runway %s not found for airport %s in %s's flight plan", nextSegment.RunwayName, nextSegment.AirportID, ac.ID)
 					}
 				} else {
-					log.Printf("ERROR: Airport %s not found for %s's landing segment", nextSegment.AirportID, ac.ID)
+					ac.Logf("ERROR: Airport %s not found for %s's landing segment", nextSegment.AirportID, ac.ID)
 				}
 				break
 			}
 		}
 	}
 
+	if ac.State != APPROACH && ac.State != LANDED {
+		ac.flyProfile()
+	}
+
 	if ac.State == APPROACH && ac.LandingRunway != nil {
 		ac.TargetHeading = ac.Position.HeadingTo(ac.LandingRunway.Threshold)
 		distanceToThreshold := ac.Position.DistanceTo(ac.LandingRunway.Threshold)
@@ -220,7 +261,7 @@ func (ac *Aircraft) Update(dt float64) {
 			ac.Position = ac.LandingRunway.Threshold
 			ac.DirectToWaypoint = nil
 			ac.FlightPlan.CurrentSegmentIndex = len(ac.FlightPlan.Route)
-			log.Printf("%s HAS LANDED at %s!", ac.ID, ac.LandingRunway.Name)
+			ac.Logf("%s HAS LANDED at %s!", ac.ID, ac.LandingRunway.Name)
 			ac.AddRadioMessageFunc(ac.ID, fmt.Sprintf("Touch down, %s", ac.LandingRunway.Name), false)
 		}
 	}
@@ -259,74 +300,44 @@ func (ac *Aircraft) Update(dt float64) {
 	ac.Position.X += pixelsPerSec * math.Sin(radians) * dt
 	ac.Position.Y -= pixelsPerSec * math.Cos(radians) * dt
 
-	// Radio communication logic
-	if time.Since(ac.LastRadioTime) > ac.MessageDebounceTime {
-		if !ac.ClearedForLanding {
-			if ac.TargetAltitude > ac.Altitude+100 && !ac.PreviousAltitudeRequest {
-				ac.AddRadioMessageFunc(ac.ID, fmt.Sprintf("Requesting higher to FL%.0f", ac.TargetAltitude/100), false)
-				ac.PreviousAltitudeRequest = true
-				ac.LastRadioTime = time.Now()
-			} else if ac.TargetAltitude < ac.Altitude-100 && !ac.PreviousAltitudeRequest {
-				ac.AddRadioMessageFunc(ac.ID, fmt.Sprintf("Requesting lower to FL%.0f", ac.TargetAltitude/100), false)
-				ac.PreviousAltitudeRequest = true
-				ac.LastRadioTime = time.Now()
-			} else if math.Abs(ac.TargetAltitude-ac.Altitude) < 100 {
-				ac.PreviousAltitudeRequest = false
-			}
-		} else {
-			ac.PreviousAltitudeRequest = false
-		}
-
-		// Altitude requests
-		if ac.TargetAltitude > ac.Altitude+100 && !ac.PreviousAltitudeRequest { // Target higher
-			ac.AddRadioMessageFunc(ac.ID, fmt.Sprintf("Requesting higher to FL%.0f", ac.TargetAltitude/100), false)
-			ac.PreviousAltitudeRequest = true
-			ac.LastRadioTime = time.Now()
-		} else if ac.TargetAltitude < ac.Altitude-100 && !ac.PreviousAltitudeRequest { // Target lower
-			ac.AddRadioMessageFunc(ac.ID, fmt.Sprintf("Requesting lower to FL%.0f", ac.TargetAltitude/100), false)
-			ac.PreviousAltitudeRequest = true
-			ac.LastRadioTime = time.Now()
-		} else if math.Abs(ac.TargetAltitude-ac.Altitude) < 100 { // Reached target altitude
-			ac.PreviousAltitudeRequest = false // Reset request state
-		}
-
-		// Speed requests (similar logic)
-		if math.Abs(ac.TargetSpeed-ac.Speed) > 50 && !ac.PreviousSpeedRequest {
-			// ac.AddRadioMessageFunc(ac.ID, fmt.Sprintf("Requesting speed %.0f knots", ac.TargetSpeed), false)
-			// ac.PreviousSpeedRequest = true
-			// ac.LastRadioTime = time.Now()
-		} else if math.Abs(ac.TargetSpeed-ac.Speed) < 10 {
-			ac.PreviousSpeedRequest = false
-		}
-	}
-
-	if ac.State == APPROACH && !ac.ClearedForLanding {
-		distanceToThreshold := ac.Position.DistanceTo(ac.LandingRunway.Threshold)
-		headingDiff := math.Abs(ac.Heading - ac.LandingRunway.Heading)
-		if headingDiff > 180 {
-			headingDiff = 360 - headingDiff
-		}
-
-		if distanceToThreshold > 500 && ac.Altitude < 5000 && headingDiff < 30 {
-			ac.AddRadioMessageFunc(ac.ID, fmt.Sprintf("Requesting clearance to land runway %s.", ac.LandingRunway.Name), false)
-			ac.LastRadioTime = time.Now()
-		}
-	}
-
 	// Waypoint Reached Report (should be less frequent, maybe not debounced by general message time)
 	// This typically happens when DirectToWaypoint is reset.
 	// So this logic will move into the DirectToWaypoint reached block.
 	if ac.DirectToWaypoint == nil && ac.FlightPlan != nil && ac.FlightPlan.CurrentSegmentIndex > 0 &&
 		ac.FlightPlan.CurrentSegmentIndex-1 < len(ac.FlightPlan.Route) { // Ensure index is valid for prev segment
 		prevWpName := ac.FlightPlan.Route[ac.FlightPlan.CurrentSegmentIndex-1].WaypointName
-		if ac.PreviousWaypointReached != prevWpName {
+		if prevWpName != "" && ac.PreviousWaypointReached != prevWpName {
 			ac.AddRadioMessageFunc(ac.ID, fmt.Sprintf("Approaching %s", prevWpName), false) // Report reaching, or approaching next
 			ac.PreviousWaypointReached = prevWpName
-			ac.LastRadioTime = time.Now() // Debounce here too
+			ac.LastRadioSeconds = ac.AgeSeconds // Debounce here too
 		}
 	}
 }
 
+// Ghost returns a copy of the aircraft to fly ahead of it for a prediction.
+// It shares no state the aircraft changes, and neither logs nor transmits.
+func (ac *Aircraft) Ghost() *Aircraft {
+	g := *ac
+	g.ghost = true
+	g.AddRadioMessageFunc = func(types.AircraftID, string, bool) {}
+	if ac.FlightPlan != nil {
+		g.FlightPlan = ac.FlightPlan.Clone()
+	}
+	if ac.DirectToWaypoint != nil {
+		wp := *ac.DirectToWaypoint
+		g.DirectToWaypoint = &wp
+	}
+	return &g
+}
+
+// Logf logs what the aircraft does, unless it is a ghost flown for a
+// prediction.
+func (ac *Aircraft) Logf(format string, args ...any) {
+	if !ac.ghost {
+		log.Printf(format, args...)
+	}
+}
+
 func (ac *Aircraft) SetHeading(h float64) {
 	ac.TargetHeading = math.Mod(h+360, 360)
 	ac.DirectToWaypoint = nil
@@ -358,6 +369,18 @@ func (ac *Aircraft) SetDirectToRunway(name string, pos types.Vec2) {
 	}
 }
 
+// ProjectPosition returns where the aircraft will be after the given number of
+// seconds if it keeps its current heading and speed.
+func (ac *Aircraft) ProjectPosition(seconds float64) types.Vec2 {
+	radians := ac.Heading * math.Pi / 180.0
+	pixelsPerSec := ac.Speed / 3600.0 * types.NM_TO_PIXEL
+
+	return types.NewVec2(
+		ac.Position.X+pixelsPerSec*math.Sin(radians)*seconds,
+		ac.Position.Y-pixelsPerSec*math.Cos(radians)*seconds,
+	)
+}
+
 func (ac *Aircraft) GetWaypoint(wpName string) (wp *types.Waypoint, ok bool) {
 	wp, ok = ac.Airspace.Waypoints[wpName]
 	return
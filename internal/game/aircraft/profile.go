@@ -0,0 +1,183 @@
+package aircraft
+
+import (
+	"atc-simulator/internal/game/flightplan"
+	"atc-simulator/pkg/types"
+	"fmt"
+	"math"
+)
+
+// planningFactor is the share of its climb, descent and deceleration rates an
+// aircraft plans with, keeping the rest in hand.
+const planningFactor = 0.8
+
+// upcomingFix is a fix ahead on the route and the distance in pixels flown
+// until it is passed, through the fixes before it.
+type upcomingFix struct {
+	segment  *flightplan.FlightPlanSegment
+	distance float64
+}
+
+// upcomingFixes returns the fixes left to fly up to the approach.
+func (ac *Aircraft) upcomingFixes() []upcomingFix {
+	fp := ac.FlightPlan
+	if fp == nil {
+		return nil
+	}
+	var fixes []upcomingFix
+	pos, distance := ac.Position, 0.0
+	for i := max(fp.CurrentSegmentIndex, 0); i < len(fp.Route); i++ {
+		seg := &fp.Route[i]
+		if seg.Type != flightplan.SegmentTypeWaypoint {
+			break
+		}
+		wp, ok := ac.GetWaypoint(seg.WaypointName)
+		if !ok {
+			break
+		}
+		distance += pos.DistanceTo(wp.Position)
+		pos = wp.Position
+		fixes = append(fixes, upcomingFix{segment: seg, distance: max(distance-FixCaptureRadius, 0)})
+	}
+	return fixes
+}
+
+// pixelsPerMinute is the ground covered at speed, never taken as less than
+// 100 kt so that a slow aircraft still plans ahead.
+func pixelsPerMinute(speed float64) float64 {
+	return max(speed, 100) / 60 * types.NM_TO_PIXEL
+}
+
+// flyProfile sets the altitude and speed the aircraft flies along its route
+// when the controller has not assigned them: those filed for the leg, bent to
+// meet the constraints of the fixes ahead. Descents and decelerations that
+// constraints call for start as late as they can.
+func (ac *Aircraft) flyProfile() {
+	current := ac.FlightPlan.CurrentSegment()
+	if current == nil || current.Type != flightplan.SegmentTypeWaypoint {
+		ac.Via = false
+		return
+	}
+	if current.Procedure == "" {
+		ac.Via = false
+	}
+	fixes := ac.upcomingFixes()
+
+	if !ac.AltitudeAssigned {
+		base := current.TargetAltitude
+		if ac.Via {
+			base = ac.ViaAltitude
+		}
+		if target := ac.profileAltitude(base, fixes); math.Abs(target-ac.TargetAltitude) > 1 {
+			ac.SetAltitude(target)
+		}
+	}
+	if !ac.SpeedAssigned {
+		if target := ac.profileSpeed(current.TargetSpeed, fixes); math.Abs(target-ac.TargetSpeed) > 0.5 {
+			ac.SetSpeed(target)
+		}
+	}
+}
+
+// profileAltitude returns the altitude to fly now towards base. Nearer fixes
+// come first: a later constraint that cannot be met together with them waits
+// until they are passed.
+func (ac *Aircraft) profileAltitude(base float64, fixes []upcomingFix) float64 {
+	lower, upper := 0.0, math.Inf(1)
+	for _, f := range fixes {
+		lo, hi := f.segment.Altitude.Bounds()
+		if max(lower, lo) > min(upper, hi) {
+			break
+		}
+		lower, upper = max(lower, lo), min(upper, hi)
+	}
+	target := min(max(base, lower), upper)
+
+	// A descent the constraints call for, rather than one to a lower filed
+	// level, starts at the top of descent: the last point from which every
+	// constraint ahead can still be met.
+	if target < ac.Altitude && (ac.Via || base >= upper) {
+		feetPerPixel := -ac.MaxDescentRateFPM * planningFactor / pixelsPerMinute(ac.Speed)
+		highest := ac.Altitude
+		for _, f := range fixes {
+			_, hi := f.segment.Altitude.Bounds()
+			highest = min(highest, hi+f.distance*feetPerPixel)
+		}
+		target = max(target, highest)
+	}
+
+	if ac.Via {
+		if ac.ViaAltitude >= ac.Altitude {
+			target = min(target, ac.ViaAltitude)
+		} else {
+			target = max(target, ac.ViaAltitude)
+		}
+	}
+	return target
+}
+
+// profileSpeed returns the speed to fly now towards base. The speed limit of
+// the fix last passed on a procedure holds until the next, and the aircraft
+// slows down in time for the limits ahead.
+func (ac *Aircraft) profileSpeed(base float64, fixes []upcomingFix) float64 {
+	fp := ac.FlightPlan
+	lower, upper := 0.0, math.Inf(1)
+	if i := fp.CurrentSegmentIndex; i > 0 && fp.Route[i-1].Procedure != "" && fp.Route[i-1].Procedure == fp.Route[i].Procedure {
+		_, upper = fp.Route[i-1].Speed.Bounds()
+	}
+	if len(fixes) > 0 {
+		lower, _ = fixes[0].segment.Speed.Bounds()
+	}
+
+	rate := ac.AccelerationRateKnotsPerSec * planningFactor
+	for _, f := range fixes {
+		_, hi := f.segment.Speed.Bounds()
+		nm := f.distance / types.NM_TO_PIXEL
+		upper = min(upper, math.Sqrt(hi*hi+2*rate*3600*nm))
+	}
+	return min(max(base, lower), upper)
+}
+
+// ConstraintWarning predicts, at the rates the aircraft climbs, descends and
+// changes speed, whether it will miss a constraint ahead, and names the first
+// one it misses, such as "NIKAT ALT 12000+/14000-", or returns "".
+func (ac *Aircraft) ConstraintWarning() string {
+	if ac.State == APPROACH || ac.State == LANDED {
+		return ""
+	}
+	for _, f := range ac.upcomingFixes() {
+		minutes := f.distance / pixelsPerMinute(ac.Speed)
+		seg := f.segment
+
+		if seg.Altitude.Restriction != flightplan.Unrestricted {
+			lo, hi := seg.Altitude.Bounds()
+			aim := min(max(ac.Altitude, lo), hi)
+			switch {
+			case ac.AltitudeAssigned:
+				aim = ac.TargetAltitude
+			case ac.Via && ac.ViaAltitude >= ac.Altitude:
+				aim = min(aim, ac.ViaAltitude)
+			case ac.Via:
+				aim = max(aim, ac.ViaAltitude)
+			}
+			change := min(max(aim-ac.Altitude, ac.MaxDescentRateFPM*minutes), ac.MaxClimbRateFPM*minutes)
+			if !seg.Altitude.Meets(ac.Altitude+change, 100) {
+				return fmt.Sprintf("%s ALT %s", seg.WaypointName, seg.Altitude)
+			}
+		}
+
+		if seg.Speed.Restriction != flightplan.Unrestricted {
+			lo, hi := seg.Speed.Bounds()
+			aim := min(max(ac.Speed, lo), hi)
+			if ac.SpeedAssigned {
+				aim = ac.TargetSpeed
+			}
+			step := ac.AccelerationRateKnotsPerSec * minutes * 60
+			change := min(max(aim-ac.Speed, -step), step)
+			if !seg.Speed.Meets(ac.Speed+change, 5) {
+				return fmt.Sprintf("%s SPD %s", seg.WaypointName, seg.Speed)
+			}
+		}
+	}
+	return ""
+}
@@ -15,6 +15,9 @@ type Airport struct {
 	Name     string
 	Position types.Vec2
 	Runways  map[string]*Runway
+
+	// SIDs and STARs by name.
+	Procedures map[string]*Procedure `json:",omitempty"`
 }
 
 func (ap *Airspace) AddAirport(airportID, name string, pos types.Vec2, runways []Runway) {
@@ -31,3 +34,13 @@ func (ap *Airspace) AddAirport(airportID, name string, pos types.Vec2, runways [
 	}
 	ap.Airports[airportID] = airport
 }
+
+// HasRunway reports whether the airspace has the airport and it the runway.
+func (ap *Airspace) HasRunway(airportID, runway string) bool {
+	airport, ok := ap.Airports[airportID]
+	if !ok {
+		return false
+	}
+	_, ok = airport.Runways[runway]
+	return ok
+}
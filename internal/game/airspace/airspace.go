@@ -2,55 +2,132 @@ package airspace
 
 import (
 	"atc-simulator/pkg/types"
-
-	"github.com/hajimehoshi/ebiten/v2"
+	"maps"
+	"slices"
 )
 
 type Sector struct {
 	Name        string
+	Controller  string // ID of the ControllerPosition owning this sector
+	Frequency   string
 	Bounds      []types.Vec2
 	MinAltitude float64
 	MaxAltitude float64
 }
 
+type ControllerPosition struct {
+	ID   string
+	Name string
+}
+
 type Airspace struct {
+	Width  float64
+	Height float64
+
 	Waypoints map[string]*types.Waypoint
 	Sectors   map[string]*Sector
 	Airports  map[string]*Airport
+	Airways   map[string]*Airway
+	Positions map[string]*ControllerPosition
 
 	ExitWaypoints  []string
 	EntryWaypoints []string
 }
 
-func NewAirspace() *Airspace {
+func NewAirspace(width, height float64) *Airspace {
 	ap := &Airspace{
+		Width:  width,
+		Height: height,
+
 		Waypoints: make(map[string]*types.Waypoint),
 		Sectors:   make(map[string]*Sector),
 		Airports:  make(map[string]*Airport),
+		Airways:   make(map[string]*Airway),
+		Positions: make(map[string]*ControllerPosition),
 
 		EntryWaypoints: []string{"APIPO", "BISKET", "EMETI", "FILKA"},
 		ExitWaypoints:  []string{"APIPO", "BISKET", "EMETI", "FILKA"},
 	}
 
-	screenWidth, screenHeight := ebiten.WindowSize()
+	ap.Waypoints["APIPO"] = &types.Waypoint{Name: "APIPO", Position: types.NewVec2(width*0.1, height*0.14)}
+	ap.Waypoints["BISKET"] = &types.Waypoint{Name: "BISKET", Position: types.NewVec2(width*0.64, height*0.23)}
+	ap.Waypoints["CIPKA"] = &types.Waypoint{Name: "CIPKA", Position: types.NewVec2(width*0.37, height*0.54)}
+	ap.Waypoints["EMETI"] = &types.Waypoint{Name: "EMETI", Position: types.NewVec2(width*0.25, height*0.90)}
+	ap.Waypoints["FILKA"] = &types.Waypoint{Name: "FILKA", Position: types.NewVec2(width*0.67, height*0.65)}
+
+	// CIPKA is where the airways cross. A33 is flown northbound only.
+	ap.AddAirway(Airway{Name: "W12", Fixes: []string{"APIPO", "CIPKA", "FILKA"}, MinAltitude: 8000, MaxAltitude: 30000})
+	ap.AddAirway(Airway{Name: "W20", Fixes: []string{"BISKET", "CIPKA", "EMETI"}, MinAltitude: 8000, MaxAltitude: 30000})
+	ap.AddAirway(Airway{Name: "A33", Fixes: []string{"FILKA", "BISKET"}, OneWay: true, MinAltitude: 10000, MaxAltitude: 40000})
 
-	ap.Waypoints["APIPO"] = &types.Waypoint{Name: "APIPO", Position: types.NewVec2(float64(screenWidth)*0.1, float64(screenHeight)*0.14)}
-	ap.Waypoints["BISKET"] = &types.Waypoint{Name: "BISKET", Position: types.NewVec2(float64(screenWidth)*0.64, float64(screenHeight)*0.23)}
-	ap.Waypoints["CIPKA"] = &types.Waypoint{Name: "CIPKA", Position: types.NewVec2(float64(screenWidth)*0.37, float64(screenHeight)*0.54)}
-	ap.Waypoints["EMETI"] = &types.Waypoint{Name: "EMETI", Position: types.NewVec2(float64(screenWidth)*0.25, float64(screenHeight)*0.90)}
-	ap.Waypoints["FILKA"] = &types.Waypoint{Name: "FILKA", Position: types.NewVec2(float64(screenWidth)*0.67, float64(screenHeight)*0.65)}
+	ap.Positions["BLR_N_CTR"] = &ControllerPosition{ID: "BLR_N_CTR", Name: "Bengaluru North Control"}
+	ap.Positions["BLR_S_APP"] = &ControllerPosition{ID: "BLR_S_APP", Name: "Bengaluru South Approach"}
 
-	// Example: Define a simple rectangular sector
-	ap.Sectors["SECTOR1"] = &Sector{
-		Name: "SECTOR1",
+	// The airspace is split horizontally, the airport sits in the southern sector.
+	boundaryY := height * 0.45
+	ap.Sectors["NORTH"] = &Sector{
+		Name:       "NORTH",
+		Controller: "BLR_N_CTR",
+		Frequency:  "127.350",
 		Bounds: []types.Vec2{
 			types.NewVec2(0, 0),
-			types.NewVec2(1024, 0),
-			types.NewVec2(1024, 768),
-			types.NewVec2(0, 768),
+			types.NewVec2(width, 0),
+			types.NewVec2(width, boundaryY),
+			types.NewVec2(0, boundaryY),
+		},
+		MinAltitude: 0,
+		MaxAltitude: 40000,
+	}
+	ap.Sectors["SOUTH"] = &Sector{
+		Name:       "SOUTH",
+		Controller: "BLR_S_APP",
+		Frequency:  "119.500",
+		Bounds: []types.Vec2{
+			types.NewVec2(0, boundaryY),
+			types.NewVec2(width, boundaryY),
+			types.NewVec2(width, height),
+			types.NewVec2(0, height),
 		},
 		MinAltitude: 0,
 		MaxAltitude: 40000,
 	}
 	return ap
 }
+
+// Contains reports whether pos at the given altitude lies inside the sector.
+func (sec *Sector) Contains(pos types.Vec2, altitude float64) bool {
+	if altitude < sec.MinAltitude || altitude > sec.MaxAltitude {
+		return false
+	}
+
+	inside := false
+	for i, j := 0, len(sec.Bounds)-1; i < len(sec.Bounds); j, i = i, i+1 {
+		pi, pj := sec.Bounds[i], sec.Bounds[j]
+		if (pi.Y > pos.Y) != (pj.Y > pos.Y) &&
+			pos.X < (pj.X-pi.X)*(pos.Y-pi.Y)/(pj.Y-pi.Y)+pi.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// SectorAt returns the sector containing pos, or nil if it is outside every sector.
+func (ap *Airspace) SectorAt(pos types.Vec2, altitude float64) *Sector {
+	for _, name := range ap.SectorNames() {
+		if sec := ap.Sectors[name]; sec.Contains(pos, altitude) {
+			return sec
+		}
+	}
+	return nil
+}
+
+// SectorNames returns the sector names in a stable order.
+func (ap *Airspace) SectorNames() []string {
+	return slices.Sorted(maps.Keys(ap.Sectors))
+}
+
+// HasWaypoint reports whether the airspace has a waypoint by that name.
+func (ap *Airspace) HasWaypoint(name string) bool {
+	_, ok := ap.Waypoints[name]
+	return ok
+}
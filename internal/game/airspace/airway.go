@@ -0,0 +1,73 @@
+package airspace
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Airway is a named route between waypoints. Traffic joins and leaves it at
+// any of its fixes and flies it between MinAltitude and MaxAltitude.
+type Airway struct {
+	Name        string
+	Fixes       []string
+	OneWay      bool // flown only in the order of Fixes
+	MinAltitude float64
+	MaxAltitude float64
+}
+
+// Between returns the fixes flown along the airway from one of its fixes to
+// another, not counting the first.
+func (a *Airway) Between(from, to string) ([]string, error) {
+	i, j := slices.Index(a.Fixes, from), slices.Index(a.Fixes, to)
+	switch {
+	case i < 0:
+		return nil, fmt.Errorf("%s is not on airway %s", from, a.Name)
+	case j < 0:
+		return nil, fmt.Errorf("%s is not on airway %s", to, a.Name)
+	case i == j:
+		return nil, fmt.Errorf("airway %s joined and left at %s", a.Name, from)
+	case i < j:
+		return slices.Clone(a.Fixes[i+1 : j+1]), nil
+	case a.OneWay:
+		return nil, fmt.Errorf("airway %s is one way, not from %s to %s", a.Name, from, to)
+	}
+	fixes := slices.Clone(a.Fixes[j:i])
+	slices.Reverse(fixes)
+	return fixes, nil
+}
+
+// AllowsAltitude reports whether the airway may be flown at altitude.
+func (a *Airway) AllowsAltitude(altitude float64) bool {
+	return altitude >= a.MinAltitude && (a.MaxAltitude == 0 || altitude <= a.MaxAltitude)
+}
+
+// AddAirway publishes an airway. It needs two or more fixes, all known
+// waypoints.
+func (ap *Airspace) AddAirway(a Airway) error {
+	if len(a.Fixes) < 2 {
+		return fmt.Errorf("airway %s needs at least two fixes", a.Name)
+	}
+	for _, fix := range a.Fixes {
+		if _, ok := ap.Waypoints[fix]; !ok {
+			return fmt.Errorf("airway %s: unknown waypoint %s", a.Name, fix)
+		}
+	}
+	if _, ok := ap.Waypoints[a.Name]; ok {
+		return fmt.Errorf("airway %s has the name of a waypoint", a.Name)
+	}
+	ap.Airways[a.Name] = &a
+	return nil
+}
+
+// AirwayBetween returns the first airway, by name, that may be flown from one
+// fix to another at the given altitude, or nil.
+func (ap *Airspace) AirwayBetween(from, to string, altitude float64) *Airway {
+	for _, name := range slices.Sorted(maps.Keys(ap.Airways)) {
+		a := ap.Airways[name]
+		if _, err := a.Between(from, to); err == nil && a.AllowsAltitude(altitude) {
+			return a
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package airspace
+
+import (
+	"atc-simulator/internal/game/flightplan"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// ProcedureKind tells departures from arrivals.
+type ProcedureKind int
+
+const (
+	SID ProcedureKind = iota
+	STAR
+)
+
+var ProcedureKindStringMap = map[ProcedureKind]string{
+	SID:  "SID",
+	STAR: "STAR",
+}
+
+// ProcedureFix is a fix of a procedure and the constraints at it.
+type ProcedureFix struct {
+	Waypoint string
+	Altitude flightplan.Constraint
+	Speed    flightplan.Constraint
+}
+
+// Procedure is a standard instrument departure from, or arrival to, one
+// runway of an airport.
+type Procedure struct {
+	Name      string // e.g. "APIPO1A"
+	Kind      ProcedureKind
+	AirportID string
+	Runway    string
+	Fixes     []ProcedureFix
+}
+
+// Segments returns the procedure's fixes from the index'th on as flight plan
+// segments flown at the given altitude and speed unless constrained.
+func (p *Procedure) Segments(from int, altitude, speed float64) []flightplan.FlightPlanSegment {
+	var segments []flightplan.FlightPlanSegment
+	for _, fix := range p.Fixes[from:] {
+		segments = append(segments, flightplan.FlightPlanSegment{
+			Type:           flightplan.SegmentTypeWaypoint,
+			WaypointName:   fix.Waypoint,
+			TargetAltitude: altitude,
+			TargetSpeed:    speed,
+			Procedure:      p.Name,
+			Altitude:       fix.Altitude,
+			Speed:          fix.Speed,
+		})
+	}
+	return segments
+}
+
+// FixIndex returns the position of a waypoint on the procedure, or -1.
+func (p *Procedure) FixIndex(waypoint string) int {
+	return slices.IndexFunc(p.Fixes, func(f ProcedureFix) bool { return f.Waypoint == waypoint })
+}
+
+// AddProcedure publishes a procedure at its airport. Every fix must be a
+// known waypoint and the runway must exist.
+func (ap *Airspace) AddProcedure(p Procedure) error {
+	airport, ok := ap.Airports[p.AirportID]
+	if !ok {
+		return fmt.Errorf("procedure %s: unknown airport %s", p.Name, p.AirportID)
+	}
+	if _, ok := airport.Runways[p.Runway]; !ok {
+		return fmt.Errorf("procedure %s: unknown runway %s at %s", p.Name, p.Runway, p.AirportID)
+	}
+	if len(p.Fixes) == 0 {
+		return fmt.Errorf("procedure %s has no fixes", p.Name)
+	}
+	for _, fix := range p.Fixes {
+		if _, ok := ap.Waypoints[fix.Waypoint]; !ok {
+			return fmt.Errorf("procedure %s: unknown waypoint %s", p.Name, fix.Waypoint)
+		}
+	}
+	if airport.Procedures == nil {
+		airport.Procedures = make(map[string]*Procedure)
+	}
+	airport.Procedures[p.Name] = &p
+	return nil
+}
+
+// Procedure finds a procedure by name at any airport.
+func (ap *Airspace) Procedure(name string) (*Procedure, bool) {
+	for _, id := range slices.Sorted(maps.Keys(ap.Airports)) {
+		if p, ok := ap.Airports[id].Procedures[name]; ok {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// HasProcedure reports whether any airport publishes a procedure by that name.
+func (ap *Airspace) HasProcedure(name string) bool {
+	_, ok := ap.Procedure(name)
+	return ok
+}
+
+// ArrivalRunway returns the airport and runway a STAR leads to.
+func (ap *Airspace) ArrivalRunway(star string) (airportID, runway string, ok bool) {
+	p, ok := ap.Procedure(star)
+	if !ok || p.Kind != STAR {
+		return "", "", false
+	}
+	return p.AirportID, p.Runway, true
+}
+
+// ProceduresFor returns the airport's procedures of a kind for a runway, by
+// name.
+func (a *Airport) ProceduresFor(kind ProcedureKind, runway string) []*Procedure {
+	var out []*Procedure
+	for _, name := range slices.Sorted(maps.Keys(a.Procedures)) {
+		if p := a.Procedures[name]; p.Kind == kind && p.Runway == runway {
+			out = append(out, p)
+		}
+	}
+	return out
+}
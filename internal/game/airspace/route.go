@@ -0,0 +1,188 @@
+package airspace
+
+import (
+	"atc-simulator/internal/game/flightplan"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RouteError is returned by ParseRoute for the element of the route that
+// could not be expanded. Element counts from 1.
+type RouteError struct {
+	Element int
+	Text    string
+	Err     error
+}
+
+func (e *RouteError) Error() string {
+	return fmt.Sprintf("route element %d %s: %v", e.Element, e.Text, e.Err)
+}
+
+func (e *RouteError) Unwrap() error {
+	return e.Err
+}
+
+// ParseRoute expands an ICAO field 15 style route such as
+//
+//	N0250F240 APIPO1D APIPO W12 CIPKA DCT FILKA/N0230A080 FILKA1B
+//
+// into flight plan segments, one per fix flown over. Airways are expanded to
+// the fixes between the points around them; a SID may open the route and a
+// STAR close it. The route is flown at altitude and speed unless it opens
+// with a speed and level, and a point followed by one changes them for the
+// rest of the route. Speeds are N (knots) or K (km/h), levels F (flight
+// level) or A (altitude in hundreds of feet).
+func (ap *Airspace) ParseRoute(route string, altitude, speed float64) ([]flightplan.FlightPlanSegment, error) {
+	elements := strings.Fields(strings.ToUpper(route))
+
+	var segments []flightplan.FlightPlanSegment
+	var last string    // the last point flown over
+	var airway *Airway // airway waiting for its exit point
+	var direct bool    // DCT waiting for its point
+	appendFix := func(name string) {
+		if name != last {
+			segments = append(segments, flightplan.FlightPlanSegment{
+				Type:           flightplan.SegmentTypeWaypoint,
+				WaypointName:   name,
+				TargetAltitude: altitude,
+				TargetSpeed:    speed,
+			})
+		}
+		last = name
+	}
+
+	for i, el := range elements {
+		fail := func(format string, args ...any) error {
+			return &RouteError{Element: i + 1, Text: el, Err: fmt.Errorf(format, args...)}
+		}
+		if i == 0 {
+			if alt, spd, err := parseSpeedLevel(el); err == nil {
+				altitude, speed = alt, spd
+				continue
+			}
+		}
+
+		name, change, hasChange := strings.Cut(el, "/")
+		if _, isPoint := ap.Waypoints[name]; !isPoint && hasChange {
+			return nil, fail("only a point can change speed and level")
+		}
+
+		if el == "DCT" {
+			if last == "" || airway != nil || direct {
+				return nil, fail("DCT must follow a point")
+			}
+			direct = true
+			continue
+		}
+
+		if a, ok := ap.Airways[name]; ok {
+			switch {
+			case last == "" || direct:
+				return nil, fail("airway must follow a point")
+			case airway != nil:
+				return nil, fail("give the point joining %s to %s", airway.Name, name)
+			case !a.AllowsAltitude(altitude):
+				return nil, fail("%.0f ft is outside the airway's %.0f to %.0f ft", altitude, a.MinAltitude, a.MaxAltitude)
+			}
+			airway = a
+			continue
+		}
+
+		if p, ok := ap.Procedure(name); ok {
+			if airway != nil {
+				return nil, fail("airway %s has no exit point", airway.Name)
+			}
+			switch p.Kind {
+			case SID:
+				if len(segments) > 0 {
+					return nil, fail("a SID must open the route")
+				}
+				segments = p.Segments(0, altitude, speed)
+				last = p.Fixes[len(p.Fixes)-1].Waypoint
+			case STAR:
+				if i != len(elements)-1 {
+					return nil, fail("a STAR must close the route")
+				}
+				from := 0
+				if last != "" {
+					if from = p.FixIndex(last); from < 0 {
+						return nil, fail("STAR does not start at %s", last)
+					}
+					from++
+				}
+				segments = append(segments, p.Segments(from, altitude, speed)...)
+				if from < len(p.Fixes) {
+					last = p.Fixes[len(p.Fixes)-1].Waypoint
+				}
+			}
+			direct = false
+			continue
+		}
+
+		if _, ok := ap.Waypoints[name]; !ok {
+			return nil, fail("unknown point, airway or procedure")
+		}
+		if airway != nil {
+			fixes, err := airway.Between(last, name)
+			if err != nil {
+				return nil, fail("%v", err)
+			}
+			for _, fix := range fixes {
+				appendFix(fix)
+			}
+			airway = nil
+		} else {
+			appendFix(name)
+		}
+		direct = false
+
+		if hasChange {
+			alt, spd, err := parseSpeedLevel(change)
+			if err != nil {
+				return nil, fail("%v", err)
+			}
+			altitude, speed = alt, spd
+		}
+	}
+
+	switch {
+	case airway != nil:
+		return nil, &RouteError{Element: len(elements), Text: airway.Name, Err: errors.New("airway has no exit point")}
+	case direct:
+		return nil, &RouteError{Element: len(elements), Text: "DCT", Err: errors.New("DCT has no point after it")}
+	case len(segments) == 0:
+		return nil, errors.New("route has no points")
+	}
+	return segments, nil
+}
+
+// parseSpeedLevel reads a speed and level group such as N0250F240 into feet
+// and knots.
+func parseSpeedLevel(group string) (altitude, speed float64, err error) {
+	if len(group) != 9 {
+		return 0, 0, fmt.Errorf("%s is not a speed and level", group)
+	}
+	n, errSpd := strconv.Atoi(group[1:5])
+	l, errLvl := strconv.Atoi(group[6:9])
+	if errSpd != nil || errLvl != nil {
+		return 0, 0, fmt.Errorf("%s is not a speed and level", group)
+	}
+
+	switch group[0] {
+	case 'N':
+		speed = float64(n)
+	case 'K':
+		speed = float64(n) / 1.852
+	default:
+		return 0, 0, fmt.Errorf("speed %s must be in knots (N) or km/h (K)", group[:5])
+	}
+	switch group[5] {
+	case 'F', 'A':
+		altitude = float64(l) * 100
+	default:
+		return 0, 0, fmt.Errorf("level %s must be a flight level (F) or altitude (A)", group[5:])
+	}
+	return altitude, speed, nil
+}
@@ -0,0 +1,289 @@
+// Package command parses the controller command language:
+//
+//	line        = [callsign] instruction {instruction}
+//	instruction = ("H" | "HEADING") degrees
+//	            | ("A" | "ALT" | "ALTITUDE") (feet | "FL" level)
+//	            | ("S" | "SPD" | "SPEED") knots
+//	            | ("D" | "DIRECT") waypoint
+//	            | ("LAND" | "LANDING") runway
+//	            | ("PO" | "POINTOUT") sector
+//	            | ("FC" | "CONTACT") [frequency]
+//	            | "SID" procedure | "STAR" procedure
+//	            | "CVIA" [feet | "FL" level] | "DVIA" [feet | "FL" level]
+//	            | ("RR" | "REROUTE") route
+//	            | ("INS" | "INSERT") waypoint [waypoint]
+//	            | ("DEL" | "DELETE") waypoint
+//	            | ("DEST" | "DESTINATION") (airport | waypoint)
+//	            | "RON" | "RESUME"
+//	            | "HO" | "HANDOFF" | "ACPT" | "ACCEPT" | "RJCT" | "REJECT"
+//	            | "ACK" | "APPROVE" | "UNABLE" | "DENY" | "NEGATIVE"
+//
+// A route is an ICAO style route such as "APIPO W12 CIPKA DCT FILKA" and runs
+// to the next keyword written apart from its value.
+//
+// Numeric arguments may be written glued to their keyword, so
+// "AAL101 H270 A FL120 S 250" and "AAL101 H 270 AFL120 S250" are the same.
+package command
+
+import (
+	"atc-simulator/pkg/types"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidValue is wrapped by parse errors for well-formed instructions
+// with an out of range or malformed value.
+var ErrInvalidValue = errors.New("invalid value")
+
+// Error is a parse or execution error at a byte offset in the command line.
+// Err is the execution error it wraps, nil or ErrInvalidValue for parse
+// errors.
+type Error struct {
+	Pos int
+	Msg string
+	Err error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (column %d)", e.Msg, e.Pos+1)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func Errorf(pos int, format string, args ...any) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func invalidValue(pos int, format string, args ...any) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...), Err: ErrInvalidValue}
+}
+
+// Wrap attributes an error from executing a command to its position.
+func Wrap(pos int, err error) *Error {
+	return &Error{Pos: pos, Msg: err.Error(), Err: err}
+}
+
+// Line is a parsed command line. Callsign is empty when the line addresses
+// the selected aircraft.
+type Line struct {
+	Callsign    types.AircraftID
+	CallsignPos int
+	Commands    []Command
+}
+
+// Command is a single instruction to an aircraft.
+type Command interface {
+	// Name is the canonical keyword, e.g. "H" for HEADING.
+	Name() string
+	// Pos is the byte offset of the keyword in the command line.
+	Pos() int
+	String() string
+}
+
+type at int
+
+func (a at) Pos() int { return int(a) }
+
+type Heading struct {
+	at
+	Degrees float64
+}
+
+func (Heading) Name() string     { return "H" }
+func (c Heading) String() string { return fmt.Sprintf("H %03.0f", c.Degrees) }
+
+type Altitude struct {
+	at
+	Feet float64
+}
+
+func (Altitude) Name() string     { return "A" }
+func (c Altitude) String() string { return fmt.Sprintf("A %.0f", c.Feet) }
+
+type Speed struct {
+	at
+	Knots float64
+}
+
+func (Speed) Name() string     { return "S" }
+func (c Speed) String() string { return fmt.Sprintf("S %.0f", c.Knots) }
+
+type DirectTo struct {
+	at
+	Waypoint string
+}
+
+func (DirectTo) Name() string     { return "D" }
+func (c DirectTo) String() string { return "D " + c.Waypoint }
+
+type Land struct {
+	at
+	Runway string
+}
+
+func (Land) Name() string     { return "LAND" }
+func (c Land) String() string { return "LAND " + c.Runway }
+
+type PointOut struct {
+	at
+	Sector string
+}
+
+func (PointOut) Name() string     { return "PO" }
+func (c PointOut) String() string { return "PO " + c.Sector }
+
+type Handoff struct{ at }
+
+func (Handoff) Name() string   { return "HO" }
+func (Handoff) String() string { return "HO" }
+
+type AcceptHandoff struct{ at }
+
+func (AcceptHandoff) Name() string   { return "ACPT" }
+func (AcceptHandoff) String() string { return "ACPT" }
+
+type RejectHandoff struct{ at }
+
+func (RejectHandoff) Name() string   { return "RJCT" }
+func (RejectHandoff) String() string { return "RJCT" }
+
+// FrequencyChange sends the aircraft to the sector it has been handed off to.
+// Frequency is empty when the controller did not name it.
+type FrequencyChange struct {
+	at
+	Frequency string
+}
+
+func (FrequencyChange) Name() string { return "FC" }
+func (c FrequencyChange) String() string {
+	if c.Frequency == "" {
+		return "FC"
+	}
+	return "FC " + c.Frequency
+}
+
+type AckPointOut struct{ at }
+
+func (AckPointOut) Name() string   { return "ACK" }
+func (AckPointOut) String() string { return "ACK" }
+
+// Approve grants the aircraft's pending request.
+type Approve struct{ at }
+
+func (Approve) Name() string   { return "APPROVE" }
+func (Approve) String() string { return "APPROVE" }
+
+// Unable turns down the pending request for now; the pilot may ask again.
+type Unable struct{ at }
+
+func (Unable) Name() string   { return "UNABLE" }
+func (Unable) String() string { return "UNABLE" }
+
+// Deny refuses the pending request outright.
+type Deny struct{ at }
+
+func (Deny) Name() string   { return "DENY" }
+func (Deny) String() string { return "DENY" }
+
+// SID clears the aircraft for a standard instrument departure, joining it
+// where it crosses the current route.
+type SID struct {
+	at
+	Procedure string
+}
+
+func (SID) Name() string     { return "SID" }
+func (c SID) String() string { return "SID " + c.Procedure }
+
+// STAR clears the aircraft for a standard arrival, joining it where it
+// crosses the current route.
+type STAR struct {
+	at
+	Procedure string
+}
+
+func (STAR) Name() string     { return "STAR" }
+func (c STAR) String() string { return "STAR " + c.Procedure }
+
+// ClimbVia has the aircraft climb on its own, meeting the constraints of its
+// SID, up to Feet, or the filed level when zero.
+type ClimbVia struct {
+	at
+	Feet float64
+}
+
+func (ClimbVia) Name() string { return "CVIA" }
+func (c ClimbVia) String() string {
+	if c.Feet == 0 {
+		return "CVIA"
+	}
+	return fmt.Sprintf("CVIA %.0f", c.Feet)
+}
+
+// DescendVia has the aircraft descend on its own, meeting the constraints of
+// its STAR, down to Feet, or the STAR's last altitude when zero.
+type DescendVia struct {
+	at
+	Feet float64
+}
+
+func (DescendVia) Name() string { return "DVIA" }
+func (c DescendVia) String() string {
+	if c.Feet == 0 {
+		return "DVIA"
+	}
+	return fmt.Sprintf("DVIA %.0f", c.Feet)
+}
+
+// Reroute replaces the rest of the route with Route.
+type Reroute struct {
+	at
+	Route string
+}
+
+func (Reroute) Name() string     { return "RR" }
+func (c Reroute) String() string { return "RR " + c.Route }
+
+// InsertFix adds Waypoint to the route after the fix After, or ahead of the
+// next fix when After is empty.
+type InsertFix struct {
+	at
+	Waypoint string
+	After    string
+}
+
+func (InsertFix) Name() string { return "INS" }
+func (c InsertFix) String() string {
+	if c.After == "" {
+		return "INS " + c.Waypoint
+	}
+	return "INS " + c.Waypoint + " " + c.After
+}
+
+// DeleteFix takes Waypoint out of the route.
+type DeleteFix struct {
+	at
+	Waypoint string
+}
+
+func (DeleteFix) Name() string     { return "DEL" }
+func (c DeleteFix) String() string { return "DEL " + c.Waypoint }
+
+// ResumeNavigation ends vectors: the aircraft rejoins its route at the next
+// fix.
+type ResumeNavigation struct{ at }
+
+func (ResumeNavigation) Name() string   { return "RON" }
+func (ResumeNavigation) String() string { return "RON" }
+
+// ChangeDestination files the aircraft to an airport, where it lands, or a
+// fix it leaves the airspace by.
+type ChangeDestination struct {
+	at
+	Destination string
+}
+
+func (ChangeDestination) Name() string     { return "DEST" }
+func (c ChangeDestination) String() string { return "DEST " + c.Destination }
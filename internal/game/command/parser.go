@@ -0,0 +1,315 @@
+package command
+
+import (
+	"atc-simulator/pkg/types"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Token is a word of the command line and its byte offset.
+type Token struct {
+	Text string
+	Pos  int
+}
+
+// Tokenize splits a command line into upper-cased words. Commas and
+// semicolons separate words like whitespace does.
+func Tokenize(input string) []Token {
+	var tokens []Token
+	start := -1
+	for i, r := range input {
+		if unicode.IsSpace(r) || r == ',' || r == ';' {
+			if start >= 0 {
+				tokens = append(tokens, Token{Text: strings.ToUpper(input[start:i]), Pos: start})
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, Token{Text: strings.ToUpper(input[start:]), Pos: start})
+	}
+	return tokens
+}
+
+// keywords maps every spelling of an instruction to its canonical name.
+var keywords = map[string]string{
+	"H": "H", "HEADING": "H",
+	"A": "A", "ALT": "A", "ALTITUDE": "A",
+	"S": "S", "SPD": "S", "SPEED": "S",
+	"D": "D", "DIRECT": "D",
+	"LAND": "LAND", "LANDING": "LAND",
+	"PO": "PO", "POINTOUT": "PO",
+	"HO": "HO", "HANDOFF": "HO",
+	"ACPT": "ACPT", "ACCEPT": "ACPT",
+	"RJCT": "RJCT", "REJECT": "RJCT",
+	"FC": "FC", "CONTACT": "FC",
+	"ACK":     "ACK",
+	"APPROVE": "APPROVE", "APPROVED": "APPROVE",
+	"UNABLE": "UNABLE",
+	"DENY":   "DENY", "NEGATIVE": "DENY",
+	"SID":  "SID",
+	"STAR": "STAR",
+	"CVIA": "CVIA",
+	"DVIA": "DVIA",
+	"RR":   "RR", "REROUTE": "RR",
+	"INS": "INS", "INSERT": "INS",
+	"DEL": "DEL", "DELETE": "DEL",
+	"RON": "RON", "RESUME": "RON",
+	"DEST": "DEST", "DESTINATION": "DEST",
+}
+
+// gluedKeywords may be written directly in front of their numeric argument,
+// longest first so HEADING270 is not read as H EADING270.
+var gluedKeywords = []string{"ALTITUDE", "HEADING", "SPEED", "ALT", "SPD", "H", "A", "S"}
+
+type parser struct {
+	input  string
+	tokens []Token
+	next   int
+}
+
+// Parse parses a command line. Errors are *Error values pointing at the
+// offending word.
+func Parse(input string) (*Line, error) {
+	p := &parser{input: input, tokens: Tokenize(input)}
+	if len(p.tokens) == 0 {
+		return nil, Errorf(0, "empty command")
+	}
+
+	line := &Line{}
+	if first := p.tokens[0]; !p.startsInstruction(first) {
+		line.Callsign = types.AircraftID(first.Text)
+		line.CallsignPos = first.Pos
+		p.next++
+		if p.done() {
+			return nil, Errorf(len(input), "no command given for %s", line.Callsign)
+		}
+	}
+
+	for !p.done() {
+		cmd, err := p.instruction()
+		if err != nil {
+			return nil, err
+		}
+		line.Commands = append(line.Commands, cmd)
+	}
+	return line, nil
+}
+
+func (p *parser) done() bool {
+	return p.next >= len(p.tokens)
+}
+
+// startsInstruction reports whether tok is a keyword, on its own or glued to
+// a valid argument.
+func (p *parser) startsInstruction(tok Token) bool {
+	if _, ok := keywords[tok.Text]; ok {
+		return true
+	}
+	_, _, ok := splitGlued(tok)
+	return ok
+}
+
+// splitGlued splits a word like H270 or AFL120 into its keyword and argument.
+func splitGlued(tok Token) (string, Token, bool) {
+	for _, kw := range gluedKeywords {
+		rest, ok := strings.CutPrefix(tok.Text, kw)
+		if !ok || rest == "" {
+			continue
+		}
+		arg := Token{Text: rest, Pos: tok.Pos + len(kw)}
+		if _, err := parseArgument(keywords[kw], arg); err == nil {
+			return kw, arg, true
+		}
+	}
+	return "", Token{}, false
+}
+
+func (p *parser) instruction() (Command, error) {
+	tok := p.tokens[p.next]
+	p.next++
+
+	name, ok := keywords[tok.Text]
+	var arg *Token
+	if !ok {
+		kw, glued, isGlued := splitGlued(tok)
+		if !isGlued {
+			return nil, Errorf(tok.Pos, "unknown command type: %s", tok.Text)
+		}
+		name, arg = keywords[kw], &glued
+	}
+
+	pos := at(tok.Pos)
+	switch name {
+	case "HO":
+		return Handoff{pos}, nil
+	case "ACPT":
+		return AcceptHandoff{pos}, nil
+	case "RJCT":
+		return RejectHandoff{pos}, nil
+	case "FC":
+		return p.frequencyChange(pos)
+	case "ACK":
+		return AckPointOut{pos}, nil
+	case "APPROVE":
+		return Approve{pos}, nil
+	case "UNABLE":
+		return Unable{pos}, nil
+	case "DENY":
+		return Deny{pos}, nil
+	case "CVIA", "DVIA":
+		return p.via(name, pos)
+	case "RON":
+		return ResumeNavigation{pos}, nil
+	case "RR":
+		return p.route(tok, pos)
+	}
+
+	if arg == nil {
+		if p.done() {
+			return nil, Errorf(len(p.input), "%s needs a value", tok.Text)
+		}
+		arg = &p.tokens[p.next]
+		p.next++
+	}
+
+	value, err := parseArgument(name, *arg)
+	if err != nil {
+		return nil, err
+	}
+	switch name {
+	case "H":
+		return Heading{pos, value.(float64)}, nil
+	case "A":
+		return Altitude{pos, value.(float64)}, nil
+	case "S":
+		return Speed{pos, value.(float64)}, nil
+	case "D":
+		return DirectTo{pos, value.(string)}, nil
+	case "LAND":
+		return Land{pos, value.(string)}, nil
+	case "SID":
+		return SID{pos, value.(string)}, nil
+	case "STAR":
+		return STAR{pos, value.(string)}, nil
+	case "INS":
+		insert := InsertFix{at: pos, Waypoint: value.(string)}
+		if !p.done() && !p.startsInstruction(p.tokens[p.next]) {
+			insert.After = p.tokens[p.next].Text
+			p.next++
+		}
+		return insert, nil
+	case "DEL":
+		return DeleteFix{pos, value.(string)}, nil
+	case "DEST":
+		return ChangeDestination{pos, value.(string)}, nil
+	default:
+		return PointOut{pos, value.(string)}, nil
+	}
+}
+
+// frequencyChange parses the optional frequency after FC. A following word
+// that is not a number starts the next instruction.
+func (p *parser) frequencyChange(pos at) (Command, error) {
+	if p.done() {
+		return FrequencyChange{at: pos}, nil
+	}
+	arg := p.tokens[p.next]
+	mhz, err := parseNumber(arg.Text)
+	if err != nil {
+		return FrequencyChange{at: pos}, nil
+	}
+	p.next++
+	if mhz < 118 || mhz >= 137 {
+		return nil, invalidValue(arg.Pos, "invalid frequency %s. Must be 118.000-136.975", arg.Text)
+	}
+	return FrequencyChange{pos, fmt.Sprintf("%.3f", mhz)}, nil
+}
+
+// via parses the optional altitude after CVIA or DVIA. A following word that
+// is not an altitude starts the next instruction.
+func (p *parser) via(name string, pos at) (Command, error) {
+	var feet float64
+	if !p.done() {
+		if value, err := parseArgument("A", p.tokens[p.next]); err == nil {
+			feet = value.(float64)
+			p.next++
+		}
+	}
+	if name == "CVIA" {
+		return ClimbVia{pos, feet}, nil
+	}
+	return DescendVia{pos, feet}, nil
+}
+
+// route takes the words after RR up to the next keyword. Glued words such as
+// A33 are airways here, not instructions.
+func (p *parser) route(tok Token, pos at) (Command, error) {
+	var elements []string
+	for !p.done() {
+		if _, ok := keywords[p.tokens[p.next].Text]; ok {
+			break
+		}
+		elements = append(elements, p.tokens[p.next].Text)
+		p.next++
+	}
+	if len(elements) == 0 {
+		return nil, Errorf(len(p.input), "%s needs a route", tok.Text)
+	}
+	return Reroute{pos, strings.Join(elements, " ")}, nil
+}
+
+// parseArgument validates the argument of the instruction called name.
+func parseArgument(name string, arg Token) (any, error) {
+	switch name {
+	case "H":
+		heading, err := parseNumber(arg.Text)
+		if err != nil || heading < 0 || heading >= 360 {
+			return nil, invalidValue(arg.Pos, "invalid heading value: %s. Must be 0-359", arg.Text)
+		}
+		return heading, nil
+	case "A":
+		var altitude float64
+		var err error
+		if level, ok := strings.CutPrefix(arg.Text, "FL"); ok {
+			altitude, err = parseNumber(level)
+			altitude *= 100.0 // convert flight level to feet
+		} else {
+			altitude, err = parseNumber(arg.Text)
+		}
+		if err != nil || altitude < 0 {
+			return nil, invalidValue(arg.Pos, "invalid altitude value: %s. Must be positive", arg.Text)
+		}
+		return altitude, nil
+	case "S":
+		speed, err := parseNumber(arg.Text)
+		if err != nil || speed < 0 {
+			return nil, invalidValue(arg.Pos, "invalid speed value: %s. Must be positive", arg.Text)
+		}
+		return speed, nil
+	case "LAND":
+		runway := arg.Text
+		if _, err := strconv.Atoi(runway); err == nil {
+			runway = "RWY" + runway
+		}
+		if !strings.HasPrefix(runway, "RWY") {
+			return nil, invalidValue(arg.Pos, "invalid runway %s. Expected RWY<number>", arg.Text)
+		}
+		return runway, nil
+	default:
+		return arg.Text, nil
+	}
+}
+
+// parseNumber accepts plain decimal numbers only, not the exponents, hex,
+// NaN or Inf strconv would also take.
+func parseNumber(text string) (float64, error) {
+	if text == "" || strings.Trim(text, "0123456789.") != "" || strings.Count(text, ".") > 1 {
+		return 0, strconv.ErrSyntax
+	}
+	return strconv.ParseFloat(text, 64)
+}
@@ -0,0 +1,145 @@
+package command
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// commands renders the parsed instructions in their canonical form.
+func commands(line *Line) string {
+	texts := make([]string, len(line.Commands))
+	for i, c := range line.Commands {
+		texts[i] = c.String()
+	}
+	return strings.Join(texts, " ")
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		callsign string
+		want     string
+	}{
+		// The grammar examples: glued and spaced arguments read the same.
+		{"AAL101 H270 A FL120 S 250", "AAL101", "H 270 A 12000 S 250"},
+		{"AAL101 H 270 AFL120 S250", "AAL101", "H 270 A 12000 S 250"},
+		{"aal101 heading 270 altitude 12000 speed 250", "AAL101", "H 270 A 12000 S 250"},
+		{"AAL101 HEADING270 ALT5000 SPD210", "AAL101", "H 270 A 5000 S 210"},
+		{"AAL101,H270;A5000", "AAL101", "H 270 A 5000"},
+
+		// Without a callsign the line addresses the selected aircraft.
+		{"H 090", "", "H 090"},
+		{"H270 S250", "", "H 270 S 250"},
+		{"HOP123 H 100", "HOP123", "H 100"},
+		{"A320 H 100", "", "A 320 H 100"},
+
+		{"AAL101 D APIPO LAND 09", "AAL101", "D APIPO LAND RWY09"},
+		{"AAL101 DIRECT APIPO LANDING RWY27", "AAL101", "D APIPO LAND RWY27"},
+		{"AAL101 PO BLR_N_CTR", "AAL101", "PO BLR_N_CTR"},
+		{"AAL101 HO", "AAL101", "HO"},
+		{"AAL101 ACCEPT", "AAL101", "ACPT"},
+		{"AAL101 REJECT", "AAL101", "RJCT"},
+		{"AAL101 ACK", "AAL101", "ACK"},
+		{"AAL101 APPROVED", "AAL101", "APPROVE"},
+		{"AAL101 UNABLE", "AAL101", "UNABLE"},
+		{"AAL101 NEGATIVE", "AAL101", "DENY"},
+
+		// The frequency is optional.
+		{"AAL101 FC", "AAL101", "FC"},
+		{"AAL101 CONTACT 124.5 H 100", "AAL101", "FC 124.500 H 100"},
+		{"AAL101 FC H 100", "AAL101", "FC H 100"},
+
+		// So are the via altitudes.
+		{"AAL101 SID APIPO1D CVIA", "AAL101", "SID APIPO1D CVIA"},
+		{"AAL101 CVIA FL240", "AAL101", "CVIA 24000"},
+		{"AAL101 STAR APIPO1A DVIA 4000 S 210", "AAL101", "STAR APIPO1A DVIA 4000 S 210"},
+		{"AAL101 DVIA H 100", "AAL101", "DVIA H 100"},
+
+		// A route runs up to the next instruction.
+		{"AAL101 RR APIPO W12 CIPKA DCT FILKA A 12000", "AAL101", "RR APIPO W12 CIPKA DCT FILKA A 12000"},
+		{"AAL101 REROUTE FILKA A33 BISKET", "AAL101", "RR FILKA A33 BISKET"},
+		{"AAL101 INS CIPKA", "AAL101", "INS CIPKA"},
+		{"AAL101 INSERT CIPKA APIPO S 220", "AAL101", "INS CIPKA APIPO S 220"},
+		{"AAL101 DEL NOSEL RON", "AAL101", "DEL NOSEL RON"},
+		{"AAL101 DESTINATION KBLR", "AAL101", "DEST KBLR"},
+		{"AAL101 RESUME", "AAL101", "RON"},
+	}
+	for _, tt := range tests {
+		line, err := Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.input, err)
+			continue
+		}
+		if string(line.Callsign) != tt.callsign || commands(line) != tt.want {
+			t.Errorf("Parse(%q) = %q %q, want %q %q", tt.input, line.Callsign, commands(line), tt.callsign, tt.want)
+		}
+	}
+}
+
+func TestParsePositions(t *testing.T) {
+	line, err := Parse("  AAL101 H270  AFL120 D APIPO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line.CallsignPos != 2 {
+		t.Errorf("callsign at %d, want 2", line.CallsignPos)
+	}
+	for i, want := range []int{9, 15, 22} {
+		if got := line.Commands[i].Pos(); got != want {
+			t.Errorf("%s at %d, want %d", line.Commands[i], got, want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		pos     int
+		invalid bool // wraps ErrInvalidValue
+	}{
+		{"", 0, false},
+		{"   ", 0, false},
+		{"AAL101", 6, false},
+		{"AAL101 X 100", 7, false},
+		{"AAL101 H", 8, false},
+		{"AAL101 SID", 10, false},
+		{"AAL101 RR", 9, false},
+		{"AAL101 INS", 10, false},
+		{"AAL101 H 360", 9, true},
+		{"AAL101 H 270 S -5", 15, true},
+		{"AAL101 A FLX", 9, true},
+		{"AAL101 LAND X9", 12, true},
+		{"AAL101 FC 140", 10, true},
+		{"AAL101 FC 117.9", 10, true},
+
+		// Only plain decimal numbers: no NaN, Inf, exponents or hex.
+		{"AAL101 A NaN", 9, true},
+		{"AAL101 S Inf", 9, true},
+		{"AAL101 H +Inf", 9, true},
+		{"AAL101 A 1e4", 9, true},
+		{"AAL101 S 0x10", 9, true},
+		{"AAL101 SNAN", 7, false},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.input)
+		var cmdErr *Error
+		if !errors.As(err, &cmdErr) {
+			t.Errorf("Parse(%q) = %v, want an *Error", tt.input, err)
+			continue
+		}
+		if cmdErr.Pos != tt.pos {
+			t.Errorf("Parse(%q) error at %d, want %d: %v", tt.input, cmdErr.Pos, tt.pos, err)
+		}
+		if errors.Is(err, ErrInvalidValue) != tt.invalid {
+			t.Errorf("Parse(%q) = %v, invalid value %v, want %v", tt.input, err, !tt.invalid, tt.invalid)
+		}
+	}
+}
+
+func TestErrorColumn(t *testing.T) {
+	_, err := Parse("AAL101 H 360")
+	if want := "(column 10)"; err == nil || !strings.HasSuffix(err.Error(), want) {
+		t.Errorf("error %v, want it to end in %s", err, want)
+	}
+}
@@ -2,6 +2,7 @@ package conflict
 
 import (
 	"atc-simulator/internal/game/aircraft"
+	"atc-simulator/internal/game/trajectory"
 	"atc-simulator/pkg/types"
 	"math"
 )
@@ -66,3 +67,54 @@ func PredictConflict(ac1, ac2 *aircraft.Aircraft, futureTimeSeconds float64) (bo
 	}
 	return false, 0, types.Vec2{}, types.Vec2{} // No conflict
 }
+
+// probeStepSeconds is how often two trajectories are compared.
+const probeStepSeconds = 5.0
+
+// Predicted is a loss of separation between two predicted trajectories, at
+// the first time they come closer than the minima.
+type Predicted struct {
+	Callsign      types.AircraftID
+	Other         types.AircraftID
+	Time          float64 // game seconds
+	Position      types.Vec2
+	OtherPosition types.Vec2
+	Altitude      float64
+	OtherAltitude float64
+}
+
+// Distance returns the horizontal distance between the aircraft in NM.
+func (p Predicted) Distance() float64 {
+	return p.Position.DistanceTo(p.OtherPosition) / types.NM_TO_PIXEL
+}
+
+// ProbeTrajectories compares two trajectories over the time both cover and
+// returns the first loss of separation between them, if any.
+func ProbeTrajectories(a, b *trajectory.Trajectory) (Predicted, bool) {
+	if len(a.Points) == 0 || len(b.Points) == 0 {
+		return Predicted{}, false
+	}
+	start := max(a.Points[0].Time, b.Points[0].Time)
+	end := min(a.Points[len(a.Points)-1].Time, b.Points[len(b.Points)-1].Time)
+	minDistPixels := MIN_HORIZONTAL_SEPARATION * types.NM_TO_PIXEL
+
+	for t := start; t <= end; t += probeStepSeconds {
+		pa, okA := a.At(t)
+		pb, okB := b.At(t)
+		if !okA || !okB {
+			continue
+		}
+		if math.Abs(pa.Altitude-pb.Altitude) < MIN_VERTICAL_SEPARATION && pa.Position.DistanceTo(pb.Position) < minDistPixels {
+			return Predicted{
+				Callsign:      a.Callsign,
+				Other:         b.Callsign,
+				Time:          t,
+				Position:      pa.Position,
+				OtherPosition: pb.Position,
+				Altitude:      pa.Altitude,
+				OtherAltitude: pb.Altitude,
+			}, true
+		}
+	}
+	return Predicted{}, false
+}
@@ -1,6 +1,11 @@
 package flightplan
 
-import "atc-simulator/pkg/types"
+import (
+	"atc-simulator/pkg/types"
+	"fmt"
+	"math"
+	"slices"
+)
 
 type SegmentType int
 
@@ -9,6 +14,70 @@ const (
 	SegmentTypeLanding
 )
 
+// Restriction is how a constraint at a fix is to be met.
+type Restriction int
+
+const (
+	Unrestricted Restriction = iota
+	At
+	AtOrAbove
+	AtOrBelow
+	Between
+)
+
+var RestrictionStringMap = map[Restriction]string{
+	Unrestricted: "NONE",
+	At:           "AT",
+	AtOrAbove:    "AT_OR_ABOVE",
+	AtOrBelow:    "AT_OR_BELOW",
+	Between:      "BETWEEN",
+}
+
+// Constraint restricts the altitude in feet or the speed in knots at which a
+// fix is crossed. Upper is the top of a Between window, Value its bottom.
+type Constraint struct {
+	Restriction Restriction `json:",omitempty"`
+	Value       float64     `json:",omitempty"`
+	Upper       float64     `json:",omitempty"`
+}
+
+// Bounds returns the lowest and highest value that meet the constraint.
+func (c Constraint) Bounds() (lower, upper float64) {
+	switch c.Restriction {
+	case At:
+		return c.Value, c.Value
+	case AtOrAbove:
+		return c.Value, math.Inf(1)
+	case AtOrBelow:
+		return 0, c.Value
+	case Between:
+		return c.Value, c.Upper
+	}
+	return 0, math.Inf(1)
+}
+
+// Meets reports whether v meets the constraint, give or take tolerance.
+func (c Constraint) Meets(v, tolerance float64) bool {
+	lower, upper := c.Bounds()
+	return v >= lower-tolerance && v <= upper+tolerance
+}
+
+// String is the charted form: 4000 at, 6000+ at or above, 10000- at or
+// below, 10000+/14000- between.
+func (c Constraint) String() string {
+	switch c.Restriction {
+	case At:
+		return fmt.Sprintf("%.0f", c.Value)
+	case AtOrAbove:
+		return fmt.Sprintf("%.0f+", c.Value)
+	case AtOrBelow:
+		return fmt.Sprintf("%.0f-", c.Value)
+	case Between:
+		return fmt.Sprintf("%.0f+/%.0f-", c.Value, c.Upper)
+	}
+	return ""
+}
+
 type FlightPlanSegment struct {
 	Type           SegmentType
 	WaypointName   string
@@ -16,12 +85,56 @@ type FlightPlanSegment struct {
 	RunwayName     string
 	TargetAltitude float64
 	TargetSpeed    float64
+
+	// Fixes of a SID or STAR name it. Constraints are met crossing the fix.
+	Procedure string `json:",omitempty"`
+	Altitude  Constraint
+	Speed     Constraint
 }
 
 type FlightPlan struct {
 	OriginAirportID      string
-	DestinationAirportID string
+	DestinationAirportID string              // the airport landed at, or the fix the airspace is left by
 	Route                []FlightPlanSegment // Sequence of segments
 	CurrentSegmentIndex  int
 	Callsign             types.AircraftID
 }
+
+// CurrentSegment returns the segment being flown, or nil once the route is
+// complete.
+func (fp *FlightPlan) CurrentSegment() *FlightPlanSegment {
+	if fp == nil || fp.CurrentSegmentIndex < 0 || fp.CurrentSegmentIndex >= len(fp.Route) {
+		return nil
+	}
+	return &fp.Route[fp.CurrentSegmentIndex]
+}
+
+// Clone returns a copy of the flight plan that shares no route with it.
+func (fp *FlightPlan) Clone() *FlightPlan {
+	c := *fp
+	c.Route = slices.Clone(fp.Route)
+	return &c
+}
+
+// RouteIndex returns the index of the first segment from the current one on
+// that flies to waypoint, or -1.
+func (fp *FlightPlan) RouteIndex(waypoint string) int {
+	for i := max(fp.CurrentSegmentIndex, 0); i < len(fp.Route); i++ {
+		if seg := fp.Route[i]; seg.Type == SegmentTypeWaypoint && seg.WaypointName == waypoint {
+			return i
+		}
+	}
+	return -1
+}
+
+// Insert adds a segment to waypoint before the i'th, flown at the altitude
+// and speed of the segment it is inserted before, or after at the end.
+func (fp *FlightPlan) Insert(i int, waypoint string) {
+	seg := FlightPlanSegment{Type: SegmentTypeWaypoint, WaypointName: waypoint}
+	if i < len(fp.Route) {
+		seg.TargetAltitude, seg.TargetSpeed = fp.Route[i].TargetAltitude, fp.Route[i].TargetSpeed
+	} else if i > 0 {
+		seg.TargetAltitude, seg.TargetSpeed = fp.Route[i-1].TargetAltitude, fp.Route[i-1].TargetSpeed
+	}
+	fp.Route = slices.Insert(fp.Route, i, seg)
+}
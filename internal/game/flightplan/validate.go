@@ -0,0 +1,159 @@
+package flightplan
+
+import (
+	"atc-simulator/pkg/types"
+	"fmt"
+	"strings"
+)
+
+// Chart is what a flight plan refers to. *airspace.Airspace implements it.
+type Chart interface {
+	HasWaypoint(name string) bool
+	HasRunway(airportID, runway string) bool
+	HasProcedure(name string) bool
+	// ArrivalRunway returns the airport and runway a STAR leads to.
+	ArrivalRunway(star string) (airportID, runway string, ok bool)
+}
+
+// Problem is what is wrong with a flight plan.
+type Problem int
+
+const (
+	ProblemEmptyRoute Problem = iota
+	ProblemSegmentIndex
+	ProblemMissingWaypoint
+	ProblemUnknownWaypoint
+	ProblemDuplicateFix
+	ProblemUnknownProcedure
+	ProblemUnknownRunway
+	ProblemLandingNotLast
+	ProblemApproachMismatch
+	ProblemInvalidTarget
+	ProblemDestinationMismatch
+)
+
+var ProblemStringMap = map[Problem]string{
+	ProblemEmptyRoute:          "EMPTY_ROUTE",
+	ProblemSegmentIndex:        "SEGMENT_INDEX",
+	ProblemMissingWaypoint:     "MISSING_WAYPOINT",
+	ProblemUnknownWaypoint:     "UNKNOWN_WAYPOINT",
+	ProblemDuplicateFix:        "DUPLICATE_FIX",
+	ProblemUnknownProcedure:    "UNKNOWN_PROCEDURE",
+	ProblemUnknownRunway:       "UNKNOWN_RUNWAY",
+	ProblemLandingNotLast:      "LANDING_NOT_LAST",
+	ProblemApproachMismatch:    "APPROACH_MISMATCH",
+	ProblemInvalidTarget:       "INVALID_TARGET",
+	ProblemDestinationMismatch: "DESTINATION_MISMATCH",
+}
+
+// ValidationError is one problem found in a flight plan. Segment is the index
+// in the route of the segment at fault, or -1 for the plan as a whole.
+type ValidationError struct {
+	Segment int
+	Problem Problem
+	Text    string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Segment < 0 {
+		return e.Text
+	}
+	return fmt.Sprintf("segment %d: %s", e.Segment, e.Text)
+}
+
+// ValidationErrors is every problem found in a flight plan, in route order.
+type ValidationErrors []*ValidationError
+
+func (errs ValidationErrors) Error() string {
+	texts := make([]string, len(errs))
+	for i, err := range errs {
+		texts[i] = err.Error()
+	}
+	return strings.Join(texts, "; ")
+}
+
+func (errs ValidationErrors) Unwrap() []error {
+	wrapped := make([]error, len(errs))
+	for i, err := range errs {
+		wrapped[i] = err
+	}
+	return wrapped
+}
+
+// Validate checks the segments still to be flown against chart: every fix,
+// procedure and runway must exist, a fix is flown over once, an approach ends
+// the route on the runway of the STAR before it, and the destination is where
+// the route ends. It returns ValidationErrors, or nil for a sound plan.
+func (fp *FlightPlan) Validate(chart Chart) error {
+	var errs ValidationErrors
+	fail := func(segment int, problem Problem, format string, args ...any) {
+		errs = append(errs, &ValidationError{Segment: segment, Problem: problem, Text: fmt.Sprintf(format, args...)})
+	}
+
+	switch {
+	case len(fp.Route) == 0:
+		fail(-1, ProblemEmptyRoute, "route has no segments")
+		return errs
+	case fp.CurrentSegmentIndex < 0 || fp.CurrentSegmentIndex > len(fp.Route):
+		fail(-1, ProblemSegmentIndex, "current segment %d is outside the route of %d", fp.CurrentSegmentIndex, len(fp.Route))
+		return errs
+	}
+
+	seen := make(map[string]bool)
+	for i := fp.CurrentSegmentIndex; i < len(fp.Route); i++ {
+		seg := fp.Route[i]
+		switch seg.Type {
+		case SegmentTypeWaypoint:
+			switch {
+			case seg.WaypointName == "":
+				fail(i, ProblemMissingWaypoint, "segment has no fix")
+			case !chart.HasWaypoint(seg.WaypointName):
+				fail(i, ProblemUnknownWaypoint, "unknown fix %s", seg.WaypointName)
+			case seen[seg.WaypointName]:
+				fail(i, ProblemDuplicateFix, "%s is already on the route", seg.WaypointName)
+			}
+			seen[seg.WaypointName] = true
+		case SegmentTypeLanding:
+			if !chart.HasRunway(seg.AirportID, seg.RunwayName) {
+				fail(i, ProblemUnknownRunway, "unknown runway %s/%s", seg.AirportID, seg.RunwayName)
+			}
+			if i != len(fp.Route)-1 {
+				fail(i, ProblemLandingNotLast, "approach to %s/%s must end the route", seg.AirportID, seg.RunwayName)
+			}
+			if i > 0 {
+				star := fp.Route[i-1].Procedure
+				if airportID, runway, ok := chart.ArrivalRunway(star); ok && (airportID != seg.AirportID || runway != seg.RunwayName) {
+					fail(i, ProblemApproachMismatch, "approach to %s/%s follows %s to %s/%s", seg.AirportID, seg.RunwayName, star, airportID, runway)
+				}
+			}
+		}
+		if seg.Procedure != "" && !chart.HasProcedure(seg.Procedure) {
+			fail(i, ProblemUnknownProcedure, "unknown procedure %s", seg.Procedure)
+		}
+		if !types.Finite(seg.TargetAltitude) || !types.Finite(seg.TargetSpeed) || seg.TargetAltitude < 0 || seg.TargetSpeed <= 0 {
+			fail(i, ProblemInvalidTarget, "altitude %.0f and speed %.0f must be finite and positive", seg.TargetAltitude, seg.TargetSpeed)
+		}
+	}
+
+	if end := fp.Destination(); fp.DestinationAirportID != end {
+		fail(-1, ProblemDestinationMismatch, "destination %q is not where the route ends, %q", fp.DestinationAirportID, end)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// Destination is where the route ends: the airport of its approach, or the
+// fix it leaves the airspace by.
+func (fp *FlightPlan) Destination() string {
+	if len(fp.Route) == 0 {
+		return ""
+	}
+	last := fp.Route[len(fp.Route)-1]
+	if last.Type == SegmentTypeLanding {
+		return last.AirportID
+	}
+	return last.WaypointName
+}
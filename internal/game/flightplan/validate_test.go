@@ -0,0 +1,107 @@
+package flightplan
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+// testChart has three fixes, two runways at VOBL, the APIPO1A arrival to
+// runway 09L and the APIPO1D departure.
+type testChart struct{}
+
+func (testChart) HasWaypoint(name string) bool {
+	return name == "APIPO" || name == "CIPKA" || name == "FILKA"
+}
+
+func (testChart) HasRunway(airportID, runway string) bool {
+	return airportID == "VOBL" && (runway == "09L" || runway == "27R")
+}
+
+func (testChart) HasProcedure(name string) bool {
+	return name == "APIPO1A" || name == "APIPO1D"
+}
+
+func (testChart) ArrivalRunway(star string) (string, string, bool) {
+	if star == "APIPO1A" {
+		return "VOBL", "09L", true
+	}
+	return "", "", false
+}
+
+func fix(name string) FlightPlanSegment {
+	return FlightPlanSegment{Type: SegmentTypeWaypoint, WaypointName: name, TargetAltitude: 10000, TargetSpeed: 250}
+}
+
+func landing(runway string) FlightPlanSegment {
+	return FlightPlanSegment{Type: SegmentTypeLanding, AirportID: "VOBL", RunwayName: runway, TargetSpeed: 140}
+}
+
+func viaStar(seg FlightPlanSegment) FlightPlanSegment {
+	seg.Procedure = "APIPO1A"
+	return seg
+}
+
+func withTarget(altitude, speed float64) FlightPlanSegment {
+	seg := fix("CIPKA")
+	seg.TargetAltitude, seg.TargetSpeed = altitude, speed
+	return seg
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		route       []FlightPlanSegment
+		current     int
+		destination string
+		want        []Problem
+	}{
+		{"overflight", []FlightPlanSegment{fix("APIPO"), fix("FILKA")}, 0, "FILKA", nil},
+		{"arrival", []FlightPlanSegment{viaStar(fix("APIPO")), landing("09L")}, 0, "VOBL", nil},
+		{"flown segments skipped", []FlightPlanSegment{fix("NOWHERE"), fix("FILKA")}, 1, "FILKA", nil},
+		{"route complete", []FlightPlanSegment{fix("APIPO")}, 1, "APIPO", nil},
+
+		{"empty route", nil, 0, "", []Problem{ProblemEmptyRoute}},
+		{"segment past the end", []FlightPlanSegment{fix("APIPO")}, 2, "APIPO", []Problem{ProblemSegmentIndex}},
+		{"negative segment", []FlightPlanSegment{fix("APIPO")}, -1, "APIPO", []Problem{ProblemSegmentIndex}},
+		{"missing fix", []FlightPlanSegment{fix(""), fix("FILKA")}, 0, "FILKA", []Problem{ProblemMissingWaypoint}},
+		{"unknown fix", []FlightPlanSegment{fix("NOWHERE"), fix("FILKA")}, 0, "FILKA", []Problem{ProblemUnknownWaypoint}},
+		{"duplicate fix", []FlightPlanSegment{fix("APIPO"), fix("CIPKA"), fix("APIPO")}, 0, "APIPO", []Problem{ProblemDuplicateFix}},
+		{"unknown procedure", []FlightPlanSegment{{Type: SegmentTypeWaypoint, WaypointName: "APIPO", Procedure: "NOPE1A", TargetAltitude: 8000, TargetSpeed: 250}}, 0, "APIPO", []Problem{ProblemUnknownProcedure}},
+		{"unknown runway", []FlightPlanSegment{fix("APIPO"), landing("36")}, 0, "VOBL", []Problem{ProblemUnknownRunway}},
+		{"landing not last", []FlightPlanSegment{landing("09L"), fix("FILKA")}, 0, "FILKA", []Problem{ProblemLandingNotLast}},
+		{"approach mismatch", []FlightPlanSegment{viaStar(fix("APIPO")), landing("27R")}, 0, "VOBL", []Problem{ProblemApproachMismatch}},
+		{"negative altitude", []FlightPlanSegment{withTarget(-1, 250)}, 0, "CIPKA", []Problem{ProblemInvalidTarget}},
+		{"no speed", []FlightPlanSegment{withTarget(10000, 0)}, 0, "CIPKA", []Problem{ProblemInvalidTarget}},
+		{"NaN altitude", []FlightPlanSegment{withTarget(math.NaN(), 250)}, 0, "CIPKA", []Problem{ProblemInvalidTarget}},
+		{"infinite altitude", []FlightPlanSegment{withTarget(math.Inf(1), 250)}, 0, "CIPKA", []Problem{ProblemInvalidTarget}},
+		{"infinite speed", []FlightPlanSegment{withTarget(10000, math.Inf(1))}, 0, "CIPKA", []Problem{ProblemInvalidTarget}},
+		{"destination mismatch", []FlightPlanSegment{fix("APIPO"), fix("FILKA")}, 0, "VOBL", []Problem{ProblemDestinationMismatch}},
+		{"several problems", []FlightPlanSegment{fix("NOWHERE"), withTarget(math.NaN(), 250)}, 0, "VOBL", []Problem{ProblemUnknownWaypoint, ProblemInvalidTarget, ProblemDestinationMismatch}},
+	}
+	for _, tt := range tests {
+		fp := &FlightPlan{DestinationAirportID: tt.destination, Route: tt.route, CurrentSegmentIndex: tt.current}
+		err := fp.Validate(testChart{})
+		var errs ValidationErrors
+		if err != nil && !errors.As(err, &errs) {
+			t.Errorf("%s: error %T, want ValidationErrors", tt.name, err)
+			continue
+		}
+		var got []Problem
+		for _, e := range errs {
+			got = append(got, e.Problem)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: problems %v (%v), want %v", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestValidationErrorText(t *testing.T) {
+	fp := &FlightPlan{DestinationAirportID: "VOBL", Route: []FlightPlanSegment{fix("NOWHERE")}}
+	want := `segment 0: unknown fix NOWHERE; destination "VOBL" is not where the route ends, "NOWHERE"`
+	if err := fp.Validate(testChart{}); err == nil || err.Error() != want {
+		t.Errorf("error %v, want %s", err, want)
+	}
+}
@@ -0,0 +1,288 @@
+// Package phraseology turns controller instructions into radiotelephony,
+// e.g. "turn left heading two seven zero".
+package phraseology
+
+import (
+	"atc-simulator/internal/game/command"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// TransitionAltitude is the altitude at and above which levels are spoken as
+// flight levels.
+const TransitionAltitude = 18000
+
+var digitWords = [...]string{"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "niner"}
+
+// State is what the phrasing of an instruction depends on: which way to turn,
+// climb or change speed, who a frequency change is to, which procedure is
+// being flown and the next fix of the route.
+type State struct {
+	Heading  float64
+	Altitude float64
+	Speed    float64
+
+	Station   string // e.g. "Bengaluru South Approach"
+	Frequency string // of Station, e.g. "119.500"
+	Procedure string // SID or STAR, e.g. "APIPO1A"
+	NextFix   string
+}
+
+// Digits speaks n digit by digit, zero padded to width.
+func Digits(n, width int) string {
+	s := fmt.Sprintf("%0*d", width, n)
+	words := make([]string, len(s))
+	for i, r := range s {
+		words[i] = digitWords[r-'0']
+	}
+	return strings.Join(words, " ")
+}
+
+// Level speaks an altitude in feet, as a flight level at or above the
+// transition altitude.
+func Level(feet float64) string {
+	if feet >= TransitionAltitude {
+		return "flight level " + Digits(int(math.Round(feet/100)), 3)
+	}
+
+	hundreds := int(math.Round(feet / 100))
+	thousands, rest := hundreds/10, hundreds%10
+	var words []string
+	if thousands > 0 {
+		words = append(words, Digits(thousands, 1)+" thousand")
+	}
+	if rest > 0 || thousands == 0 {
+		words = append(words, Digits(rest, 1)+" hundred")
+	}
+	return strings.Join(words, " ")
+}
+
+// Frequency speaks a frequency such as 119.500 as "one one niner decimal five",
+// dropping trailing zeros after the first decimal.
+func Frequency(mhz string) string {
+	whole, decimals, _ := strings.Cut(mhz, ".")
+	decimals = strings.TrimRight(decimals, "0")
+	if decimals == "" {
+		decimals = "0"
+	}
+	return Spell(whole) + " decimal " + Spell(decimals)
+}
+
+// Spell speaks the digits of s one by one, ignoring anything else.
+func Spell(s string) string {
+	var words []string
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			words = append(words, digitWords[r-'0'])
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// telephony is the spoken designator of the airlines the simulation flies.
+var telephony = map[string]string{
+	"AAL": "American",
+	"SWA": "Southwest",
+	"DAL": "Delta",
+	"UAL": "United",
+	"JBU": "JetBlue",
+	"ASA": "Alaska",
+	"FFT": "Frontier Flight",
+	"AI":  "Air India",
+	"JAL": "Japan Air",
+}
+
+// Callsign speaks a callsign such as AAL101 as "American one zero one".
+func Callsign(callsign string) string {
+	airline, number := splitCallsign(callsign)
+	if number == "" {
+		return callsign
+	}
+	return airline + " " + Spell(number)
+}
+
+// splitCallsign returns the spoken airline and the flight number of a
+// callsign. Unknown airlines are spelled letter by letter.
+func splitCallsign(callsign string) (airline, number string) {
+	i := strings.IndexFunc(callsign, func(r rune) bool { return r >= '0' && r <= '9' })
+	if i <= 0 {
+		return callsign, ""
+	}
+	if name, ok := telephony[callsign[:i]]; ok {
+		return name, callsign[i:]
+	}
+	return strings.Join(strings.Split(callsign[:i], ""), " "), callsign[i:]
+}
+
+var alphabet = map[rune]string{
+	'A': "alpha", 'B': "bravo", 'C': "charlie", 'D': "delta", 'E': "echo",
+	'F': "foxtrot", 'G': "golf", 'H': "hotel", 'J': "juliett", 'K': "kilo",
+	'L': "lima", 'M': "mike", 'N': "november", 'P': "papa", 'Q': "quebec",
+	'R': "romeo", 'S': "sierra", 'T': "tango", 'U': "uniform", 'V': "victor",
+	'W': "whiskey", 'X': "x-ray", 'Y': "yankee", 'Z': "zulu",
+}
+
+// Procedure speaks a SID or STAR name such as APIPO1A as "APIPO one alpha":
+// the fix, then the number and letter of the revision.
+func Procedure(name string) string {
+	i := strings.IndexFunc(name, func(r rune) bool { return r >= '0' && r <= '9' })
+	if i <= 0 {
+		return name
+	}
+	words := []string{name[:i]}
+	for _, r := range name[i:] {
+		switch {
+		case r >= '0' && r <= '9':
+			words = append(words, digitWords[r-'0'])
+		case alphabet[r] != "":
+			words = append(words, alphabet[r])
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// Runway speaks a runway name such as RWY27.
+func Runway(name string) string {
+	designator := strings.TrimPrefix(name, "RWY")
+	words := make([]string, 0, len(designator))
+	for _, r := range designator {
+		switch {
+		case r >= '0' && r <= '9':
+			words = append(words, digitWords[r-'0'])
+		case r == 'L':
+			words = append(words, "left")
+		case r == 'R':
+			words = append(words, "right")
+		case r == 'C':
+			words = append(words, "center")
+		}
+	}
+	return "runway " + strings.Join(words, " ")
+}
+
+// turnDirection returns "left" or "right", whichever is the shorter turn.
+func turnDirection(from, to float64) string {
+	diff := math.Mod(to-from+540, 360) - 180
+	if diff < 0 {
+		return "left"
+	}
+	return "right"
+}
+
+// Route speaks an ICAO style route: airways and procedures are spelled out,
+// DCT is "direct" and speed and level groups are left out.
+func Route(route string) string {
+	var words []string
+	for _, el := range strings.Fields(route) {
+		el, _, _ = strings.Cut(el, "/")
+		i := strings.IndexFunc(el, func(r rune) bool { return r >= '0' && r <= '9' })
+		switch {
+		case el == "DCT":
+			words = append(words, "direct")
+		case i == 1 && len(el) == 9 && (el[0] == 'N' || el[0] == 'K'):
+			// speed and level
+		case i > 0 && i <= 2 && strings.Trim(el[i:], "0123456789") == "":
+			for _, r := range el[:i] {
+				words = append(words, alphabet[r])
+			}
+			words = append(words, Spell(el[i:]))
+		case i > 2:
+			words = append(words, Procedure(el))
+		default:
+			words = append(words, el)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// Instruction phrases a single instruction given the aircraft's state.
+func Instruction(cmd command.Command, st State) string {
+	switch c := cmd.(type) {
+	case command.Heading:
+		heading := int(math.Round(c.Degrees))
+		if heading == 0 {
+			heading = 360 // north is spoken as three six zero
+		}
+		return fmt.Sprintf("turn %s heading %s", turnDirection(st.Heading, c.Degrees), Digits(heading, 3))
+	case command.Altitude:
+		switch {
+		case math.Abs(c.Feet-st.Altitude) < 50:
+			return "maintain " + Level(c.Feet)
+		case c.Feet < st.Altitude:
+			return "descend and maintain " + Level(c.Feet)
+		default:
+			return "climb and maintain " + Level(c.Feet)
+		}
+	case command.Speed:
+		knots := Digits(int(math.Round(c.Knots)), 3)
+		switch {
+		case math.Abs(c.Knots-st.Speed) < 5:
+			return "maintain speed " + knots + " knots"
+		case c.Knots < st.Speed:
+			return "reduce speed " + knots + " knots"
+		default:
+			return "increase speed " + knots + " knots"
+		}
+	case command.DirectTo:
+		return "proceed direct " + c.Waypoint
+	case command.Land:
+		return "cleared to land " + Runway(c.Runway)
+	case command.Handoff:
+		return "contact departure, good day"
+	case command.SID:
+		return "cleared " + Procedure(c.Procedure) + " departure"
+	case command.STAR:
+		return "cleared " + Procedure(c.Procedure) + " arrival"
+	case command.ClimbVia:
+		phrase := "climb via the " + Procedure(st.Procedure) + " departure"
+		if c.Feet > 0 {
+			phrase += " to " + Level(c.Feet)
+		}
+		return phrase
+	case command.DescendVia:
+		phrase := "descend via the " + Procedure(st.Procedure) + " arrival"
+		if c.Feet > 0 {
+			phrase += " to " + Level(c.Feet)
+		}
+		return phrase
+	case command.Reroute:
+		return "cleared via " + Route(c.Route)
+	case command.InsertFix:
+		if c.After == "" {
+			return "proceed via " + c.Waypoint + ", then as filed"
+		}
+		return "after " + c.After + " proceed via " + c.Waypoint + ", then as filed"
+	case command.DeleteFix:
+		return "omit " + c.Waypoint + ", rest of route unchanged"
+	case command.ResumeNavigation:
+		if st.NextFix == "" {
+			return "resume own navigation"
+		}
+		return "resume own navigation direct " + st.NextFix
+	case command.ChangeDestination:
+		return "cleared to " + c.Destination
+	case command.FrequencyChange:
+		frequency := c.Frequency
+		if frequency == "" {
+			frequency = st.Frequency
+		}
+		return fmt.Sprintf("contact %s %s, good day", st.Station, Frequency(frequency))
+	default:
+		return strings.ToLower(cmd.String())
+	}
+}
+
+// Transmission is the controller's call: callsign first.
+func Transmission(callsign string, phrases []string) string {
+	return fmt.Sprintf("%s, %s.", callsign, strings.Join(phrases, ", "))
+}
+
+// Readback is the pilot's reply: the instructions read back, callsign last.
+func Readback(callsign string, phrases []string) string {
+	text := strings.Join(phrases, ", ")
+	if text != "" {
+		text = strings.ToUpper(text[:1]) + text[1:]
+	}
+	return fmt.Sprintf("%s, %s.", text, callsign)
+}
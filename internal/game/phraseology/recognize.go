@@ -0,0 +1,316 @@
+package phraseology
+
+import (
+	"atc-simulator/internal/game/command"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Vocabulary is what a spoken instruction may name.
+type Vocabulary struct {
+	Callsigns []string // e.g. "AAL101"
+	Waypoints []string
+	Runways   []string // e.g. "RWY27"
+	Stations  []string // e.g. "Bengaluru South Approach"
+}
+
+// Grammar is a GBNF grammar of controller phraseology over the vocabulary,
+// for constraining a speech recogniser to what Recognize understands.
+func Grammar(v Vocabulary) string {
+	var b strings.Builder
+	rule := func(name string, alternatives ...string) {
+		if len(alternatives) == 0 {
+			alternatives = []string{`"-"`} // nothing to say, never matches speech
+		}
+		fmt.Fprintf(&b, "%s ::= %s\n", name, strings.Join(alternatives, " | "))
+	}
+	quoted := func(phrases []string) []string {
+		out := make([]string, len(phrases))
+		for i, p := range phrases {
+			out[i] = strconv.Quote(p)
+		}
+		return out
+	}
+
+	airlines := make([]string, 0, len(telephony))
+	for _, cs := range v.Callsigns {
+		if name, _ := splitCallsign(cs); !slices.Contains(airlines, name) {
+			airlines = append(airlines, name)
+		}
+	}
+	waypoints := make([]string, len(v.Waypoints))
+	for i, wp := range v.Waypoints {
+		waypoints[i] = strings.ToLower(wp)
+	}
+	runways := make([]string, len(v.Runways))
+	for i, rwy := range v.Runways {
+		runways[i] = Runway(rwy)
+	}
+
+	rule("root", `" "? (callsign ","? " ")? instruction (","? " " ("and " | "then ")? instruction)* "."?`)
+	rule("instruction", "heading", "level", "speed", "direct", "land", "contact", "answer")
+	rule("heading", `("turn left " | "turn right " | "fly ")? "heading " digit " " digit " " digit`)
+	rule("level", `("climb and maintain " | "descend and maintain " | "climb " | "descend " | "maintain ") altitude`)
+	rule("altitude", `"flight level " digit " " digit " " digit`, `digit (" " digit)? " thousand" (" " digit " hundred")?`, `digit " hundred"`)
+	rule("speed", `("reduce " | "increase " | "maintain ")? "speed " digit " " digit " " digit " knots"?`)
+	rule("direct", `"proceed "? "direct " "to "? waypoint`)
+	rule("land", `"cleared to land " runway`)
+	rule("contact", `"contact departure"`, `"contact " station (" " frequency)?`)
+	rule("answer", `"approved"`, `"unable"`, `"negative"`)
+	rule("frequency", `digit " " digit " " digit " decimal " digit (" " digit)? (" " digit)?`)
+	rule("callsign", `airline " " digit (" " digit)*`)
+	rule("airline", quoted(airlines)...)
+	rule("waypoint", quoted(waypoints)...)
+	rule("runway", quoted(runways)...)
+	rule("station", quoted(v.Stations)...)
+	rule("digit", quoted(append(digitWords[:9:9], "niner", "nine"))...)
+	return b.String()
+}
+
+// spokenDigits maps the ways a digit is said to the digit.
+var spokenDigits = map[string]string{
+	"zero": "0", "oh": "0", "one": "1", "two": "2", "three": "3", "tree": "3",
+	"four": "4", "five": "5", "fife": "5", "six": "6", "seven": "7",
+	"eight": "8", "nine": "9", "niner": "9",
+}
+
+// filler is said between instructions and means nothing.
+var filler = map[string]bool{"and": true, "then": true, "good": true, "day": true, "please": true}
+
+// words splits speech into lower case words, digits one word each whether
+// they were transcribed as numbers or spoken.
+func words(text string) []string {
+	text = strings.ToLower(text)
+	text = strings.NewReplacer(",", " ", ";", " ", ":", " ", "!", " ", "?", " ", "-", " ").Replace(text)
+
+	var out []string
+	for _, w := range strings.Fields(text) {
+		w = strings.TrimSuffix(w, ".")
+		if d, ok := spokenDigits[w]; ok {
+			out = append(out, d)
+			continue
+		}
+		if rest, ok := strings.CutPrefix(w, "fl"); ok && rest != "" && isNumber(rest) {
+			out = append(out, "flight", "level")
+			w = rest
+		}
+		if isNumber(strings.ReplaceAll(w, ".", "")) && w != "" {
+			for _, r := range w {
+				if r == '.' {
+					out = append(out, "decimal")
+				} else {
+					out = append(out, string(r))
+				}
+			}
+			continue
+		}
+		if w != "" {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+func isNumber(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+func isDigit(w string) bool {
+	return len(w) == 1 && w[0] >= '0' && w[0] <= '9'
+}
+
+type transcript struct {
+	words []string
+	pos   int
+}
+
+func (t *transcript) done() bool { return t.pos >= len(t.words) }
+
+// accept consumes phrase if it comes next.
+func (t *transcript) accept(phrase ...string) bool {
+	if t.pos+len(phrase) > len(t.words) || !slices.Equal(t.words[t.pos:t.pos+len(phrase)], phrase) {
+		return false
+	}
+	t.pos += len(phrase)
+	return true
+}
+
+// digits consumes up to n digits.
+func (t *transcript) digits(n int) string {
+	var s string
+	for len(s) < n && !t.done() && isDigit(t.words[t.pos]) {
+		s += t.words[t.pos]
+		t.pos++
+	}
+	return s
+}
+
+// choose consumes the longest of the spoken forms that comes next and returns
+// what it stands for.
+func (t *transcript) choose(spoken map[string]string) (string, bool) {
+	best, bestLen := "", 0
+	for phrase, name := range spoken {
+		ws := words(phrase)
+		if len(ws) > bestLen && t.pos+len(ws) <= len(t.words) && slices.Equal(t.words[t.pos:t.pos+len(ws)], ws) {
+			best, bestLen = name, len(ws)
+		}
+	}
+	t.pos += bestLen
+	return best, bestLen > 0
+}
+
+func (t *transcript) rest() string {
+	return strings.Join(t.words[t.pos:], " ")
+}
+
+// Recognize turns spoken phraseology, as transcribed by a speech recogniser,
+// into a command line such as "AAL101 H 270 A 12000". Without a callsign the
+// line applies to the selected aircraft.
+func Recognize(speech string, v Vocabulary) (string, error) {
+	t := &transcript{words: words(speech)}
+
+	callsigns := map[string]string{}
+	for _, cs := range v.Callsigns {
+		callsigns[Callsign(cs)] = cs
+	}
+	waypoints := map[string]string{}
+	for _, wp := range v.Waypoints {
+		waypoints[wp] = wp
+	}
+	runways := map[string]string{}
+	for _, rwy := range v.Runways {
+		runways[Runway(rwy)] = rwy
+		runways[strings.TrimPrefix(Runway(rwy), "runway ")] = rwy
+	}
+	stations := map[string]string{}
+	for _, st := range v.Stations {
+		stations[st] = st
+	}
+
+	var line []string
+	if cs, ok := t.choose(callsigns); ok {
+		line = append(line, cs)
+	}
+
+	var cmds []command.Command
+	for !t.done() {
+		if filler[t.words[t.pos]] {
+			t.pos++
+			continue
+		}
+		cmd, err := t.instruction(waypoints, runways, stations)
+		if err != nil {
+			return "", err
+		}
+		cmds = append(cmds, cmd)
+	}
+	if len(cmds) == 0 {
+		return "", fmt.Errorf("no instruction in %q", speech)
+	}
+	for _, c := range cmds {
+		line = append(line, c.String())
+	}
+	return strings.Join(line, " "), nil
+}
+
+func (t *transcript) instruction(waypoints, runways, stations map[string]string) (command.Command, error) {
+	start := t.pos
+	switch {
+	case t.accept("turn", "left", "heading"), t.accept("turn", "right", "heading"),
+		t.accept("fly", "heading"), t.accept("heading"):
+		if d := t.digits(3); len(d) == 3 {
+			degrees, _ := strconv.Atoi(d)
+			return command.Heading{Degrees: float64(degrees)}, nil
+		}
+
+	case t.accept("reduce", "speed"), t.accept("increase", "speed"),
+		t.accept("maintain", "speed"), t.accept("speed"):
+		if d := t.digits(3); len(d) >= 2 {
+			t.accept("knots")
+			knots, _ := strconv.Atoi(d)
+			return command.Speed{Knots: float64(knots)}, nil
+		}
+
+	case t.accept("climb", "and", "maintain"), t.accept("descend", "and", "maintain"),
+		t.accept("climb"), t.accept("descend"), t.accept("maintain"):
+		if feet, ok := t.altitude(); ok {
+			return command.Altitude{Feet: feet}, nil
+		}
+
+	case t.accept("proceed", "direct"), t.accept("direct"):
+		t.accept("to")
+		if wp, ok := t.choose(waypoints); ok {
+			return command.DirectTo{Waypoint: wp}, nil
+		}
+
+	case t.accept("cleared", "to", "land"), t.accept("cleared", "land"):
+		if rwy, ok := t.choose(runways); ok {
+			return command.Land{Runway: rwy}, nil
+		}
+
+	case t.accept("contact", "departure"):
+		return command.Handoff{}, nil
+
+	case t.accept("contact"):
+		t.choose(stations)
+		return command.FrequencyChange{Frequency: t.frequency()}, nil
+
+	case t.accept("approved"), t.accept("approve"):
+		return command.Approve{}, nil
+	case t.accept("unable"):
+		return command.Unable{}, nil
+	case t.accept("negative"), t.accept("denied"), t.accept("deny"):
+		return command.Deny{}, nil
+	}
+
+	t.pos = start
+	return nil, fmt.Errorf("did not understand %q", t.rest())
+}
+
+// altitude reads a flight level, or thousands and hundreds of feet.
+func (t *transcript) altitude() (float64, bool) {
+	if t.accept("flight", "level") {
+		d := t.digits(3)
+		level, err := strconv.Atoi(d)
+		return float64(level) * 100, err == nil && len(d) >= 2
+	}
+
+	var feet int
+	d := t.digits(2)
+	if d == "" {
+		return 0, false
+	}
+	n, _ := strconv.Atoi(d)
+	switch {
+	case t.accept("thousand"):
+		feet = n * 1000
+		if h := t.digits(1); h != "" {
+			if !t.accept("hundred") {
+				return 0, false
+			}
+			hundreds, _ := strconv.Atoi(h)
+			feet += hundreds * 100
+		}
+	case t.accept("hundred"):
+		feet = n * 100
+	default:
+		return 0, false
+	}
+	return float64(feet), true
+}
+
+// frequency reads a frequency such as "one one niner decimal five", or
+// returns "" if none was said.
+func (t *transcript) frequency() string {
+	start := t.pos
+	whole := t.digits(3)
+	if len(whole) == 3 && t.accept("decimal") {
+		if decimals := t.digits(3); decimals != "" {
+			return whole + "." + decimals
+		}
+	}
+	t.pos = start
+	return ""
+}
@@ -0,0 +1,286 @@
+package simulation
+
+import (
+	"atc-simulator/internal/game/aircraft"
+	"atc-simulator/internal/game/airspace"
+	"atc-simulator/internal/game/flightplan"
+	"atc-simulator/pkg/types"
+	"slices"
+)
+
+// checkAmendable returns an error if the route of the aircraft can no longer
+// be changed.
+func checkAmendable(ac *aircraft.Aircraft) error {
+	if ac.FlightPlan == nil || ac.State == aircraft.APPROACH || ac.State == aircraft.LANDED || ac.ClearedForLanding {
+		return reject(RejectNotReady, "%s is landing", ac.ID)
+	}
+	return nil
+}
+
+// remainingRoute returns the segments still to be flown.
+func remainingRoute(fp *flightplan.FlightPlan) []flightplan.FlightPlanSegment {
+	return fp.Route[min(fp.CurrentSegmentIndex, len(fp.Route)):]
+}
+
+// cruise returns the altitude and speed new segments are flown at: those of
+// the current segment, or the aircraft's targets once the route is flown.
+func cruise(ac *aircraft.Aircraft) (altitude, speed float64) {
+	if seg := ac.FlightPlan.CurrentSegment(); seg != nil && seg.Type == flightplan.SegmentTypeWaypoint {
+		return seg.TargetAltitude, seg.TargetSpeed
+	}
+	return ac.TargetAltitude, ac.TargetSpeed
+}
+
+// starLanding is the approach that follows a STAR.
+func starLanding(star *airspace.Procedure) flightplan.FlightPlanSegment {
+	return flightplan.FlightPlanSegment{
+		Type:           flightplan.SegmentTypeLanding,
+		AirportID:      star.AirportID,
+		RunwayName:     star.Runway,
+		TargetAltitude: 2000,
+		TargetSpeed:    225,
+	}
+}
+
+// amend returns a copy of the flight plan with the rest of the route replaced,
+// filed to where the new route ends.
+func amend(fp *flightplan.FlightPlan, rest []flightplan.FlightPlanSegment) *flightplan.FlightPlan {
+	plan := fp.Clone()
+	plan.Route = append(plan.Route[:min(plan.CurrentSegmentIndex, len(plan.Route))], rest...)
+	plan.DestinationAirportID = plan.Destination()
+	return plan
+}
+
+// checkPlan rejects an amended flight plan that does not validate against the
+// airspace.
+func (s *Simulation) checkPlan(plan *flightplan.FlightPlan) error {
+	if err := plan.Validate(s.Airspace); err != nil {
+		return reject(RejectInvalidRoute, "%v", err)
+	}
+	return nil
+}
+
+// setRoute files the aircraft on an amended flight plan. It stops flying
+// direct to a fix that is no longer next.
+func setRoute(ac *aircraft.Aircraft, plan *flightplan.FlightPlan) {
+	ac.FlightPlan = plan
+	if wp := ac.DirectToWaypoint; wp != nil {
+		if next := plan.CurrentSegment(); next == nil || next.Type != flightplan.SegmentTypeWaypoint || next.WaypointName != wp.Name {
+			ac.DirectToWaypoint = nil
+		}
+	}
+}
+
+// checkReroute returns the flight plan with the route the aircraft is to fly
+// instead of the rest of its own. It keeps the approach at the end of the old
+// route unless the new one ends in a STAR, which brings its own.
+func (s *Simulation) checkReroute(ac *aircraft.Aircraft, route string) (*flightplan.FlightPlan, error) {
+	if err := checkAmendable(ac); err != nil {
+		return nil, err
+	}
+	altitude, speed := cruise(ac)
+	segments, err := s.Airspace.ParseRoute(route, altitude, speed)
+	if err != nil {
+		return nil, reject(RejectInvalidRoute, "%v", err)
+	}
+
+	last := segments[len(segments)-1]
+	if star, ok := s.Airspace.Procedure(last.Procedure); ok && star.Kind == airspace.STAR {
+		segments = append(segments, starLanding(star))
+	} else if rest := remainingRoute(ac.FlightPlan); len(rest) > 0 && rest[len(rest)-1].Type == flightplan.SegmentTypeLanding {
+		segments = append(segments, rest[len(rest)-1])
+	}
+	plan := amend(ac.FlightPlan, segments)
+	if err := s.checkPlan(plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// Reroute clears the aircraft via an ICAO style route instead of the rest of
+// its own, see airspace.ParseRoute.
+func (s *Simulation) Reroute(aircraftID types.AircraftID, route string) error {
+	ac, err := s.controlledAircraft(aircraftID)
+	if err != nil {
+		return err
+	}
+	plan, err := s.checkReroute(ac, route)
+	if err != nil {
+		return err
+	}
+	setRoute(ac, plan)
+	ac.DirectToWaypoint = nil
+	ac.Vectored, ac.Via = false, false
+	ac.Logf("%s rerouted via %s", ac.ID, route)
+	return nil
+}
+
+// checkInsertFix returns the flight plan with waypoint in the route after the
+// fix after, or ahead of the next fix.
+func (s *Simulation) checkInsertFix(ac *aircraft.Aircraft, waypoint, after string) (*flightplan.FlightPlan, error) {
+	if err := checkAmendable(ac); err != nil {
+		return nil, err
+	}
+	if _, ok := s.Airspace.Waypoints[waypoint]; !ok {
+		return nil, reject(RejectUnknownWaypoint, "waypoint %s not found", waypoint)
+	}
+	plan := ac.FlightPlan.Clone()
+	i := min(plan.CurrentSegmentIndex, len(plan.Route))
+	if after != "" {
+		if i = plan.RouteIndex(after); i < 0 {
+			return nil, reject(RejectInvalidRoute, "%s is not on the route of %s", after, ac.ID)
+		}
+		i++
+	}
+	plan.Insert(i, waypoint)
+	plan.DestinationAirportID = plan.Destination()
+	if err := s.checkPlan(plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// InsertFix adds a waypoint to the route after the fix after, or as the next
+// fix when after is empty.
+func (s *Simulation) InsertFix(aircraftID types.AircraftID, waypoint, after string) error {
+	ac, err := s.controlledAircraft(aircraftID)
+	if err != nil {
+		return err
+	}
+	plan, err := s.checkInsertFix(ac, waypoint, after)
+	if err != nil {
+		return err
+	}
+	setRoute(ac, plan)
+	ac.Logf("%s route amended, %s inserted", ac.ID, waypoint)
+	return nil
+}
+
+// checkDeleteFix returns the flight plan with a fix taken out of the route.
+// The last fix is where the aircraft is going and stays.
+func (s *Simulation) checkDeleteFix(ac *aircraft.Aircraft, waypoint string) (*flightplan.FlightPlan, error) {
+	if err := checkAmendable(ac); err != nil {
+		return nil, err
+	}
+	i := ac.FlightPlan.RouteIndex(waypoint)
+	switch {
+	case i < 0:
+		return nil, reject(RejectInvalidRoute, "%s is not on the route of %s", waypoint, ac.ID)
+	case i == len(ac.FlightPlan.Route)-1:
+		return nil, reject(RejectInvalidRoute, "%s is the destination of %s", waypoint, ac.ID)
+	}
+	plan := ac.FlightPlan.Clone()
+	plan.Route = slices.Delete(plan.Route, i, i+1)
+	if err := s.checkPlan(plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// DeleteFix takes a fix out of the route.
+func (s *Simulation) DeleteFix(aircraftID types.AircraftID, waypoint string) error {
+	ac, err := s.controlledAircraft(aircraftID)
+	if err != nil {
+		return err
+	}
+	plan, err := s.checkDeleteFix(ac, waypoint)
+	if err != nil {
+		return err
+	}
+	setRoute(ac, plan)
+	ac.Logf("%s route amended, %s deleted", ac.ID, waypoint)
+	return nil
+}
+
+// ResumeNavigation ends vectors: the aircraft turns back to the next fix of
+// its route.
+func (s *Simulation) ResumeNavigation(aircraftID types.AircraftID) error {
+	ac, err := s.controlledAircraft(aircraftID)
+	if err != nil {
+		return err
+	}
+	if ac.FlightPlan.CurrentSegment() == nil {
+		return reject(RejectNotReady, "%s has no route to resume", ac.ID)
+	}
+	ac.Vectored = false
+	ac.DirectToWaypoint = nil
+	return nil
+}
+
+// checkDestination returns the flight plan to a new destination. For an
+// airport the arrival procedures of the old route are dropped and an approach
+// to the runway in use, or the runway of a STAR to it, is added; for a fix
+// the approach is dropped and the fix added.
+func (s *Simulation) checkDestination(ac *aircraft.Aircraft, destination string) (*flightplan.FlightPlan, error) {
+	if err := checkAmendable(ac); err != nil {
+		return nil, err
+	}
+	airport, isAirport := s.Airspace.Airports[destination]
+	if _, isFix := s.Airspace.Waypoints[destination]; !isAirport && !isFix {
+		return nil, reject(RejectUnknownWaypoint, "destination %s not found", destination)
+	}
+
+	var rest []flightplan.FlightPlanSegment
+	var star *airspace.Procedure
+	for _, seg := range remainingRoute(ac.FlightPlan) {
+		if seg.Type == flightplan.SegmentTypeLanding {
+			continue
+		}
+		if p, ok := s.Airspace.Procedure(seg.Procedure); ok && p.Kind == airspace.STAR {
+			if !isAirport || p.AirportID != destination {
+				continue
+			}
+			star = p
+		}
+		rest = append(rest, seg)
+	}
+
+	altitude, speed := cruise(ac)
+	if len(rest) > 0 {
+		altitude, speed = rest[len(rest)-1].TargetAltitude, rest[len(rest)-1].TargetSpeed
+	}
+	switch {
+	case star != nil:
+		rest = append(rest, starLanding(star))
+	case isAirport:
+		rwy := s.activeRunway(airport)
+		if rwy == nil {
+			return nil, reject(RejectUnknownRunway, "%s has no runway", destination)
+		}
+		rest = append(rest, flightplan.FlightPlanSegment{
+			Type:           flightplan.SegmentTypeLanding,
+			AirportID:      airport.ID,
+			RunwayName:     rwy.Name,
+			TargetAltitude: 2000,
+			TargetSpeed:    225,
+		})
+	case len(rest) == 0 || rest[len(rest)-1].WaypointName != destination:
+		rest = append(rest, flightplan.FlightPlanSegment{
+			Type:           flightplan.SegmentTypeWaypoint,
+			WaypointName:   destination,
+			TargetAltitude: altitude,
+			TargetSpeed:    speed,
+		})
+	}
+	plan := amend(ac.FlightPlan, rest)
+	if err := s.checkPlan(plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// ChangeDestination files the aircraft to land at an airport or to leave the
+// airspace by a fix.
+func (s *Simulation) ChangeDestination(aircraftID types.AircraftID, destination string) error {
+	ac, err := s.controlledAircraft(aircraftID)
+	if err != nil {
+		return err
+	}
+	plan, err := s.checkDestination(ac, destination)
+	if err != nil {
+		return err
+	}
+	setRoute(ac, plan)
+	ac.Logf("%s destination changed to %s", ac.ID, destination)
+	return nil
+}
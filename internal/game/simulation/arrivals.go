@@ -0,0 +1,139 @@
+package simulation
+
+import (
+	"atc-simulator/internal/game/aircraft"
+	"atc-simulator/internal/game/flightplan"
+	"atc-simulator/pkg/types"
+	"cmp"
+	"maps"
+	"math"
+	"slices"
+)
+
+const (
+	arrivalSpacingNM      = 5.0      // between landings on one runway
+	minimumDelaySeconds   = 15.0     // less is not worth advising
+	minimumCleanSpeed     = 210.0    // knots, the slowest an arrival is slowed to
+	speedAdvisoryStep     = 10.0     // knots, advised speeds are rounded down to it
+	maximumVectorSeconds  = 5 * 60.0 // more is lost in a hold
+	holdingPatternSeconds = 4 * 60.0 // one lap of a holding pattern
+)
+
+// Advice is how an arrival is to lose the time the arrival manager asks of it.
+type Advice int
+
+const (
+	AdviceNone Advice = iota
+	AdviceSpeed
+	AdviceVectors
+	AdviceHold
+)
+
+var AdviceStringMap = map[Advice]string{
+	AdviceNone:    "NONE",
+	AdviceSpeed:   "SPEED",
+	AdviceVectors: "VECTORS",
+	AdviceHold:    "HOLD",
+}
+
+// Arrival is an aircraft in the landing sequence of a runway. Times are game
+// seconds.
+type Arrival struct {
+	Callsign      types.AircraftID
+	Sequence      int     // 1 lands first
+	EstimatedTime float64 // as predicted, see Trajectories
+	ScheduledTime float64 // spaced behind the arrival before
+	Delay         float64 // to lose, ScheduledTime less EstimatedTime
+
+	Advice Advice
+	Speed  float64 `json:",omitempty"` // for AdviceSpeed, knots
+	Laps   int     `json:",omitempty"` // for AdviceHold
+}
+
+// ArrivalSequence is the order arrivals land on a runway in.
+type ArrivalSequence struct {
+	AirportID string
+	Runway    string
+	Arrivals  []Arrival
+}
+
+// sequenceArrivals schedules the landings on every runway from the predicted
+// trajectories: first come first served by estimated landing time, each
+// arrival at least arrivalSpacingNM behind the one before at its approach
+// speed. Arrivals not predicted to land within the prediction horizon are
+// left out until they are.
+func (s *Simulation) sequenceArrivals() {
+	byRunway := make(map[[2]string][]Arrival)
+	for _, id := range s.aircraftIDs() {
+		ac := s.Aircrafts[id]
+		seg := landingSegment(ac)
+		tr, ok := s.Trajectories[id]
+		if seg == nil || !ok {
+			continue
+		}
+		eta, ok := tr.ETA(seg.RunwayName)
+		if !ok {
+			continue
+		}
+		key := [2]string{seg.AirportID, seg.RunwayName}
+		byRunway[key] = append(byRunway[key], Arrival{Callsign: id, EstimatedTime: eta})
+	}
+
+	s.ArrivalSequences = nil
+	for _, key := range slices.SortedFunc(maps.Keys(byRunway), func(a, b [2]string) int {
+		return cmp.Or(cmp.Compare(a[0], b[0]), cmp.Compare(a[1], b[1]))
+	}) {
+		arrivals := byRunway[key]
+		slices.SortStableFunc(arrivals, func(a, b Arrival) int {
+			return cmp.Compare(a.EstimatedTime, b.EstimatedTime)
+		})
+		for i := range arrivals {
+			a := &arrivals[i]
+			a.Sequence = i + 1
+			a.ScheduledTime = a.EstimatedTime
+			if i > 0 {
+				spacing := arrivalSpacingNM / landingSegment(s.Aircrafts[a.Callsign]).TargetSpeed * 3600
+				a.ScheduledTime = max(a.EstimatedTime, arrivals[i-1].ScheduledTime+spacing)
+			}
+			a.Delay = a.ScheduledTime - a.EstimatedTime
+			s.adviseDelay(a, s.Aircrafts[a.Callsign])
+		}
+		s.ArrivalSequences = append(s.ArrivalSequences, ArrivalSequence{AirportID: key[0], Runway: key[1], Arrivals: arrivals})
+	}
+}
+
+// adviseDelay picks the least disruptive way for the aircraft to lose its
+// delay: slowing down if it is far enough out for that to be enough, else
+// vectors to stretch its path, else holding.
+func (s *Simulation) adviseDelay(a *Arrival, ac *aircraft.Aircraft) {
+	if a.Delay < minimumDelaySeconds {
+		return
+	}
+	remaining := a.EstimatedTime - s.GameTimeSeconds
+	if remaining > 0 && ac.Speed > minimumCleanSpeed {
+		// Flying the rest at speed v' instead of v takes remaining*v/v'.
+		speed := ac.Speed * remaining / (remaining + a.Delay)
+		speed = math.Floor(speed/speedAdvisoryStep) * speedAdvisoryStep
+		if speed >= minimumCleanSpeed {
+			a.Advice, a.Speed = AdviceSpeed, speed
+			return
+		}
+	}
+	if a.Delay <= maximumVectorSeconds {
+		a.Advice = AdviceVectors
+		return
+	}
+	a.Advice, a.Laps = AdviceHold, int(math.Ceil(a.Delay/holdingPatternSeconds))
+}
+
+// landingSegment returns the approach the aircraft has still to fly, or nil.
+func landingSegment(ac *aircraft.Aircraft) *flightplan.FlightPlanSegment {
+	fp := ac.FlightPlan
+	if fp == nil || ac.State == aircraft.LANDED || len(fp.Route) == 0 || fp.CurrentSegmentIndex >= len(fp.Route) {
+		return nil
+	}
+	if last := &fp.Route[len(fp.Route)-1]; last.Type == flightplan.SegmentTypeLanding {
+		return last
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package simulation
+
+import (
+	"atc-simulator/internal/game/aircraft"
+	"atc-simulator/internal/game/airspace"
+	"atc-simulator/internal/game/command"
+	"atc-simulator/pkg/types"
+	"fmt"
+)
+
+// ExecuteCommand parses a controller command line and applies it on behalf of
+// a controller working the given positions. Lines that do not start with a
+// callsign apply to the selected aircraft. A line may carry several
+// instructions; none are applied if any names an unknown waypoint or is not
+// the controller's to issue. Instructions that take effect at once rather
+// than after a readback, such as ACPT, PO or DENY, can still fail as they
+// run, after those before them were applied.
+//
+// Clearances are transmitted straight away and carried out after the pilot
+// reads them back, see transmitClearance. Instructions the addressed aircraft
+// cannot follow are answered with a "say again" or "unable" instead.
+func (s *Simulation) ExecuteCommand(positions []string, selected types.AircraftID, cmd string) error {
+	s.recordEvent(EventCommand, positions, selected, cmd)
+
+	line, err := command.Parse(cmd)
+	if err != nil {
+		if ac := s.addressedAircraft(positions, selected, cmd); ac != nil {
+			s.respondToRejection(ac, err)
+		}
+		return err
+	}
+
+	aircraftID := line.Callsign
+	if aircraftID == "" {
+		if selected == "" {
+			return reject(RejectUnknownAircraft, "no aircraft selected")
+		}
+		aircraftID = selected
+	}
+	ac, exists := s.Aircrafts[aircraftID]
+	if !exists {
+		return command.Wrap(line.CallsignPos, reject(RejectUnknownAircraft, "aircraft %s not found", aircraftID))
+	}
+
+	for _, c := range line.Commands {
+		if err := s.checkAuthority(positions, ac, c.Name()); err != nil {
+			return command.Wrap(c.Pos(), err)
+		}
+		if err := s.validateClearance(ac, c); err != nil {
+			s.respondToRejection(ac, err)
+			return command.Wrap(c.Pos(), err)
+		}
+	}
+
+	// Coordination takes effect at once, clearances once the pilot has read
+	// them back.
+	var clearances []command.Command
+	for _, c := range line.Commands {
+		if isClearance(c) {
+			clearances = append(clearances, c)
+			continue
+		}
+		if err := s.executeInstruction(positions, ac, c); err != nil {
+			return command.Wrap(c.Pos(), err)
+		}
+	}
+	if len(clearances) > 0 {
+		s.transmitClearance(ac, clearances)
+	}
+	return nil
+}
+
+// addressedAircraft guesses which aircraft a line that failed to parse was
+// meant for, so it can ask for a repeat. Aircraft on other controllers'
+// frequencies never hear it.
+func (s *Simulation) addressedAircraft(positions []string, selected types.AircraftID, cmd string) *aircraft.Aircraft {
+	ac, ok := s.Aircrafts[selected]
+	if tokens := command.Tokenize(cmd); len(tokens) > 0 {
+		if named, found := s.Aircrafts[types.AircraftID(tokens[0].Text)]; found {
+			ac, ok = named, true
+		}
+	}
+	if !ok || s.checkAuthority(positions, ac, "") != nil {
+		return nil
+	}
+	return ac
+}
+
+func (s *Simulation) executeInstruction(positions []string, ac *aircraft.Aircraft, cmd command.Command) error {
+	aircraftID := ac.ID
+
+	switch c := cmd.(type) {
+	case command.Heading:
+		if err := s.IssueHeading(aircraftID, c.Degrees); err != nil {
+			return err
+		}
+		ac.Logf("Issued H %.0f to %s", c.Degrees, aircraftID)
+	case command.Altitude:
+		if err := s.IssueAltitude(aircraftID, c.Feet); err != nil {
+			return err
+		}
+		ac.Logf("Issued A %.0f to %s", c.Feet, aircraftID)
+	case command.Speed:
+		if err := s.IssueSpeed(aircraftID, c.Knots); err != nil {
+			return err
+		}
+		ac.Logf("Issued S %.0f to %s", c.Knots, aircraftID)
+	case command.DirectTo:
+		wp, ok := s.Airspace.Waypoints[c.Waypoint]
+		if !ok {
+			return reject(RejectUnknownWaypoint, "waypoint %s not found", c.Waypoint)
+		}
+		if err := s.IssueDirectTo(aircraftID, wp); err != nil {
+			return err
+		}
+		ac.Logf("Issued D %s to %s", c.Waypoint, aircraftID)
+	case command.Handoff:
+		if err := s.ClearHandoff(aircraftID); err != nil {
+			return err
+		}
+		ac.Logf("ATC issued HANDOFF to %s", aircraftID)
+	case command.Land:
+		if err := s.ClearLanding(aircraftID, c.Runway); err != nil {
+			return err
+		}
+		ac.Logf("ATC issued LANDING clearance to %s for %s", aircraftID, c.Runway)
+	case command.SID:
+		return s.AssignProcedure(aircraftID, airspace.SID, c.Procedure)
+	case command.STAR:
+		return s.AssignProcedure(aircraftID, airspace.STAR, c.Procedure)
+	case command.ClimbVia:
+		if err := s.ClearVia(aircraftID, airspace.SID, c.Feet); err != nil {
+			return err
+		}
+		ac.Logf("Issued CVIA to %s", aircraftID)
+	case command.DescendVia:
+		if err := s.ClearVia(aircraftID, airspace.STAR, c.Feet); err != nil {
+			return err
+		}
+		ac.Logf("Issued DVIA to %s", aircraftID)
+	case command.Reroute:
+		return s.Reroute(aircraftID, c.Route)
+	case command.InsertFix:
+		return s.InsertFix(aircraftID, c.Waypoint, c.After)
+	case command.DeleteFix:
+		return s.DeleteFix(aircraftID, c.Waypoint)
+	case command.ResumeNavigation:
+		if err := s.ResumeNavigation(aircraftID); err != nil {
+			return err
+		}
+		ac.Logf("Issued RON to %s", aircraftID)
+	case command.ChangeDestination:
+		return s.ChangeDestination(aircraftID, c.Destination)
+	case command.AcceptHandoff:
+		return s.AcceptHandoff(aircraftID)
+	case command.RejectHandoff:
+		return s.RejectHandoff(aircraftID)
+	case command.FrequencyChange:
+		return s.IssueFrequencyChange(aircraftID, c.Frequency)
+	case command.PointOut:
+		return s.PointOutAircraft(aircraftID, c.Sector)
+	case command.AckPointOut:
+		return s.AcknowledgePointOut(aircraftID, positions)
+	case command.Unable:
+		return s.AnswerRequest(aircraftID, RequestUnable)
+	case command.Deny:
+		return s.AnswerRequest(aircraftID, RequestDenied)
+	default:
+		return fmt.Errorf("unknown command type: %s", cmd.Name())
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package simulation
+
+import (
+	"atc-simulator/internal/game/aircraft"
+	"atc-simulator/internal/game/airspace"
+	"atc-simulator/internal/game/flightplan"
+	"atc-simulator/pkg/types"
+	"log"
+	"slices"
+)
+
+const (
+	runwaySpacingSeconds    = 60.0     // between takeoffs, for the runway to clear
+	sameRouteSpacing        = 2 * 60.0 // between departures out by the same fix
+	arrivalGapBeforeSeconds = 60.0     // no takeoff this long before a landing
+	arrivalGapAfterSeconds  = 45.0     // or this long after it
+	recentTakeoffSeconds    = 5 * 60.0 // takeoffs kept for spacing
+	takeoffSpeed            = 160.0    // knots
+	departureClimbSpeed     = 250.0    // knots
+)
+
+// wakeSpacing is the time a departure waits behind one of a heavier wake
+// category taking off from the same runway, in seconds, by leader and then
+// follower.
+var wakeSpacing = map[aircraft.WakeCategory]map[aircraft.WakeCategory]float64{
+	aircraft.WakeSuper:  {aircraft.WakeHeavy: 2 * 60, aircraft.WakeMedium: 3 * 60, aircraft.WakeLight: 3 * 60},
+	aircraft.WakeHeavy:  {aircraft.WakeMedium: 2 * 60, aircraft.WakeLight: 2 * 60},
+	aircraft.WakeMedium: {aircraft.WakeLight: 2 * 60},
+}
+
+// Departure is an aircraft waiting at the holding point of a runway for its
+// takeoff slot. Times are game seconds.
+type Departure struct {
+	Callsign  types.AircraftID
+	AirportID string
+	Runway    string
+	SID       string
+	Type      string
+	Wake      aircraft.WakeCategory
+	Cruise    float64 // feet, the level it climbs via the SID to
+
+	Sequence  int     // 1 takes off next from the runway
+	ReadyTime float64 // reached the holding point
+	SlotTime  float64 // cleared for takeoff
+	Delay     float64 // waited, SlotTime less ReadyTime
+}
+
+// spawnDeparture taxies an aircraft of the fleet type to the holding point of
+// the runway in use at the airport, filed to climb via one of its SIDs to a
+// random level. It takes off once the departure manager gives it a slot, see
+// updateDepartures. It reports false if there is no SID to fly.
+func (s *Simulation) spawnDeparture(acID types.AircraftID, airportID string, ft FleetType) bool {
+	airport, ok := s.Airspace.Airports[airportID]
+	if !ok {
+		return false
+	}
+	rwy := s.activeRunway(airport)
+	if rwy == nil {
+		return false
+	}
+	sids := airport.ProceduresFor(airspace.SID, rwy.Name)
+	if len(sids) == 0 {
+		return false
+	}
+	sid := sids[s.rng.IntN(len(sids))]
+	cruise := (float64(s.rng.IntN(15)) + 10) * 1000.0 // 10,000 to 24,000 ft
+
+	wake, _ := wakeCategory(ft.Wake)
+	dep := &Departure{
+		Callsign:  acID,
+		AirportID: airport.ID,
+		Runway:    rwy.Name,
+		SID:       sid.Name,
+		Type:      ft.Type,
+		Wake:      wake,
+		Cruise:    cruise,
+		ReadyTime: s.GameTimeSeconds,
+	}
+	if _, err := s.departureFlightPlan(dep); err != nil {
+		log.Printf("WARNING: not spawning departure %s, invalid flight plan: %v", acID, err)
+		return false
+	}
+	s.DepartureQueue = append(s.DepartureQueue, dep)
+	s.allocateSlots()
+	log.Printf("DEPARTURE: %s ready at %s %s for the %s, slot %.0f", acID, airport.ID, rwy.Name, sid.Name, dep.SlotTime)
+	return true
+}
+
+// departureFlightPlan is the plan a departure files: its SID, climbing to its
+// cruising level.
+func (s *Simulation) departureFlightPlan(dep *Departure) (*flightplan.FlightPlan, error) {
+	sid, ok := s.Airspace.Procedure(dep.SID)
+	if !ok {
+		return nil, reject(RejectUnknownProcedure, "SID %s not found", dep.SID)
+	}
+	route := sid.Segments(0, dep.Cruise, departureClimbSpeed)
+	flightPlan := &flightplan.FlightPlan{
+		OriginAirportID:      dep.AirportID,
+		DestinationAirportID: route[len(route)-1].WaypointName,
+		Callsign:             dep.Callsign,
+		Route:                route,
+	}
+	if err := flightPlan.Validate(s.Airspace); err != nil {
+		return nil, err
+	}
+	return flightPlan, nil
+}
+
+// updateDepartures hands out takeoff slots and lines up every departure whose
+// slot has come.
+func (s *Simulation) updateDepartures() {
+	s.recentTakeoffs = slices.DeleteFunc(s.recentTakeoffs, func(d Departure) bool {
+		return d.SlotTime < s.GameTimeSeconds-recentTakeoffSeconds
+	})
+	s.allocateSlots()
+
+	var waiting []*Departure
+	for _, dep := range s.DepartureQueue {
+		if dep.SlotTime > s.GameTimeSeconds || !s.takeOff(dep) {
+			waiting = append(waiting, dep)
+		}
+	}
+	if len(waiting) < len(s.DepartureQueue) {
+		s.DepartureQueue = waiting
+		s.allocateSlots()
+	}
+}
+
+// allocateSlots schedules the takeoffs from every runway, first come first
+// served. A departure waits behind each recent or earlier departure from its
+// runway for the runway to clear, for wake turbulence and, out by the same
+// fix, for the route to separate them. Its slot is then moved past any
+// landing scheduled on the runway by the arrival manager.
+func (s *Simulation) allocateSlots() {
+	sequence := make(map[[2]string]int)
+	var scheduled []Departure
+	for _, dep := range s.DepartureQueue {
+		key := [2]string{dep.AirportID, dep.Runway}
+		sequence[key]++
+		dep.Sequence = sequence[key]
+
+		slot := max(dep.ReadyTime, s.GameTimeSeconds)
+		for moved := true; moved; {
+			moved = false
+			for _, leader := range slices.Concat(s.recentTakeoffs, scheduled) {
+				if leader.AirportID == dep.AirportID && leader.Runway == dep.Runway {
+					slot = max(slot, leader.SlotTime+s.departureSpacing(leader, *dep))
+				}
+			}
+			for _, seq := range s.ArrivalSequences {
+				if seq.AirportID != dep.AirportID || seq.Runway != dep.Runway {
+					continue
+				}
+				for _, a := range seq.Arrivals {
+					if slot > a.ScheduledTime-arrivalGapBeforeSeconds && slot < a.ScheduledTime+arrivalGapAfterSeconds {
+						slot, moved = a.ScheduledTime+arrivalGapAfterSeconds, true
+					}
+				}
+			}
+		}
+		dep.SlotTime = slot
+		dep.Delay = slot - dep.ReadyTime
+		scheduled = append(scheduled, *dep)
+	}
+}
+
+// departureSpacing is how long a departure waits behind the takeoff of
+// another from the same runway.
+func (s *Simulation) departureSpacing(leader, follower Departure) float64 {
+	spacing := max(runwaySpacingSeconds, wakeSpacing[leader.Wake][follower.Wake])
+	if s.sidExit(leader.SID) == s.sidExit(follower.SID) {
+		spacing = max(spacing, sameRouteSpacing)
+	}
+	return spacing
+}
+
+// sidExit is the fix a SID ends at.
+func (s *Simulation) sidExit(name string) string {
+	if sid, ok := s.Airspace.Procedure(name); ok && len(sid.Fixes) > 0 {
+		return sid.Fixes[len(sid.Fixes)-1].Waypoint
+	}
+	return name
+}
+
+// takeOff lines a departure up on its runway, cleared to climb via its SID,
+// and records its delay. It reports false if the runway is gone or the plan
+// no longer valid, in which case it keeps waiting.
+func (s *Simulation) takeOff(dep *Departure) bool {
+	airport, ok := s.Airspace.Airports[dep.AirportID]
+	if !ok || airport.Runways[dep.Runway] == nil {
+		return false
+	}
+	rwy := airport.Runways[dep.Runway]
+	flightPlan, err := s.departureFlightPlan(dep)
+	if err != nil {
+		log.Printf("WARNING: holding departure %s, invalid flight plan: %v", dep.Callsign, err)
+		return false
+	}
+
+	ac := aircraft.NewAircraft(dep.Callsign, rwy.Threshold, rwy.Heading, takeoffSpeed, 0, aircraft.TAKING_OFF, flightPlan, s.Airspace, s.AddRadioMessage)
+	ac.Via, ac.ViaAltitude = true, dep.Cruise
+	ac.Type, ac.Wake = dep.Type, dep.Wake
+	if sec := s.Airspace.SectorAt(rwy.Threshold, 0); sec != nil {
+		s.assignSector(ac, sec)
+	}
+	s.Aircrafts[dep.Callsign] = ac
+
+	dep.Delay = s.GameTimeSeconds - dep.ReadyTime
+	s.Departures++
+	s.DepartureDelaySeconds += dep.Delay
+	s.MaxDepartureDelaySeconds = max(s.MaxDepartureDelaySeconds, dep.Delay)
+	taken := *dep
+	taken.SlotTime = s.GameTimeSeconds
+	s.recentTakeoffs = append(s.recentTakeoffs, taken)
+	log.Printf("Spawned departure %s off %s %s on the %s, climbing to %.0f, after %.0fs at the holding point", ac.ID, dep.AirportID, dep.Runway, dep.SID, dep.Cruise, dep.Delay)
+	return true
+}
+
+// DepartureDelay returns the mean and longest wait at the holding point of
+// the departures that have taken off, in seconds.
+func (s *Simulation) DepartureDelay() (mean, longest float64) {
+	if s.Departures == 0 {
+		return 0, 0
+	}
+	return s.DepartureDelaySeconds / float64(s.Departures), s.MaxDepartureDelaySeconds
+}
@@ -0,0 +1,143 @@
+package simulation
+
+import (
+	"atc-simulator/pkg/types"
+	"strings"
+)
+
+// Frequencies carry one speaker at a time. Pilot calls wait for a quiet
+// frequency; two pilots keying up together, or the controller keying up over
+// a pilot, block each other and have to be repeated.
+const (
+	speechWordsPerSecond = 3.0
+	keyingSeconds        = 0.5
+	collisionProbability = 0.2 // that a second waiting pilot keys up at the same moment
+)
+
+// RadioCall is a pilot transmission waiting for the frequency.
+type RadioCall struct {
+	Frequency string
+	Callsign  types.AircraftID
+	Message   string
+	IsUrgent  bool
+	QueuedAt  float64
+	NotBefore float64 // backoff after being blocked
+
+	Clearance *PendingClearance `json:",omitempty"` // read back by this call
+}
+
+// Channel is the state of one frequency.
+type Channel struct {
+	BusyUntil float64
+	Speaker   types.AircraftID
+	Call      *RadioCall `json:",omitempty"` // the pilot call on air, repeated if stepped on
+}
+
+func transmissionSeconds(message string) float64 {
+	return keyingSeconds + float64(len(strings.Fields(message)))/speechWordsPerSecond
+}
+
+func (s *Simulation) channel(frequency string) *Channel {
+	ch, ok := s.Channels[frequency]
+	if !ok {
+		ch = &Channel{}
+		s.Channels[frequency] = ch
+	}
+	return ch
+}
+
+// FrequencyBusy reports whether someone is transmitting on the frequency.
+func (s *Simulation) FrequencyBusy(frequency string) bool {
+	ch, ok := s.Channels[frequency]
+	return ok && ch.BusyUntil > s.GameTimeSeconds
+}
+
+// backoff is how long a blocked pilot waits before trying again.
+func (s *Simulation) backoff() float64 {
+	return 1 + 2*s.rng.Float64()
+}
+
+// transmitATC puts a controller call on the air. A call over a pilot blocks
+// both: the controller's is lost and the pilot says theirs again.
+func (s *Simulation) transmitATC(frequency, message string, isUrgent bool) bool {
+	ch := s.channel(frequency)
+	end := s.GameTimeSeconds + transmissionSeconds(message)
+
+	if ch.BusyUntil > s.GameTimeSeconds && ch.Speaker != "ATC" {
+		s.transmit(frequency, "ATC", message, isUrgent, true)
+		if ch.Call != nil {
+			retry := *ch.Call
+			retry.Clearance = nil // already being carried out
+			retry.NotBefore = max(end, ch.BusyUntil) + s.backoff()
+			s.RadioQueue = append(s.RadioQueue, &retry)
+		}
+		ch.BusyUntil, ch.Speaker, ch.Call = max(end, ch.BusyUntil), "ATC", nil
+		return false
+	}
+
+	s.transmit(frequency, "ATC", message, isUrgent, false)
+	ch.BusyUntil, ch.Speaker, ch.Call = max(end, ch.BusyUntil), "ATC", nil
+	return true
+}
+
+// updateRadio lets waiting pilots talk on frequencies that have gone quiet,
+// first come first served.
+func (s *Simulation) updateRadio() {
+	var frequencies []string
+	waiting := map[string][]*RadioCall{}
+	queue := s.RadioQueue[:0]
+	for _, call := range s.RadioQueue {
+		if _, ok := s.Aircrafts[call.Callsign]; !ok {
+			continue
+		}
+		queue = append(queue, call)
+		if call.Frequency == "" {
+			// Queued before the aircraft was tuned, on spawn.
+			call.Frequency = s.frequencyOf(call.Callsign)
+		}
+		if call.NotBefore > s.GameTimeSeconds || s.FrequencyBusy(call.Frequency) {
+			continue
+		}
+		if _, ok := waiting[call.Frequency]; !ok {
+			frequencies = append(frequencies, call.Frequency)
+		}
+		waiting[call.Frequency] = append(waiting[call.Frequency], call)
+	}
+	s.RadioQueue = queue
+
+	sent := map[*RadioCall]bool{}
+	for _, frequency := range frequencies {
+		calls := waiting[frequency]
+		first := calls[0]
+		ch := s.channel(frequency)
+
+		if len(calls) > 1 && s.rng.Float64() < collisionProbability {
+			second := calls[1]
+			s.transmit(frequency, first.Callsign, first.Message, first.IsUrgent, true)
+			s.transmit(frequency, second.Callsign, second.Message, second.IsUrgent, true)
+			ch.BusyUntil = s.GameTimeSeconds + max(transmissionSeconds(first.Message), transmissionSeconds(second.Message))
+			ch.Speaker, ch.Call = "", nil
+			first.NotBefore = ch.BusyUntil + s.backoff()
+			second.NotBefore = ch.BusyUntil + s.backoff()
+			continue
+		}
+
+		s.transmit(frequency, first.Callsign, first.Message, first.IsUrgent, false)
+		ch.BusyUntil = s.GameTimeSeconds + transmissionSeconds(first.Message)
+		ch.Speaker, ch.Call = first.Callsign, first
+		sent[first] = true
+		if first.Clearance != nil {
+			s.carryOut(s.Aircrafts[first.Callsign], first.Clearance)
+		}
+	}
+
+	if len(sent) > 0 {
+		queue := s.RadioQueue[:0]
+		for _, call := range s.RadioQueue {
+			if !sent[call] {
+				queue = append(queue, call)
+			}
+		}
+		s.RadioQueue = queue
+	}
+}
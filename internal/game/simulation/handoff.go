@@ -0,0 +1,335 @@
+package simulation
+
+import (
+	"atc-simulator/internal/game/aircraft"
+	"atc-simulator/internal/game/airspace"
+	"atc-simulator/internal/game/command"
+	"atc-simulator/internal/game/phraseology"
+	"atc-simulator/pkg/types"
+	"fmt"
+	"log"
+	"slices"
+)
+
+type HandoffState int
+
+const (
+	HandoffOffered HandoffState = iota
+	HandoffAccepted
+	HandoffRejected
+)
+
+var HandoffStateStringMap = map[HandoffState]string{
+	HandoffOffered:  "OFFERED",
+	HandoffAccepted: "ACCEPTED",
+	HandoffRejected: "REJECTED",
+}
+
+// HandoffOffer tracks the coordination of an aircraft between two sectors.
+// The aircraft stays under FromSector's control until the offer is accepted
+// and the transferring controller has issued the frequency change.
+type HandoffOffer struct {
+	Callsign   types.AircraftID
+	FromSector string
+	ToSector   string
+	State      HandoffState
+	OfferedAt  float64 // game time in seconds
+	UpdatedAt  float64
+}
+
+type PointOut struct {
+	Callsign     types.AircraftID
+	FromSector   string
+	ToSector     string
+	Acknowledged bool
+	IssuedAt     float64
+}
+
+// StaffPosition marks a controller position as worked by a human controller.
+// Sectors owned by unstaffed positions are run by the simulation itself.
+func (s *Simulation) StaffPosition(positionID string) error {
+	if _, ok := s.Airspace.Positions[positionID]; !ok {
+		return fmt.Errorf("controller position %s not found", positionID)
+	}
+	s.recordEvent(EventStaff, nil, "", positionID)
+	s.staffedPositions[positionID] = true
+	return nil
+}
+
+// UnstaffPosition hands a controller position back to the simulation.
+func (s *Simulation) UnstaffPosition(positionID string) {
+	s.recordEvent(EventUnstaff, nil, "", positionID)
+	delete(s.staffedPositions, positionID)
+}
+
+func (s *Simulation) IsPositionStaffed(positionID string) bool {
+	return s.staffedPositions[positionID]
+}
+
+func (s *Simulation) IsSectorStaffed(sectorName string) bool {
+	sec, ok := s.Airspace.Sectors[sectorName]
+	return ok && s.staffedPositions[sec.Controller]
+}
+
+// SectorOwnedBy reports whether the sector belongs to one of the given positions.
+func (s *Simulation) SectorOwnedBy(sectorName string, positions []string) bool {
+	sec, ok := s.Airspace.Sectors[sectorName]
+	return ok && slices.Contains(positions, sec.Controller)
+}
+
+// checkAuthority returns an error if a controller working the given positions
+// may not issue commandType for the aircraft.
+func (s *Simulation) checkAuthority(positions []string, ac *aircraft.Aircraft, commandType string) error {
+	switch commandType {
+	case "ACPT", "RJCT":
+		if offer, ok := s.HandoffOffers[ac.ID]; ok && !s.SectorOwnedBy(offer.ToSector, positions) {
+			return fmt.Errorf("handoff of %s is for %s, which you do not control", ac.ID, offer.ToSector)
+		}
+		return nil
+	case "ACK":
+		return nil
+	}
+
+	if ac.ControllingSector != "" && !s.SectorOwnedBy(ac.ControllingSector, positions) {
+		return reject(RejectNotInControl, "%s is under %s control", ac.ID, ac.ControllingSector)
+	}
+	return nil
+}
+
+// checkControl returns an error if the aircraft is worked by a sector that is
+// not staffed by the local controller.
+func (s *Simulation) checkControl(ac *aircraft.Aircraft) error {
+	if ac.ControllingSector == "" || s.IsSectorStaffed(ac.ControllingSector) {
+		return nil
+	}
+	return reject(RejectNotInControl, "%s is under %s control", ac.ID, ac.ControllingSector)
+}
+
+func (s *Simulation) updateHandoffs() {
+	for _, id := range s.aircraftIDs() {
+		ac := s.Aircrafts[id]
+		if ac.State == aircraft.LANDED {
+			delete(s.HandoffOffers, id)
+			continue
+		}
+
+		if ac.ControllingSector == "" {
+			if sec := s.Airspace.SectorAt(ac.Position, ac.Altitude); sec != nil {
+				s.assignSector(ac, sec)
+			}
+			continue
+		}
+
+		offer, hasOffer := s.HandoffOffers[id]
+		next := s.Airspace.SectorAt(ac.ProjectPosition(s.handoffLookaheadSeconds), ac.Altitude)
+
+		if !hasOffer {
+			if next != nil && next.Name != ac.ControllingSector {
+				s.offerHandoff(ac, next)
+			}
+			continue
+		}
+
+		if offer.State != HandoffAccepted && (next == nil || next.Name != offer.ToSector) {
+			log.Printf("HANDOFF: Offer of %s to %s cancelled, aircraft no longer heading there.", id, offer.ToSector)
+			delete(s.HandoffOffers, id)
+			continue
+		}
+
+		elapsed := s.GameTimeSeconds - offer.UpdatedAt
+		switch offer.State {
+		case HandoffOffered:
+			if !s.IsSectorStaffed(offer.ToSector) && elapsed > s.autoCoordinationSeconds {
+				s.acceptHandoff(offer)
+			}
+		case HandoffAccepted:
+			if !s.IsSectorStaffed(offer.FromSector) && elapsed > s.autoCoordinationSeconds {
+				phrases := s.phrase(ac, []command.Command{command.FrequencyChange{}})
+				s.AddATCMessage(ac.ID, phraseology.Transmission(string(ac.ID), phrases), false)
+				s.AddRadioMessage(ac.ID, phraseology.Readback(string(ac.ID), phrases), false)
+				s.transferControl(ac, offer)
+			}
+		case HandoffRejected:
+			if elapsed > s.handoffRetrySeconds {
+				delete(s.HandoffOffers, id)
+			}
+		}
+	}
+
+	pointOuts := s.PointOuts[:0]
+	for _, po := range s.PointOuts {
+		if _, ok := s.Aircrafts[po.Callsign]; !ok {
+			continue
+		}
+		pointOuts = append(pointOuts, po)
+		if !po.Acknowledged && !s.IsSectorStaffed(po.ToSector) && s.GameTimeSeconds-po.IssuedAt > s.autoCoordinationSeconds {
+			po.Acknowledged = true
+			log.Printf("POINT OUT: %s acknowledged point out of %s from %s.", po.ToSector, po.Callsign, po.FromSector)
+		}
+	}
+	s.PointOuts = pointOuts
+
+	// Aircraft flown by automated sectors are released to the next unit on their own.
+	for _, id := range s.aircraftIDs() {
+		ac := s.Aircrafts[id]
+		if ac.ClearedForHandoff || s.IsSectorStaffed(ac.ControllingSector) {
+			continue
+		}
+		if ac.FlightPlan != nil && ac.FlightPlan.CurrentSegmentIndex >= len(ac.FlightPlan.Route) && ac.State != aircraft.LANDED {
+			ac.ClearedForHandoff = true
+		}
+	}
+}
+
+func (s *Simulation) offerHandoff(ac *aircraft.Aircraft, to *airspace.Sector) {
+	offer := &HandoffOffer{
+		Callsign:   ac.ID,
+		FromSector: ac.ControllingSector,
+		ToSector:   to.Name,
+		State:      HandoffOffered,
+		OfferedAt:  s.GameTimeSeconds,
+		UpdatedAt:  s.GameTimeSeconds,
+	}
+	s.HandoffOffers[ac.ID] = offer
+	log.Printf("HANDOFF: %s offered %s to %s.", offer.FromSector, ac.ID, offer.ToSector)
+}
+
+func (s *Simulation) acceptHandoff(offer *HandoffOffer) {
+	offer.State = HandoffAccepted
+	offer.UpdatedAt = s.GameTimeSeconds
+	log.Printf("HANDOFF: %s accepted %s from %s.", offer.ToSector, offer.Callsign, offer.FromSector)
+}
+
+// transferControl retunes the aircraft to the receiving sector, where it
+// checks in once done talking on the old frequency.
+func (s *Simulation) transferControl(ac *aircraft.Aircraft, offer *HandoffOffer) {
+	next := s.Airspace.Sectors[offer.ToSector]
+
+	leaving := s.GameTimeSeconds
+	if ch, ok := s.Channels[s.frequencyOf(ac.ID)]; ok {
+		leaving = max(leaving, ch.BusyUntil)
+	}
+	s.assignSector(ac, next)
+	checkIn := s.queueCall(ac.ID, fmt.Sprintf("%s, %s, %s.", s.stationName(next), ac.ID, phraseology.Level(ac.Altitude)), false)
+	checkIn.NotBefore = leaving + s.PilotLatencySeconds
+
+	delete(s.HandoffOffers, ac.ID)
+	s.SectorTransfers++
+	log.Printf("HANDOFF: %s transferred from %s to %s.", ac.ID, offer.FromSector, offer.ToSector)
+}
+
+// AcceptHandoff accepts a pending handoff offer into a staffed sector.
+func (s *Simulation) AcceptHandoff(aircraftID types.AircraftID) error {
+	offer, ok := s.HandoffOffers[aircraftID]
+	if !ok || offer.State != HandoffOffered {
+		return fmt.Errorf("no pending handoff for %s", aircraftID)
+	}
+	if !s.IsSectorStaffed(offer.ToSector) {
+		return fmt.Errorf("handoff of %s is for %s, which you do not control", aircraftID, offer.ToSector)
+	}
+
+	s.acceptHandoff(offer)
+	return nil
+}
+
+// RejectHandoff refuses a pending handoff offer. The aircraft stays with the
+// transferring sector, which may offer it again later.
+func (s *Simulation) RejectHandoff(aircraftID types.AircraftID) error {
+	offer, ok := s.HandoffOffers[aircraftID]
+	if !ok || offer.State != HandoffOffered {
+		return fmt.Errorf("no pending handoff for %s", aircraftID)
+	}
+	if !s.IsSectorStaffed(offer.ToSector) {
+		return fmt.Errorf("handoff of %s is for %s, which you do not control", aircraftID, offer.ToSector)
+	}
+
+	offer.State = HandoffRejected
+	offer.UpdatedAt = s.GameTimeSeconds
+	log.Printf("HANDOFF: %s rejected %s from %s.", offer.ToSector, aircraftID, offer.FromSector)
+	return nil
+}
+
+// IssueFrequencyChange instructs an aircraft whose handoff has been accepted
+// to contact the receiving sector, completing the transfer of control. An
+// empty frequency means the receiving sector's.
+func (s *Simulation) IssueFrequencyChange(aircraftID types.AircraftID, frequency string) error {
+	ac, ok := s.Aircrafts[aircraftID]
+	if !ok {
+		return fmt.Errorf("aircraft %s not found", aircraftID)
+	}
+	if err := s.checkControl(ac); err != nil {
+		return err
+	}
+	if err := s.checkFrequencyChange(ac, frequency); err != nil {
+		return err
+	}
+
+	s.transferControl(ac, s.HandoffOffers[aircraftID])
+	return nil
+}
+
+// checkFrequencyChange returns an error unless the aircraft may be sent to
+// frequency, which must be the receiving sector's of an accepted handoff.
+func (s *Simulation) checkFrequencyChange(ac *aircraft.Aircraft, frequency string) error {
+	offer, ok := s.HandoffOffers[ac.ID]
+	if !ok {
+		return reject(RejectNotReady, "%s has not been handed off", ac.ID)
+	}
+	if offer.State != HandoffAccepted {
+		return reject(RejectNotReady, "handoff of %s to %s is %s", ac.ID, offer.ToSector, HandoffStateStringMap[offer.State])
+	}
+	if next := s.Airspace.Sectors[offer.ToSector]; frequency != "" && frequency != next.Frequency {
+		return reject(RejectInvalidValue, "%s is handed off to %s on %s, not %s", ac.ID, next.Name, next.Frequency, frequency)
+	}
+	return nil
+}
+
+// stationName is how pilots address the controller of a sector.
+func (s *Simulation) stationName(sec *airspace.Sector) string {
+	if pos, ok := s.Airspace.Positions[sec.Controller]; ok {
+		return pos.Name
+	}
+	return sec.Name
+}
+
+// PointOutAircraft makes another sector aware of an aircraft that will pass
+// close to or briefly through its airspace without transferring control.
+func (s *Simulation) PointOutAircraft(aircraftID types.AircraftID, sectorName string) error {
+	ac, ok := s.Aircrafts[aircraftID]
+	if !ok {
+		return fmt.Errorf("aircraft %s not found", aircraftID)
+	}
+	if err := s.checkControl(ac); err != nil {
+		return err
+	}
+	if _, ok := s.Airspace.Sectors[sectorName]; !ok {
+		return fmt.Errorf("sector %s not found", sectorName)
+	}
+	if sectorName == ac.ControllingSector {
+		return fmt.Errorf("%s is already in %s", aircraftID, sectorName)
+	}
+
+	s.PointOuts = append(s.PointOuts, &PointOut{
+		Callsign:   ac.ID,
+		FromSector: ac.ControllingSector,
+		ToSector:   sectorName,
+		IssuedAt:   s.GameTimeSeconds,
+	})
+	log.Printf("POINT OUT: %s pointed out %s to %s.", ac.ControllingSector, ac.ID, sectorName)
+	return nil
+}
+
+// AcknowledgePointOut acknowledges the most recent point out of an aircraft
+// into one of the sectors owned by the given positions.
+func (s *Simulation) AcknowledgePointOut(aircraftID types.AircraftID, positions []string) error {
+	for i := len(s.PointOuts) - 1; i >= 0; i-- {
+		po := s.PointOuts[i]
+		if po.Callsign != aircraftID || po.Acknowledged || !s.SectorOwnedBy(po.ToSector, positions) {
+			continue
+		}
+		po.Acknowledged = true
+		log.Printf("POINT OUT: %s acknowledged point out of %s from %s.", po.ToSector, po.Callsign, po.FromSector)
+		return nil
+	}
+	return fmt.Errorf("no pending point out for %s", aircraftID)
+}
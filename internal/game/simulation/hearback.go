@@ -0,0 +1,239 @@
+package simulation
+
+import (
+	"atc-simulator/internal/game/aircraft"
+	"atc-simulator/internal/game/command"
+	"atc-simulator/internal/game/phraseology"
+	"atc-simulator/pkg/types"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+)
+
+type ReadbackErrorKind int
+
+const (
+	ReadbackWrongHeading ReadbackErrorKind = iota
+	ReadbackWrongLevel
+	ReadbackWrongCallsign
+)
+
+var ReadbackErrorKindStringMap = map[ReadbackErrorKind]string{
+	ReadbackWrongHeading:  "HEADING",
+	ReadbackWrongLevel:    "LEVEL",
+	ReadbackWrongCallsign: "CALLSIGN",
+}
+
+// ReadbackError is a clearance read back wrong. The pilot flies what they
+// read back unless the controller corrects them within the hearback window.
+type ReadbackError struct {
+	Kind      ReadbackErrorKind
+	Addressee types.AircraftID // who the clearance was for
+	Callsign  types.AircraftID // who read it back and is flying it
+	Expected  string           // phrases as transmitted
+	ReadBack  string           // phrases as read back
+	Wrong     float64          // heading or feet read back, for those kinds
+	ReadAt    float64          // game time of the readback
+	Deadline  float64          // game time by which the error must be corrected
+	Caught    bool
+	CaughtAt  float64
+	Resolved  bool // caught, or the window has passed
+}
+
+// SetReadbackErrors enables pilots reading back a wrong heading, level or
+// callsign on the given fraction of clearances, 0 to turn it off, and sets how
+// long the controller has to catch each one.
+func (s *Simulation) SetReadbackErrors(rate, windowSeconds float64) error {
+	if math.IsNaN(rate) || rate < 0 || rate > 1 {
+		return fmt.Errorf("invalid readback error rate %.2f, must be between 0 and 1", rate)
+	}
+	if math.IsNaN(windowSeconds) || windowSeconds <= 0 {
+		return fmt.Errorf("invalid hearback window %.0f, must be positive", windowSeconds)
+	}
+	s.ReadbackErrorRate = rate
+	s.HearbackWindowSeconds = windowSeconds
+	log.Printf("INSTRUCTOR: readback errors on %.0f%% of clearances, %.0fs to catch them", rate*100, windowSeconds)
+	return nil
+}
+
+// garbleClearance decides whether the pilot mishears the clearance and, if
+// so, returns who reads it back and what they read back.
+func (s *Simulation) garbleClearance(ac *aircraft.Aircraft, clearances []command.Command) (*aircraft.Aircraft, []command.Command, ReadbackErrorKind, bool) {
+	if s.ReadbackErrorRate <= 0 || s.rng.Float64() >= s.ReadbackErrorRate {
+		return ac, clearances, 0, false
+	}
+
+	var kinds []ReadbackErrorKind
+	for _, c := range clearances {
+		switch c.(type) {
+		case command.Heading:
+			kinds = append(kinds, ReadbackWrongHeading)
+		case command.Altitude:
+			kinds = append(kinds, ReadbackWrongLevel)
+		}
+	}
+	other := s.similarCallsign(ac)
+	if other != nil {
+		kinds = append(kinds, ReadbackWrongCallsign)
+	}
+	if len(kinds) == 0 {
+		return ac, clearances, 0, false
+	}
+
+	kind := kinds[s.rng.IntN(len(kinds))]
+	if kind == ReadbackWrongCallsign {
+		return other, clearances, kind, true
+	}
+
+	garbled := slices.Clone(clearances)
+	for i, c := range garbled {
+		switch c := c.(type) {
+		case command.Heading:
+			if kind == ReadbackWrongHeading {
+				offset := float64(10 * (1 + s.rng.IntN(4)))
+				if s.rng.IntN(2) == 0 {
+					offset = -offset
+				}
+				c.Degrees = float64(int(c.Degrees+offset+360) % 360)
+				garbled[i] = c
+			}
+		case command.Altitude:
+			if kind == ReadbackWrongLevel {
+				offset := float64(1000 * (1 + s.rng.IntN(2)))
+				if s.rng.IntN(2) == 0 && c.Feet > offset {
+					offset = -offset
+				}
+				c.Feet += offset
+				garbled[i] = c
+			}
+		}
+	}
+	return ac, garbled, kind, true
+}
+
+// wrongValue is the heading or level read back in a garbled clearance.
+func wrongValue(kind ReadbackErrorKind, heard []command.Command) float64 {
+	for _, c := range heard {
+		switch c := c.(type) {
+		case command.Heading:
+			if kind == ReadbackWrongHeading {
+				return c.Degrees
+			}
+		case command.Altitude:
+			if kind == ReadbackWrongLevel {
+				return c.Feet
+			}
+		}
+	}
+	return 0
+}
+
+// similarCallsign picks another aircraft on the same frequency that could
+// take a call meant for ac, preferring one of the same airline.
+func (s *Simulation) similarCallsign(ac *aircraft.Aircraft) *aircraft.Aircraft {
+	frequency := s.frequencyOf(ac.ID)
+	var candidates, sameAirline []*aircraft.Aircraft
+	for _, id := range s.aircraftIDs() {
+		other := s.Aircrafts[id]
+		if id == ac.ID || other.State == aircraft.LANDED || s.frequencyOf(id) != frequency {
+			continue
+		}
+		candidates = append(candidates, other)
+		if len(id) >= 3 && len(ac.ID) >= 3 && id[:3] == ac.ID[:3] {
+			sameAirline = append(sameAirline, other)
+		}
+	}
+	if len(sameAirline) > 0 {
+		candidates = sameAirline
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	return candidates[s.rng.IntN(len(candidates))]
+}
+
+// phrase renders clearances as transmitted to an aircraft in its current
+// state.
+func (s *Simulation) phrase(ac *aircraft.Aircraft, clearances []command.Command) []string {
+	st := phraseology.State{Heading: ac.Heading, Altitude: ac.Altitude, Speed: ac.Speed}
+	if offer, ok := s.HandoffOffers[ac.ID]; ok {
+		if next, ok := s.Airspace.Sectors[offer.ToSector]; ok {
+			st.Station, st.Frequency = s.stationName(next), next.Frequency
+		}
+	}
+	if current := ac.FlightPlan.CurrentSegment(); current != nil {
+		st.Procedure, st.NextFix = current.Procedure, current.WaypointName
+	}
+	phrases := make([]string, len(clearances))
+	for i, c := range clearances {
+		switch c := c.(type) {
+		case command.SID:
+			st.Procedure = c.Procedure
+		case command.STAR:
+			st.Procedure = c.Procedure
+		}
+		phrases[i] = phraseology.Instruction(c, st)
+	}
+	return phrases
+}
+
+// checkHearback marks open readback errors as caught when the controller
+// corrects them: a heading or level other than the wrong one read back for
+// the aircraft flying it, or any clearance to either aircraft involved in a
+// callsign mix-up.
+func (s *Simulation) checkHearback(ac *aircraft.Aircraft, clearances []command.Command) {
+	for _, rb := range s.ReadbackErrors {
+		if rb.Resolved || rb.Deadline < s.GameTimeSeconds {
+			continue
+		}
+
+		corrected := false
+		switch rb.Kind {
+		case ReadbackWrongCallsign:
+			corrected = ac.ID == rb.Callsign || ac.ID == rb.Addressee
+		default:
+			if ac.ID != rb.Callsign {
+				continue
+			}
+			for _, c := range clearances {
+				switch c := c.(type) {
+				case command.Heading:
+					corrected = corrected || rb.Kind == ReadbackWrongHeading && c.Degrees != rb.Wrong
+				case command.Altitude:
+					corrected = corrected || rb.Kind == ReadbackWrongLevel && c.Feet != rb.Wrong
+				}
+			}
+		}
+
+		if corrected {
+			rb.Caught, rb.CaughtAt, rb.Resolved = true, s.GameTimeSeconds, true
+			log.Printf("HEARBACK: %s readback error by %s caught after %.0fs", ReadbackErrorKindStringMap[rb.Kind], rb.Callsign, s.GameTimeSeconds-rb.ReadAt)
+		}
+	}
+}
+
+// updateHearback closes readback errors nobody caught in time.
+func (s *Simulation) updateHearback() {
+	for _, rb := range s.ReadbackErrors {
+		if !rb.Resolved && rb.Deadline < s.GameTimeSeconds {
+			rb.Resolved = true
+			log.Printf("HEARBACK: %s readback error by %s missed", ReadbackErrorKindStringMap[rb.Kind], rb.Callsign)
+		}
+	}
+}
+
+// HearbackScore counts readback errors caught, missed and still open.
+func (s *Simulation) HearbackScore() (caught, missed, open int) {
+	for _, rb := range s.ReadbackErrors {
+		switch {
+		case rb.Caught:
+			caught++
+		case rb.Resolved:
+			missed++
+		default:
+			open++
+		}
+	}
+	return caught, missed, open
+}
@@ -0,0 +1,67 @@
+package simulation
+
+import (
+	"atc-simulator/internal/game/command"
+	"testing"
+)
+
+func parseCommands(t *testing.T, text string) []command.Command {
+	t.Helper()
+	line, err := command.Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return line.Commands
+}
+
+func TestCheckHearback(t *testing.T) {
+	tests := []struct {
+		kind       ReadbackErrorKind
+		wrong      float64
+		correction string
+		caught     bool
+	}{
+		{ReadbackWrongHeading, 280, "H 270", true},
+		{ReadbackWrongHeading, 280, "H 280", false},
+		{ReadbackWrongHeading, 280, "A 5000", false},
+		{ReadbackWrongHeading, 280, "S 220 H 260", true},
+		{ReadbackWrongLevel, 6000, "A 5000", true},
+		{ReadbackWrongLevel, 6000, "A 6000", false},
+		{ReadbackWrongLevel, 6000, "H 280", false},
+	}
+	for _, tt := range tests {
+		s := newTestSimulation(t)
+		rb := &ReadbackError{Kind: tt.kind, Addressee: "TST1", Callsign: "TST1", Wrong: tt.wrong, Deadline: 30}
+		s.ReadbackErrors = append(s.ReadbackErrors, rb)
+
+		s.checkHearback(s.Aircrafts["TST1"], parseCommands(t, tt.correction))
+		if rb.Caught != tt.caught {
+			t.Errorf("%s read back as %.0f, corrected with %s: caught %v, want %v",
+				ReadbackErrorKindStringMap[tt.kind], tt.wrong, tt.correction, rb.Caught, tt.caught)
+		}
+	}
+}
+
+func TestGarbleClearanceKeepsPositions(t *testing.T) {
+	s := newTestSimulation(t)
+	for id := range s.Aircrafts {
+		if id != "TST1" {
+			delete(s.Aircrafts, id) // no similar callsign to read back as
+		}
+	}
+	if err := s.SetReadbackErrors(1, 30); err != nil {
+		t.Fatal(err)
+	}
+	clearances := parseCommands(t, "TST1 S 220 H 270")
+	_, heard, kind, garbled := s.garbleClearance(s.Aircrafts["TST1"], clearances)
+	if !garbled || kind != ReadbackWrongHeading {
+		t.Fatalf("garbled %v as %s, want a wrong heading", garbled, ReadbackErrorKindStringMap[kind])
+	}
+	h, ok := heard[1].(command.Heading)
+	if !ok || h.Degrees == 270 || h.Pos() != clearances[1].Pos() {
+		t.Errorf("heard %s at %d, want another heading at %d", heard[1], heard[1].Pos(), clearances[1].Pos())
+	}
+	if wrongValue(kind, heard) != h.Degrees {
+		t.Errorf("wrong value %.0f, want %.0f", wrongValue(kind, heard), h.Degrees)
+	}
+}
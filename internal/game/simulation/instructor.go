@@ -0,0 +1,429 @@
+package simulation
+
+import (
+	"atc-simulator/internal/game/aircraft"
+	"atc-simulator/internal/game/airspace"
+	"atc-simulator/internal/game/flightplan"
+	"atc-simulator/pkg/types"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type Weather struct {
+	WindDirection float64 // degrees the wind blows from
+	WindSpeed     float64 // knots
+	VisibilityKm  float64
+}
+
+// windDrift returns how far the wind moves an aircraft in dt seconds, in pixels.
+func (w Weather) windDrift(dt float64) types.Vec2 {
+	if w.WindSpeed == 0 {
+		return types.Vec2{}
+	}
+	// Wind from 270 pushes aircraft towards 090.
+	radians := math.Mod(w.WindDirection+180, 360) * math.Pi / 180.0
+	pixels := w.WindSpeed / 3600.0 * types.NM_TO_PIXEL * dt
+	return types.NewVec2(pixels*math.Sin(radians), -pixels*math.Cos(radians))
+}
+
+var emergencyMessages = map[string]string{
+	"ENGINE":   "engine failure, requesting immediate return to land",
+	"FUEL":     "minimum fuel, requesting priority approach",
+	"MEDICAL":  "medical emergency on board, requesting priority approach",
+	"PRESSURE": "lost cabin pressure, commencing emergency descent",
+}
+
+// Pause freezes the simulation clock and all traffic.
+func (s *Simulation) Pause() {
+	s.Paused = true
+	log.Printf("INSTRUCTOR: simulation paused")
+}
+
+func (s *Simulation) Resume() {
+	s.Paused = false
+	log.Printf("INSTRUCTOR: simulation resumed")
+}
+
+// DeclareEmergency makes an aircraft declare an emergency of the given kind
+// (ENGINE, FUEL, MEDICAL or PRESSURE) and fly accordingly.
+func (s *Simulation) DeclareEmergency(aircraftID types.AircraftID, kind string) error {
+	ac, ok := s.Aircrafts[aircraftID]
+	if !ok {
+		return fmt.Errorf("aircraft %s not found", aircraftID)
+	}
+	message, ok := emergencyMessages[kind]
+	if !ok {
+		return fmt.Errorf("unknown emergency %s", kind)
+	}
+	if ac.Emergency == kind {
+		return fmt.Errorf("%s has already declared a %s emergency", aircraftID, kind)
+	}
+
+	switch kind {
+	case "ENGINE":
+		ac.MaxClimbRateFPM /= 2
+		ac.SetSpeed(math.Min(ac.TargetSpeed, 220))
+	case "PRESSURE":
+		ac.MaxDescentRateFPM = -4000
+		ac.SetAltitude(math.Min(ac.TargetAltitude, 10000))
+	}
+	ac.Emergency = kind
+
+	s.AddRadioMessage(ac.ID, fmt.Sprintf("MAYDAY MAYDAY MAYDAY, %s, %s.", ac.ID, message), true)
+	log.Printf("INSTRUCTOR: %s declared %s emergency", ac.ID, kind)
+	return nil
+}
+
+// InjectPilotRequest makes an aircraft transmit a free text request.
+func (s *Simulation) InjectPilotRequest(aircraftID types.AircraftID, request string) error {
+	if _, ok := s.Aircrafts[aircraftID]; !ok {
+		return fmt.Errorf("aircraft %s not found", aircraftID)
+	}
+	s.AddRadioMessage(aircraftID, request, false)
+	return nil
+}
+
+// SetWeather changes the wind and visibility and broadcasts them on every
+// frequency.
+func (s *Simulation) SetWeather(w Weather) error {
+	if !types.Finite(w.WindDirection) || w.WindDirection < 0 || w.WindDirection >= 360 {
+		return fmt.Errorf("invalid wind direction %.0f, must be 0-359", w.WindDirection)
+	}
+	if !types.Finite(w.WindSpeed) || w.WindSpeed < 0 {
+		return fmt.Errorf("invalid wind speed %.0f, must not be negative", w.WindSpeed)
+	}
+	if !types.Finite(w.VisibilityKm) || w.VisibilityKm <= 0 {
+		return fmt.Errorf("invalid visibility %.1f, must be positive", w.VisibilityKm)
+	}
+	s.Weather = w
+	for _, name := range s.Airspace.SectorNames() {
+		sec := s.Airspace.Sectors[name]
+		s.transmitATC(sec.Frequency, fmt.Sprintf("All stations, wind %03.0f at %.0f, visibility %.0f km.", w.WindDirection, w.WindSpeed, w.VisibilityKm), false)
+	}
+	log.Printf("INSTRUCTOR: weather set to %+v", w)
+	return nil
+}
+
+// SpawnAircraft adds an aircraft at entryWp, filed to exitWp.
+func (s *Simulation) SpawnAircraft(callsign types.AircraftID, entryWp, exitWp string, altitude, speed float64) error {
+	if _, exists := s.Aircrafts[callsign]; exists {
+		return fmt.Errorf("aircraft %s already exists", callsign)
+	}
+	entry, ok := s.Airspace.Waypoints[entryWp]
+	if !ok {
+		return fmt.Errorf("waypoint %s not found", entryWp)
+	}
+	exit, ok := s.Airspace.Waypoints[exitWp]
+	if !ok {
+		return fmt.Errorf("waypoint %s not found", exitWp)
+	}
+
+	flightPlan := &flightplan.FlightPlan{
+		OriginAirportID:      "INSTRUCTOR",
+		DestinationAirportID: exit.Name,
+		Callsign:             callsign,
+		Route: []flightplan.FlightPlanSegment{
+			{WaypointName: exit.Name, TargetAltitude: altitude, TargetSpeed: speed},
+		},
+	}
+	if err := flightPlan.Validate(s.Airspace); err != nil {
+		return err
+	}
+
+	ac := aircraft.NewAircraft(
+		callsign,
+		entry.Position,
+		entry.Position.HeadingTo(exit.Position),
+		speed,
+		altitude,
+		aircraft.CRUISE,
+		flightPlan,
+		s.Airspace,
+		s.AddRadioMessage,
+	)
+	if sec := s.Airspace.SectorAt(ac.Position, ac.Altitude); sec != nil {
+		s.assignSector(ac, sec)
+	}
+	s.Aircrafts[callsign] = ac
+	log.Printf("INSTRUCTOR: spawned %s at %s for %s", callsign, entry.Name, exit.Name)
+	return nil
+}
+
+// SpawnRoute adds an aircraft at the first point of an ICAO style route,
+// filed to fly the rest of it, see airspace.ParseRoute. A route ending in a
+// STAR ends in an approach to its runway.
+func (s *Simulation) SpawnRoute(callsign types.AircraftID, route string, altitude, speed float64) error {
+	if _, exists := s.Aircrafts[callsign]; exists {
+		return fmt.Errorf("aircraft %s already exists", callsign)
+	}
+	segments, err := s.Airspace.ParseRoute(route, altitude, speed)
+	if err != nil {
+		return err
+	}
+	entry := s.Airspace.Waypoints[segments[0].WaypointName]
+	if len(segments) > 1 {
+		segments = segments[1:]
+	}
+	first := s.Airspace.Waypoints[segments[0].WaypointName]
+	if star, ok := s.Airspace.Procedure(segments[len(segments)-1].Procedure); ok && star.Kind == airspace.STAR {
+		segments = append(segments, starLanding(star))
+	}
+
+	flightPlan := &flightplan.FlightPlan{
+		OriginAirportID: "INSTRUCTOR",
+		Callsign:        callsign,
+		Route:           segments,
+	}
+	flightPlan.DestinationAirportID = flightPlan.Destination()
+	if err := flightPlan.Validate(s.Airspace); err != nil {
+		return err
+	}
+	ac := aircraft.NewAircraft(
+		callsign,
+		entry.Position,
+		entry.Position.HeadingTo(first.Position),
+		segments[0].TargetSpeed,
+		segments[0].TargetAltitude,
+		aircraft.CRUISE,
+		flightPlan,
+		s.Airspace,
+		s.AddRadioMessage,
+	)
+	if sec := s.Airspace.SectorAt(ac.Position, ac.Altitude); sec != nil {
+		s.assignSector(ac, sec)
+	}
+	s.Aircrafts[callsign] = ac
+	log.Printf("INSTRUCTOR: spawned %s at %s filed %s", callsign, entry.Name, route)
+	return nil
+}
+
+// DeleteAircraft removes an aircraft, flying or waiting to depart, without
+// affecting the score.
+func (s *Simulation) DeleteAircraft(aircraftID types.AircraftID) error {
+	queued := slices.ContainsFunc(s.DepartureQueue, func(dep *Departure) bool {
+		return dep.Callsign == aircraftID
+	})
+	if _, ok := s.Aircrafts[aircraftID]; !ok && !queued {
+		return fmt.Errorf("aircraft %s not found", aircraftID)
+	}
+	delete(s.Aircrafts, aircraftID)
+	delete(s.HandoffOffers, aircraftID)
+	s.PendingClearances = slices.DeleteFunc(s.PendingClearances, func(pc *PendingClearance) bool {
+		return pc.Callsign == aircraftID
+	})
+	s.PilotRequests = slices.DeleteFunc(s.PilotRequests, func(req *PilotRequest) bool {
+		return req.Callsign == aircraftID
+	})
+	if queued {
+		s.DepartureQueue = slices.DeleteFunc(s.DepartureQueue, func(dep *Departure) bool {
+			return dep.Callsign == aircraftID
+		})
+		s.allocateSlots()
+	}
+	log.Printf("INSTRUCTOR: deleted %s", aircraftID)
+	return nil
+}
+
+// Bounds of what an instructor may set an aircraft to.
+const (
+	maxOverrideAltitude = 60000.0 // feet
+	maxOverrideSpeed    = 600.0   // knots
+)
+
+// parseFinite parses a number for an instructor command. Unlike
+// strconv.ParseFloat it refuses NaN and Inf, which no aircraft state can hold.
+func parseFinite(text string) (float64, error) {
+	v, err := strconv.ParseFloat(text, 64)
+	if err == nil && !types.Finite(v) {
+		return 0, strconv.ErrSyntax
+	}
+	return v, err
+}
+
+// OverrideAircraft sets an aircraft's actual state immediately, bypassing its
+// performance limits. field is one of ALT, HDG, SPD or POS; POS takes "x,y".
+func (s *Simulation) OverrideAircraft(aircraftID types.AircraftID, field, value string) error {
+	ac, ok := s.Aircrafts[aircraftID]
+	if !ok {
+		return fmt.Errorf("aircraft %s not found", aircraftID)
+	}
+
+	if field == "POS" {
+		x, y, found := strings.Cut(value, ",")
+		if !found {
+			return fmt.Errorf("invalid position %s, expected x,y", value)
+		}
+		px, errX := parseFinite(x)
+		py, errY := parseFinite(y)
+		if errX != nil || errY != nil {
+			return fmt.Errorf("invalid position %s, expected x,y", value)
+		}
+		ac.Position = types.NewVec2(px, py)
+		return nil
+	}
+
+	v, err := parseFinite(value)
+	if err != nil {
+		return fmt.Errorf("invalid value %s for %s", value, field)
+	}
+
+	switch field {
+	case "ALT":
+		if v < 0 || v > maxOverrideAltitude {
+			return fmt.Errorf("invalid altitude %s, must be between 0 and %.0f ft", value, maxOverrideAltitude)
+		}
+		ac.Altitude, ac.TargetAltitude, ac.ClimbRate = v, v, 0
+	case "HDG":
+		heading := math.Mod(math.Mod(v, 360)+360, 360)
+		ac.Heading, ac.TargetHeading = heading, heading
+	case "SPD":
+		if v < 0 || v > maxOverrideSpeed {
+			return fmt.Errorf("invalid speed %s, must be between 0 and %.0f kt", value, maxOverrideSpeed)
+		}
+		ac.Speed, ac.TargetSpeed = v, v
+	default:
+		return fmt.Errorf("unknown field %s, expected ALT, HDG, SPD or POS", field)
+	}
+	log.Printf("INSTRUCTOR: set %s %s to %s", aircraftID, field, value)
+	return nil
+}
+
+// ExecuteInstructorCommand parses and applies a privileged instructor command:
+//
+//	PAUSE | RESUME
+//	EMERG <callsign> <ENGINE|FUEL|MEDICAL|PRESSURE>
+//	REQ <callsign> <free text>
+//	WX <wind dir> <wind speed> [visibility km]
+//	SPAWN <callsign> <entry wp> <exit wp> <altitude> <speed>
+//	FPL <callsign> <altitude> <speed> <route>
+//	DEL <callsign>
+//	SET <callsign> <ALT|HDG|SPD|POS> <value>
+//	LATENCY <seconds>
+//	RBERR <rate 0-1> [hearback window seconds]
+//	FLOW <entry fix|airport> <aircraft an hour>
+func (s *Simulation) ExecuteInstructorCommand(cmd string) error {
+	s.recordEvent(EventInstructor, nil, "", cmd)
+
+	parts := strings.Fields(cmd)
+	if len(parts) < 1 {
+		return fmt.Errorf("empty instructor command")
+	}
+
+	upper := make([]string, len(parts))
+	for i, p := range parts {
+		upper[i] = strings.ToUpper(p)
+	}
+	expect := func(n int, usage string) error {
+		if len(parts) < n {
+			return fmt.Errorf("usage: %s", usage)
+		}
+		return nil
+	}
+
+	switch upper[0] {
+	case "PAUSE":
+		s.Pause()
+	case "RESUME":
+		s.Resume()
+	case "EMERG":
+		if err := expect(3, "EMERG <callsign> <ENGINE|FUEL|MEDICAL|PRESSURE>"); err != nil {
+			return err
+		}
+		return s.DeclareEmergency(types.AircraftID(upper[1]), upper[2])
+	case "REQ":
+		if err := expect(3, "REQ <callsign> <text>"); err != nil {
+			return err
+		}
+		return s.InjectPilotRequest(types.AircraftID(upper[1]), strings.Join(parts[2:], " "))
+	case "WX":
+		if err := expect(3, "WX <wind dir> <wind speed> [visibility km]"); err != nil {
+			return err
+		}
+		w := Weather{VisibilityKm: s.Weather.VisibilityKm}
+		var err error
+		if w.WindDirection, err = parseFinite(parts[1]); err != nil {
+			return fmt.Errorf("invalid wind direction %s", parts[1])
+		}
+		if w.WindSpeed, err = parseFinite(parts[2]); err != nil {
+			return fmt.Errorf("invalid wind speed %s", parts[2])
+		}
+		if len(parts) > 3 {
+			if w.VisibilityKm, err = parseFinite(parts[3]); err != nil {
+				return fmt.Errorf("invalid visibility %s", parts[3])
+			}
+		}
+		return s.SetWeather(w)
+	case "SPAWN":
+		const usage = "SPAWN <callsign> <entry wp> <exit wp> <altitude> <speed>"
+		if err := expect(6, usage); err != nil {
+			return err
+		}
+		altitude, errAlt := parseFinite(parts[4])
+		speed, errSpd := parseFinite(parts[5])
+		if errAlt != nil || errSpd != nil {
+			return fmt.Errorf("invalid altitude or speed, usage: %s", usage)
+		}
+		return s.SpawnAircraft(types.AircraftID(upper[1]), upper[2], upper[3], altitude, speed)
+	case "FPL":
+		const usage = "FPL <callsign> <altitude> <speed> <route>"
+		if err := expect(5, usage); err != nil {
+			return err
+		}
+		altitude, errAlt := parseFinite(parts[2])
+		speed, errSpd := parseFinite(parts[3])
+		if errAlt != nil || errSpd != nil {
+			return fmt.Errorf("invalid altitude or speed, usage: %s", usage)
+		}
+		return s.SpawnRoute(types.AircraftID(upper[1]), strings.Join(upper[4:], " "), altitude, speed)
+	case "DEL":
+		if err := expect(2, "DEL <callsign>"); err != nil {
+			return err
+		}
+		return s.DeleteAircraft(types.AircraftID(upper[1]))
+	case "SET":
+		if err := expect(4, "SET <callsign> <ALT|HDG|SPD|POS> <value>"); err != nil {
+			return err
+		}
+		return s.OverrideAircraft(types.AircraftID(upper[1]), upper[2], parts[3])
+	case "LATENCY":
+		if err := expect(2, "LATENCY <seconds>"); err != nil {
+			return err
+		}
+		seconds, err := parseFinite(parts[1])
+		if err != nil {
+			return fmt.Errorf("invalid latency %s", parts[1])
+		}
+		return s.SetPilotLatency(seconds)
+	case "RBERR":
+		if err := expect(2, "RBERR <rate 0-1> [hearback window seconds]"); err != nil {
+			return err
+		}
+		rate, err := parseFinite(parts[1])
+		if err != nil {
+			return fmt.Errorf("invalid readback error rate %s", parts[1])
+		}
+		window := s.HearbackWindowSeconds
+		if len(parts) > 2 {
+			if window, err = parseFinite(parts[2]); err != nil {
+				return fmt.Errorf("invalid hearback window %s", parts[2])
+			}
+		}
+		return s.SetReadbackErrors(rate, window)
+	case "FLOW":
+		if err := expect(3, "FLOW <entry fix|airport> <aircraft an hour>"); err != nil {
+			return err
+		}
+		rate, err := parseFinite(parts[2])
+		if err != nil {
+			return fmt.Errorf("invalid rate %s", parts[2])
+		}
+		return s.SetFlowRate(upper[1], rate)
+	default:
+		return fmt.Errorf("unknown instructor command: %s", upper[0])
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package simulation
+
+import (
+	"atc-simulator/internal/game/command"
+	"atc-simulator/pkg/types"
+	"fmt"
+	"math"
+	"testing"
+)
+
+// newTestSimulation returns a simulation with TST1 flying from APIPO to FILKA.
+func newTestSimulation(t *testing.T) *Simulation {
+	t.Helper()
+	s := NewSimulation(30, 1024, 768, 1)
+	if err := s.SpawnAircraft("TST1", "APIPO", "FILKA", 10000, 250); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestExecuteInstructorCommand(t *testing.T) {
+	tests := []struct {
+		cmd string
+		ok  bool
+	}{
+		{"", false},
+		{"FOO", false},
+		{"PAUSE", true},
+		{"resume", true},
+
+		{"EMERG TST1", false},
+		{"EMERG TST1 engine", true},
+		{"EMERG TST1 SNAKES", false},
+		{"EMERG XXX1 FUEL", false},
+		{"REQ TST1 request higher", true},
+		{"REQ XXX1 request higher", false},
+
+		{"WX 270 15", true},
+		{"WX 0 0 0.5", true},
+		{"WX 270", false},
+		{"WX 360 15", false},
+		{"WX -10 15", false},
+		{"WX NaN 15", false},
+		{"WX 270 Inf", false},
+		{"WX 270 -5", false},
+		{"WX 270 15 0", false},
+		{"WX 270 15 NaN", false},
+
+		{"SPAWN TST2 APIPO FILKA 9000 240", true},
+		{"SPAWN TST1 APIPO FILKA 9000 240", false},
+		{"SPAWN TST2 APIPO FILKA 9000", false},
+		{"SPAWN TST2 APIPO FILKA NaN 240", false},
+		{"SPAWN TST2 APIPO FILKA 9000 Inf", false},
+		{"SPAWN TST2 NOWHERE FILKA 9000 240", false},
+
+		{"FPL TST2 12000 240 APIPO W12 FILKA A33 BISKET", true},
+		{"FPL TST2 12000 240", false},
+		{"FPL TST2 NaN 240 APIPO W12 FILKA", false},
+		{"FPL TST2 12000 -Inf APIPO W12 FILKA", false},
+		{"FPL TST2 12000 240 APIPO W99 FILKA", false},
+
+		{"DEL TST1", true},
+		{"DEL XXX1", false},
+
+		{"SET TST1 ALT 12000", true},
+		{"SET TST1 ALT -1", false},
+		{"SET TST1 ALT 60001", false},
+		{"SET TST1 ALT NaN", false},
+		{"SET TST1 SPD 600", true},
+		{"SET TST1 SPD 601", false},
+		{"SET TST1 SPD +Inf", false},
+		{"SET TST1 HDG 720", true},
+		{"SET TST1 HDG -Inf", false},
+		{"SET TST1 POS 100,200", true},
+		{"SET TST1 POS 100,NaN", false},
+		{"SET TST1 POS 100", false},
+		{"SET TST1 FOO 1", false},
+		{"SET XXX1 ALT 5000", false},
+
+		{"LATENCY 4.5", true},
+		{"LATENCY 0", true},
+		{"LATENCY", false},
+		{"LATENCY 31", false},
+		{"LATENCY -1", false},
+		{"LATENCY NaN", false},
+		{"LATENCY Inf", false},
+
+		{"RBERR 0.2", true},
+		{"RBERR 0.2 20", true},
+		{"RBERR", false},
+		{"RBERR 1.5", false},
+		{"RBERR NaN", false},
+		{"RBERR 0.2 0", false},
+		{"RBERR 0.2 NaN", false},
+		{"RBERR 0.2 Inf", false},
+
+		{"FLOW APIPO 600", true},
+		{"FLOW KBLR 0", true},
+		{"FLOW APIPO", false},
+		{"FLOW APIPO 601", false},
+		{"FLOW APIPO -1", false},
+		{"FLOW APIPO NaN", false},
+		{"FLOW APIPO Inf", false},
+		{"FLOW NOWHERE 10", false},
+	}
+	for _, tt := range tests {
+		err := newTestSimulation(t).ExecuteInstructorCommand(tt.cmd)
+		if (err == nil) != tt.ok {
+			t.Errorf("%q: error %v, want ok %v", tt.cmd, err, tt.ok)
+		}
+	}
+}
+
+func TestOverrideHeadingWraps(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+	}{
+		{"90", 90},
+		{"360", 0},
+		{"-400", 320},
+		{"1090.5", 10.5},
+	}
+	s := newTestSimulation(t)
+	for _, tt := range tests {
+		if err := s.OverrideAircraft("TST1", "HDG", tt.value); err != nil {
+			t.Fatal(err)
+		}
+		if ac := s.Aircrafts["TST1"]; ac.Heading != tt.want || ac.TargetHeading != tt.want {
+			t.Errorf("HDG %s set heading %.1f and target %.1f, want %.1f", tt.value, ac.Heading, ac.TargetHeading, tt.want)
+		}
+	}
+}
+
+func TestDeclareEmergencyOnce(t *testing.T) {
+	s := newTestSimulation(t)
+	ac := s.Aircrafts["TST1"]
+	climb := ac.MaxClimbRateFPM
+	if err := s.DeclareEmergency("TST1", "ENGINE"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeclareEmergency("TST1", "ENGINE"); err == nil {
+		t.Error("second ENGINE emergency accepted")
+	}
+	if ac.MaxClimbRateFPM != climb/2 {
+		t.Errorf("climb rate %.0f, want %.0f", ac.MaxClimbRateFPM, climb/2)
+	}
+}
+
+func TestWeatherKeepsVisibility(t *testing.T) {
+	s := newTestSimulation(t)
+	if err := s.ExecuteInstructorCommand("WX 180 20 4"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ExecuteInstructorCommand("WX 200 25"); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Weather{WindDirection: 200, WindSpeed: 25, VisibilityKm: 4}); s.Weather != want {
+		t.Errorf("weather %+v, want %+v", s.Weather, want)
+	}
+}
+
+func TestSetPilotLatencyRejectsNaN(t *testing.T) {
+	s := newTestSimulation(t)
+	if err := s.SetPilotLatency(math.NaN()); err == nil {
+		t.Errorf("NaN latency accepted, now %v", s.PilotLatencySeconds)
+	}
+}
+
+func TestSetReadbackErrorsRejectsNaN(t *testing.T) {
+	s := newTestSimulation(t)
+	if err := s.SetReadbackErrors(math.NaN(), 30); err == nil {
+		t.Error("NaN rate accepted")
+	}
+	if err := s.SetReadbackErrors(0.5, math.NaN()); err == nil {
+		t.Error("NaN window accepted")
+	}
+}
+
+func TestDeleteAircraftDropsItsState(t *testing.T) {
+	s := newTestSimulation(t)
+	line, err := command.Parse("H 200")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.transmitClearance(s.Aircrafts["TST1"], line.Commands)
+	s.PilotRequests = append(s.PilotRequests, &PilotRequest{Callsign: "TST1", Kind: RequestDirect})
+	if len(s.PendingClearances) == 0 {
+		t.Fatal("no clearance pending")
+	}
+
+	if err := s.DeleteAircraft("TST1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.PendingClearances) != 0 {
+		t.Errorf("%d clearances left pending", len(s.PendingClearances))
+	}
+	if len(s.PilotRequests) != 0 {
+		t.Errorf("%d pilot requests left", len(s.PilotRequests))
+	}
+}
+
+func TestDeleteQueuedDeparture(t *testing.T) {
+	s := newTestSimulation(t)
+	for i := 0; len(s.DepartureQueue) < 2; i++ {
+		if i == 100 {
+			t.Fatal("no departures queued")
+		}
+		s.spawnDeparture(types.AircraftID(fmt.Sprintf("DEP%d", i)), "KBLR", s.Traffic.Fleet[0])
+	}
+	first, second := s.DepartureQueue[0], s.DepartureQueue[1]
+
+	if err := s.DeleteAircraft(first.Callsign); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.DepartureQueue) != 1 || s.DepartureQueue[0] != second {
+		t.Errorf("queue %v, want only %s", s.DepartureQueue, second.Callsign)
+	}
+	if err := s.DeleteAircraft(first.Callsign); err == nil {
+		t.Errorf("%s deleted twice", first.Callsign)
+	}
+}
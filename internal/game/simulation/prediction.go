@@ -0,0 +1,69 @@
+package simulation
+
+import (
+	"atc-simulator/internal/game/aircraft"
+	"atc-simulator/internal/game/trajectory"
+	"atc-simulator/pkg/types"
+	"cmp"
+	"math"
+	"slices"
+)
+
+const (
+	predictionHorizonSeconds  = 20 * 60.0
+	arrivalHorizonSeconds     = 60 * 60.0
+	predictionIntervalSeconds = 5.0
+)
+
+// updatePredictions predicts the trajectory of new aircraft at once and
+// renews the others once they are predictionIntervalSeconds old, oldest
+// first and only a few a tick so that the work is spread out. The arrivals
+// are then sequenced by them.
+func (s *Simulation) updatePredictions(dt float64) {
+	for id := range s.Trajectories {
+		if _, ok := s.Aircrafts[id]; !ok {
+			delete(s.Trajectories, id)
+		}
+	}
+
+	var stale []*trajectory.Trajectory
+	for _, id := range s.aircraftIDs() {
+		tr, ok := s.Trajectories[id]
+		switch {
+		case !ok:
+			s.predict(s.Aircrafts[id])
+		case s.GameTimeSeconds-tr.Points[0].Time >= predictionIntervalSeconds:
+			stale = append(stale, tr)
+		}
+	}
+	slices.SortStableFunc(stale, func(a, b *trajectory.Trajectory) int {
+		return cmp.Compare(a.Points[0].Time, b.Points[0].Time)
+	})
+	budget := max(1, int(math.Ceil(float64(len(s.Aircrafts))*dt/predictionIntervalSeconds)))
+	for _, tr := range stale[:min(budget, len(stale))] {
+		s.predict(s.Aircrafts[tr.Callsign])
+	}
+
+	s.sequenceArrivals()
+}
+
+// predict replaces the predicted trajectory of an aircraft, as after a
+// clearance changes where it goes. Arrivals are predicted until they land.
+func (s *Simulation) predict(ac *aircraft.Aircraft) {
+	horizon := predictionHorizonSeconds
+	if landingSegment(ac) != nil {
+		horizon = arrivalHorizonSeconds
+	}
+	s.Trajectories[ac.ID] = s.predictedTrajectory(ac, horizon)
+}
+
+// predictedTrajectory predicts the trajectory of an aircraft from now for
+// horizon seconds, in the wind blowing now.
+func (s *Simulation) predictedTrajectory(ac *aircraft.Aircraft, horizon float64) *trajectory.Trajectory {
+	return trajectory.Predict(ac, s.GameTimeSeconds, horizon, s.Weather.windDrift(1))
+}
+
+// Trajectory returns the predicted trajectory of an aircraft, or nil.
+func (s *Simulation) Trajectory(aircraftID types.AircraftID) *trajectory.Trajectory {
+	return s.Trajectories[aircraftID]
+}
@@ -0,0 +1,109 @@
+package simulation
+
+import (
+	"atc-simulator/internal/game/command"
+	"atc-simulator/internal/game/conflict"
+	"atc-simulator/internal/game/trajectory"
+	"atc-simulator/pkg/types"
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Probe is where an aircraft would fly if a clearance were issued now, and
+// the losses of separation with other traffic it would lead to.
+type Probe struct {
+	Callsign     types.AircraftID
+	Instructions string // canonical command text, e.g. "A 24000"
+	Trajectory   *trajectory.Trajectory
+	Conflicts    []conflict.Predicted // in time order
+}
+
+// isProbeable reports whether the clearance changes where the aircraft flies,
+// so that it can be tried out with ProbeCommand.
+func isProbeable(cmd command.Command) bool {
+	switch cmd.(type) {
+	case command.Heading, command.Altitude, command.Speed, command.DirectTo, command.Land,
+		command.SID, command.STAR, command.ClimbVia, command.DescendVia,
+		command.Reroute, command.InsertFix, command.DeleteFix, command.ResumeNavigation, command.ChangeDestination:
+		return true
+	}
+	return false
+}
+
+// ProbeCommand tries out a command line the way ExecuteCommand would issue
+// it, on a ghost of the addressed aircraft: the clearances are carried out at
+// once, its trajectory is predicted for predictionHorizonSeconds and compared
+// with the trajectories of all other traffic. Nothing in the simulation
+// changes and nothing is transmitted.
+func (s *Simulation) ProbeCommand(positions []string, selected types.AircraftID, cmd string) (*Probe, error) {
+	line, err := command.Parse(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	aircraftID := line.Callsign
+	if aircraftID == "" {
+		if selected == "" {
+			return nil, reject(RejectUnknownAircraft, "no aircraft selected")
+		}
+		aircraftID = selected
+	}
+	ac, exists := s.Aircrafts[aircraftID]
+	if !exists {
+		return nil, command.Wrap(line.CallsignPos, reject(RejectUnknownAircraft, "aircraft %s not found", aircraftID))
+	}
+
+	texts := make([]string, len(line.Commands))
+	for i, c := range line.Commands {
+		if !isProbeable(c) {
+			return nil, command.Wrap(c.Pos(), fmt.Errorf("%s cannot be probed, only clearances that change where the aircraft flies", c.Name()))
+		}
+		if err := s.checkAuthority(positions, ac, c.Name()); err != nil {
+			return nil, command.Wrap(c.Pos(), err)
+		}
+		if err := s.validateClearance(ac, c); err != nil {
+			return nil, command.Wrap(c.Pos(), err)
+		}
+		texts[i] = c.String()
+	}
+
+	// The instructions act on the aircraft by callsign, so the ghost stands
+	// in for it while they are carried out.
+	ghost := ac.Ghost()
+	s.Aircrafts[aircraftID] = ghost
+	defer func() { s.Aircrafts[aircraftID] = ac }()
+	for _, c := range line.Commands {
+		if err := s.executeInstruction(positions, ghost, c); err != nil {
+			return nil, command.Wrap(c.Pos(), err)
+		}
+	}
+
+	probe := &Probe{
+		Callsign:     aircraftID,
+		Instructions: strings.Join(texts, " "),
+		Trajectory:   s.predictedTrajectory(ghost, predictionHorizonSeconds),
+	}
+	probe.Conflicts = s.probeTrajectory(probe.Trajectory)
+	return probe, nil
+}
+
+// probeTrajectory returns the first loss of separation between a trajectory
+// and that of each other aircraft, in time order. The others are predicted
+// afresh so that all trajectories cover the same time.
+func (s *Simulation) probeTrajectory(tr *trajectory.Trajectory) []conflict.Predicted {
+	var conflicts []conflict.Predicted
+	for _, id := range s.aircraftIDs() {
+		if id == tr.Callsign {
+			continue
+		}
+		if c, ok := conflict.ProbeTrajectories(tr, s.predictedTrajectory(s.Aircrafts[id], predictionHorizonSeconds)); ok {
+			conflicts = append(conflicts, c)
+		}
+	}
+	slices.SortStableFunc(conflicts, func(a, b conflict.Predicted) int {
+		return cmp.Compare(a.Time, b.Time)
+	})
+	return conflicts
+}
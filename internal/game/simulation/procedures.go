@@ -0,0 +1,257 @@
+package simulation
+
+import (
+	"atc-simulator/internal/game/aircraft"
+	"atc-simulator/internal/game/airspace"
+	"atc-simulator/internal/game/flightplan"
+	"atc-simulator/pkg/types"
+	"log"
+	"maps"
+	"math"
+	"slices"
+)
+
+// Terminal fixes of the Kempegowda procedures. Arrivals end at IBVOR for
+// runway 09 and OSKAM for runway 27, departures start at DOKEL off 09 and
+// GOMOR off 27.
+var kempegowdaFixes = map[string]types.Vec2{
+	"IBVOR": types.NewVec2(80, 384),
+	"OSKAM": types.NewVec2(950, 384),
+	"NOSEL": types.NewVec2(260, 230),
+	"SOLUS": types.NewVec2(170, 560),
+	"NIKAT": types.NewVec2(560, 220),
+	"RUMIT": types.NewVec2(1000, 250),
+	"PALSO": types.NewVec2(760, 600),
+	"DOKEL": types.NewVec2(420, 384),
+	"GOMOR": types.NewVec2(600, 384),
+	"TAVAK": types.NewVec2(420, 200),
+	"MABIN": types.NewVec2(420, 560),
+	"RIMAN": types.NewVec2(640, 200),
+	"KUSOR": types.NewVec2(640, 560),
+}
+
+var unrestricted = flightplan.Constraint{}
+
+func at(v float64) flightplan.Constraint {
+	return flightplan.Constraint{Restriction: flightplan.At, Value: v}
+}
+
+func atOrAbove(v float64) flightplan.Constraint {
+	return flightplan.Constraint{Restriction: flightplan.AtOrAbove, Value: v}
+}
+
+func atOrBelow(v float64) flightplan.Constraint {
+	return flightplan.Constraint{Restriction: flightplan.AtOrBelow, Value: v}
+}
+
+func between(lower, upper float64) flightplan.Constraint {
+	return flightplan.Constraint{Restriction: flightplan.Between, Value: lower, Upper: upper}
+}
+
+func fix(waypoint string, altitude, speed flightplan.Constraint) airspace.ProcedureFix {
+	return airspace.ProcedureFix{Waypoint: waypoint, Altitude: altitude, Speed: speed}
+}
+
+// kempegowdaProcedures are named after the fix where they begin (STARs) or
+// end (SIDs). The letter is the runway: A arrives on 09, B on 27, D departs
+// from 09, E from 27.
+var kempegowdaProcedures = []airspace.Procedure{
+	{Name: "APIPO1A", Kind: airspace.STAR, AirportID: "KBLR", Runway: "RWY09", Fixes: []airspace.ProcedureFix{
+		fix("APIPO", unrestricted, unrestricted), fix("NOSEL", atOrBelow(10000), atOrBelow(250)), fix("IBVOR", at(4000), atOrBelow(210))}},
+	{Name: "BISKET1A", Kind: airspace.STAR, AirportID: "KBLR", Runway: "RWY09", Fixes: []airspace.ProcedureFix{
+		fix("BISKET", unrestricted, unrestricted), fix("NIKAT", between(12000, 14000), unrestricted), fix("NOSEL", atOrBelow(10000), atOrBelow(250)), fix("IBVOR", at(4000), atOrBelow(210))}},
+	{Name: "EMETI1A", Kind: airspace.STAR, AirportID: "KBLR", Runway: "RWY09", Fixes: []airspace.ProcedureFix{
+		fix("EMETI", unrestricted, unrestricted), fix("SOLUS", atOrBelow(10000), atOrBelow(250)), fix("IBVOR", at(4000), atOrBelow(210))}},
+	{Name: "FILKA1A", Kind: airspace.STAR, AirportID: "KBLR", Runway: "RWY09", Fixes: []airspace.ProcedureFix{
+		fix("FILKA", unrestricted, unrestricted), fix("SOLUS", atOrBelow(10000), atOrBelow(250)), fix("IBVOR", at(4000), atOrBelow(210))}},
+
+	{Name: "APIPO1B", Kind: airspace.STAR, AirportID: "KBLR", Runway: "RWY27", Fixes: []airspace.ProcedureFix{
+		fix("APIPO", unrestricted, unrestricted), fix("NIKAT", between(12000, 14000), unrestricted), fix("RUMIT", atOrBelow(10000), atOrBelow(250)), fix("OSKAM", at(4000), atOrBelow(210))}},
+	{Name: "BISKET1B", Kind: airspace.STAR, AirportID: "KBLR", Runway: "RWY27", Fixes: []airspace.ProcedureFix{
+		fix("BISKET", unrestricted, unrestricted), fix("RUMIT", atOrBelow(10000), atOrBelow(250)), fix("OSKAM", at(4000), atOrBelow(210))}},
+	{Name: "EMETI1B", Kind: airspace.STAR, AirportID: "KBLR", Runway: "RWY27", Fixes: []airspace.ProcedureFix{
+		fix("EMETI", unrestricted, unrestricted), fix("PALSO", atOrBelow(10000), atOrBelow(250)), fix("OSKAM", at(4000), atOrBelow(210))}},
+	{Name: "FILKA1B", Kind: airspace.STAR, AirportID: "KBLR", Runway: "RWY27", Fixes: []airspace.ProcedureFix{
+		fix("FILKA", unrestricted, unrestricted), fix("PALSO", atOrBelow(10000), atOrBelow(250)), fix("OSKAM", at(4000), atOrBelow(210))}},
+
+	{Name: "APIPO1D", Kind: airspace.SID, AirportID: "KBLR", Runway: "RWY09", Fixes: []airspace.ProcedureFix{
+		fix("DOKEL", atOrBelow(5000), atOrBelow(250)), fix("TAVAK", atOrAbove(6000), unrestricted), fix("APIPO", atOrAbove(10000), unrestricted)}},
+	{Name: "BISKET1D", Kind: airspace.SID, AirportID: "KBLR", Runway: "RWY09", Fixes: []airspace.ProcedureFix{
+		fix("DOKEL", atOrBelow(5000), atOrBelow(250)), fix("BISKET", atOrAbove(10000), unrestricted)}},
+	{Name: "EMETI1D", Kind: airspace.SID, AirportID: "KBLR", Runway: "RWY09", Fixes: []airspace.ProcedureFix{
+		fix("DOKEL", atOrBelow(5000), atOrBelow(250)), fix("MABIN", atOrAbove(6000), unrestricted), fix("EMETI", atOrAbove(10000), unrestricted)}},
+	{Name: "FILKA1D", Kind: airspace.SID, AirportID: "KBLR", Runway: "RWY09", Fixes: []airspace.ProcedureFix{
+		fix("DOKEL", atOrBelow(5000), atOrBelow(250)), fix("FILKA", atOrAbove(10000), unrestricted)}},
+
+	{Name: "APIPO1E", Kind: airspace.SID, AirportID: "KBLR", Runway: "RWY27", Fixes: []airspace.ProcedureFix{
+		fix("GOMOR", atOrBelow(5000), atOrBelow(250)), fix("TAVAK", atOrAbove(6000), unrestricted), fix("APIPO", atOrAbove(10000), unrestricted)}},
+	{Name: "BISKET1E", Kind: airspace.SID, AirportID: "KBLR", Runway: "RWY27", Fixes: []airspace.ProcedureFix{
+		fix("GOMOR", atOrBelow(5000), atOrBelow(250)), fix("RIMAN", atOrAbove(6000), unrestricted), fix("BISKET", atOrAbove(10000), unrestricted)}},
+	{Name: "EMETI1E", Kind: airspace.SID, AirportID: "KBLR", Runway: "RWY27", Fixes: []airspace.ProcedureFix{
+		fix("GOMOR", atOrBelow(5000), atOrBelow(250)), fix("MABIN", atOrAbove(6000), unrestricted), fix("EMETI", atOrAbove(10000), unrestricted)}},
+	{Name: "FILKA1E", Kind: airspace.SID, AirportID: "KBLR", Runway: "RWY27", Fixes: []airspace.ProcedureFix{
+		fix("GOMOR", atOrBelow(5000), atOrBelow(250)), fix("KUSOR", atOrAbove(6000), unrestricted), fix("FILKA", atOrAbove(10000), unrestricted)}},
+}
+
+// addKempegowdaProcedures publishes the SIDs and STARs of KBLR and their
+// fixes.
+func addKempegowdaProcedures(asp *airspace.Airspace) {
+	for _, name := range slices.Sorted(maps.Keys(kempegowdaFixes)) {
+		asp.Waypoints[name] = &types.Waypoint{Name: name, Position: kempegowdaFixes[name]}
+	}
+	for _, p := range kempegowdaProcedures {
+		p.Fixes = slices.Clone(p.Fixes)
+		if err := asp.AddProcedure(p); err != nil {
+			log.Printf("WARNING: %v", err)
+		}
+	}
+}
+
+// activeRunway is the runway of the airport most nearly into the wind, used
+// by arrivals and departures alike.
+func (s *Simulation) activeRunway(airport *airspace.Airport) *airspace.Runway {
+	var best *airspace.Runway
+	bestDiff := math.Inf(1)
+	for _, name := range slices.Sorted(maps.Keys(airport.Runways)) {
+		rwy := airport.Runways[name]
+		diff := math.Abs(math.Mod(rwy.Heading-s.Weather.WindDirection+540, 360) - 180)
+		if diff < bestDiff {
+			best, bestDiff = rwy, diff
+		}
+	}
+	return best
+}
+
+// arrivalProcedure returns the STAR to the airport's runway in use that
+// begins at the entry fix, or nil.
+func (s *Simulation) arrivalProcedure(airportID, entry string) *airspace.Procedure {
+	airport, ok := s.Airspace.Airports[airportID]
+	if !ok {
+		return nil
+	}
+	rwy := s.activeRunway(airport)
+	if rwy == nil {
+		return nil
+	}
+	for _, p := range airport.ProceduresFor(airspace.STAR, rwy.Name) {
+		if p.Fixes[0].Waypoint == entry {
+			return p
+		}
+	}
+	return nil
+}
+
+// checkProcedure returns the named procedure if the aircraft may be cleared
+// for it: a SID only from the airport it departed.
+func (s *Simulation) checkProcedure(ac *aircraft.Aircraft, kind airspace.ProcedureKind, name string) (*airspace.Procedure, error) {
+	proc, ok := s.Airspace.Procedure(name)
+	if !ok || proc.Kind != kind {
+		return nil, reject(RejectUnknownProcedure, "%s %s not found", airspace.ProcedureKindStringMap[kind], name)
+	}
+	switch {
+	case ac.State == aircraft.APPROACH || ac.State == aircraft.LANDED:
+		return nil, reject(RejectNotReady, "%s is on approach", ac.ID)
+	case kind == airspace.SID && ac.FlightPlan.OriginAirportID != proc.AirportID:
+		return nil, reject(RejectNotReady, "%s did not depart %s", ac.ID, proc.AirportID)
+	}
+	return proc, nil
+}
+
+// checkAssignProcedure returns the flight plan of the aircraft cleared for a
+// SID or STAR, and the fix it joins the procedure at. It joins at the first
+// of the procedure's fixes still ahead on the route, or else flies to its
+// first fix; the rest of the old route is dropped. A STAR ends in an approach
+// to its runway.
+func (s *Simulation) checkAssignProcedure(ac *aircraft.Aircraft, kind airspace.ProcedureKind, name string) (*flightplan.FlightPlan, string, error) {
+	proc, err := s.checkProcedure(ac, kind, name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	plan := ac.FlightPlan.Clone()
+	altitude, speed := ac.TargetAltitude, ac.TargetSpeed
+	if current := plan.CurrentSegment(); current != nil {
+		altitude, speed = current.TargetAltitude, current.TargetSpeed
+	}
+
+	join, from := min(plan.CurrentSegmentIndex, len(plan.Route)), 0
+	for i := join; i < len(plan.Route); i++ {
+		if j := proc.FixIndex(plan.Route[i].WaypointName); j >= 0 {
+			join, from = i, j
+			break
+		}
+	}
+
+	plan.Route = append(plan.Route[:join], proc.Segments(from, altitude, speed)...)
+	if kind == airspace.STAR {
+		plan.Route = append(plan.Route, starLanding(proc))
+		plan.DestinationAirportID = proc.AirportID
+	}
+	plan.CurrentSegmentIndex = min(plan.CurrentSegmentIndex, len(plan.Route))
+	if err := s.checkPlan(plan); err != nil {
+		return nil, "", err
+	}
+	return plan, proc.Fixes[from].Waypoint, nil
+}
+
+// AssignProcedure clears the aircraft for a SID or STAR, see
+// checkAssignProcedure. Climbing or descending via the procedure takes a
+// clearance of its own.
+func (s *Simulation) AssignProcedure(aircraftID types.AircraftID, kind airspace.ProcedureKind, name string) error {
+	ac, err := s.controlledAircraft(aircraftID)
+	if err != nil {
+		return err
+	}
+	plan, join, err := s.checkAssignProcedure(ac, kind, name)
+	if err != nil {
+		return err
+	}
+	setRoute(ac, plan)
+	ac.DirectToWaypoint = nil // back on own navigation
+	ac.Via = false
+	ac.Logf("%s cleared %s %s, joining at %s", ac.ID, airspace.ProcedureKindStringMap[kind], name, join)
+	return nil
+}
+
+// viaProcedure returns the procedure of the given kind the aircraft is flying.
+func (s *Simulation) viaProcedure(ac *aircraft.Aircraft, kind airspace.ProcedureKind) (*airspace.Procedure, error) {
+	if current := ac.FlightPlan.CurrentSegment(); current != nil && current.Procedure != "" {
+		if proc, ok := s.Airspace.Procedure(current.Procedure); ok && proc.Kind == kind {
+			return proc, nil
+		}
+	}
+	return nil, reject(RejectNotReady, "%s is not on a %s", ac.ID, airspace.ProcedureKindStringMap[kind])
+}
+
+// ClearVia lets the aircraft climb (SID) or descend (STAR) by itself to meet
+// the constraints of the procedure it is on, as far as feet. Without feet a
+// departure climbs to its filed level and an arrival descends to the last
+// altitude of its STAR. Any other altitude or a heading cancels it. Assigned
+// altitudes and speeds end, so the published ones apply again.
+func (s *Simulation) ClearVia(aircraftID types.AircraftID, kind airspace.ProcedureKind, feet float64) error {
+	ac, err := s.controlledAircraft(aircraftID)
+	if err != nil {
+		return err
+	}
+	if _, err := s.viaProcedure(ac, kind); err != nil {
+		return err
+	}
+	if feet < 0 {
+		return reject(RejectInvalidValue, "invalid altitude value: %.0f. Must be positive", feet)
+	}
+
+	if feet == 0 {
+		fp := ac.FlightPlan
+		feet = fp.CurrentSegment().TargetAltitude
+		if kind == airspace.STAR {
+			for _, seg := range fp.Route[fp.CurrentSegmentIndex:] {
+				if seg.Procedure != "" && seg.Altitude.Restriction != flightplan.Unrestricted {
+					feet = seg.Altitude.Value
+				}
+			}
+		}
+	}
+	ac.Via, ac.ViaAltitude = true, feet
+	ac.AltitudeAssigned, ac.SpeedAssigned = false, false
+	return nil
+}
@@ -0,0 +1,102 @@
+package simulation
+
+import (
+	"atc-simulator/pkg/types"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RadioLogFormats are the formats WriteRadioLog writes.
+var RadioLogFormats = []string{"txt", "csv", "json"}
+
+// RadioFilter selects radio messages. The zero value selects all.
+type RadioFilter struct {
+	Callsign   types.AircraftID // transmissions by or naming the aircraft
+	UrgentOnly bool
+}
+
+func (f RadioFilter) Match(msg RadioMessage) bool {
+	if f.UrgentOnly && !msg.IsUrgent {
+		return false
+	}
+	if f.Callsign != "" && msg.Callsign != f.Callsign && !strings.Contains(msg.Message, string(f.Callsign)) {
+		return false
+	}
+	return true
+}
+
+// Filter returns the messages the filter selects.
+func (f RadioFilter) Filter(msgs []RadioMessage) []RadioMessage {
+	if f == (RadioFilter{}) {
+		return msgs
+	}
+	var out []RadioMessage
+	for _, msg := range msgs {
+		if f.Match(msg) {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
+
+// FormatGameTime formats seconds of game time as T+hh:mm:ss.
+func FormatGameTime(seconds float64) string {
+	d := time.Duration(seconds) * time.Second
+	return fmt.Sprintf("T+%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
+
+// WriteRadioLog writes a transcript of msgs for debriefing: as text, one line
+// per transmission, as CSV, or as a JSON array.
+func WriteRadioLog(w io.Writer, format string, msgs []RadioMessage) error {
+	switch format {
+	case "txt":
+		for _, msg := range msgs {
+			text := msg.Message
+			if msg.Blocked {
+				text += " [BLOCKED]"
+			}
+			callsign := string(msg.Callsign)
+			if msg.IsUrgent {
+				callsign = "+" + callsign
+			}
+			if _, err := fmt.Fprintf(w, "%s [%s] %s %s: %s\n", FormatGameTime(msg.GameTime), msg.Timestamp.Format("15:04:05"), msg.Frequency, callsign, text); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"id", "game_time", "time", "frequency", "callsign", "urgent", "blocked", "message"})
+		for _, msg := range msgs {
+			cw.Write([]string{
+				strconv.Itoa(msg.ID),
+				strconv.FormatFloat(msg.GameTime, 'f', 2, 64),
+				msg.Timestamp.Format(time.RFC3339),
+				msg.Frequency,
+				string(msg.Callsign),
+				strconv.FormatBool(msg.IsUrgent),
+				strconv.FormatBool(msg.Blocked),
+				msg.Message,
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+
+	case "json":
+		if msgs == nil {
+			msgs = []RadioMessage{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(msgs)
+
+	default:
+		return fmt.Errorf("unknown radio log format %q, want one of %s", format, strings.Join(RadioLogFormats, ", "))
+	}
+}
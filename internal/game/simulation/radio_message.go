@@ -1,27 +1,99 @@
 package simulation
 
 import (
+	"atc-simulator/internal/game/aircraft"
+	"atc-simulator/internal/game/airspace"
 	"atc-simulator/pkg/types"
+	"log"
+	"sort"
 	"time"
 )
 
+// RadioMessage is one transmission. The log keeps every transmission of the
+// session.
 type RadioMessage struct {
+	ID        int
 	Timestamp time.Time
+	GameTime  float64 // seconds since the session started
+	Frequency string
 	Callsign  types.AircraftID
 	Message   string
 	IsUrgent  bool
+	Blocked   bool // stepped on by another station, not heard
 }
 
+// AddRadioMessage queues a transmission by an aircraft on the frequency it is
+// tuned to. It goes out once the frequency is free, see updateRadio.
 func (s *Simulation) AddRadioMessage(callsign types.AircraftID, message string, isUrgent bool) {
+	s.queueCall(callsign, message, isUrgent)
+}
+
+func (s *Simulation) queueCall(callsign types.AircraftID, message string, isUrgent bool) *RadioCall {
+	call := &RadioCall{
+		Frequency: s.frequencyOf(callsign),
+		Callsign:  callsign,
+		Message:   message,
+		IsUrgent:  isUrgent,
+		QueuedAt:  s.GameTimeSeconds,
+	}
+	s.RadioQueue = append(s.RadioQueue, call)
+	return call
+}
+
+// AddATCMessage transmits a controller call to an aircraft on the frequency it
+// is tuned to. It reports whether the call got through; it is blocked when the
+// controller keys up over a pilot.
+func (s *Simulation) AddATCMessage(to types.AircraftID, message string, isUrgent bool) bool {
+	return s.transmitATC(s.frequencyOf(to), message, isUrgent)
+}
+
+// frequencyOf returns the frequency an aircraft is tuned to, or "" if unknown.
+func (s *Simulation) frequencyOf(aircraftID types.AircraftID) string {
+	ac, ok := s.Aircrafts[aircraftID]
+	if !ok {
+		return ""
+	}
+	if ac.Frequency != "" {
+		return ac.Frequency
+	}
+	if sec, ok := s.Airspace.Sectors[ac.ControllingSector]; ok {
+		return sec.Frequency
+	}
+	return ""
+}
+
+// assignSector puts the aircraft under the sector's control and tunes it to
+// the sector's frequency.
+func (s *Simulation) assignSector(ac *aircraft.Aircraft, sec *airspace.Sector) {
+	ac.ControllingSector = sec.Name
+	ac.Frequency = sec.Frequency
+}
+
+func (s *Simulation) transmit(frequency string, callsign types.AircraftID, message string, isUrgent, blocked bool) {
+	s.nextRadioMessageID++
 	msg := RadioMessage{
-		Timestamp: time.Now(),
+		ID:        s.nextRadioMessageID,
+		Timestamp: s.TimeOfDay,
+		GameTime:  s.GameTimeSeconds,
+		Frequency: frequency,
 		Callsign:  callsign,
 		Message:   message,
 		IsUrgent:  isUrgent,
+		Blocked:   blocked,
 	}
 	s.RadioLog = append(s.RadioLog, msg)
 
-	if len(s.RadioLog) > s.maxRadioLogSize {
-		s.RadioLog = s.RadioLog[len(s.RadioLog)-s.maxRadioLogSize:]
+	if blocked {
+		s.BlockedTransmissions++
+		log.Printf("RADIO: %s blocked on %s: %s", callsign, frequency, message)
+	}
+}
+
+// RadioMessagesSince returns the logged messages with an ID greater than afterID.
+func (s *Simulation) RadioMessagesSince(afterID int) []RadioMessage {
+	i := sort.Search(len(s.RadioLog), func(i int) bool { return s.RadioLog[i].ID > afterID })
+	if i == len(s.RadioLog) {
+		return nil
 	}
+	return s.RadioLog[i:]
 }
@@ -0,0 +1,182 @@
+package simulation
+
+import (
+	"atc-simulator/internal/game/aircraft"
+	"atc-simulator/internal/game/airspace"
+	"atc-simulator/internal/game/command"
+	"atc-simulator/internal/game/phraseology"
+	"atc-simulator/pkg/types"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+)
+
+// PendingClearance is a clearance the pilot has heard but not yet read back.
+// The aircraft only acts on it after the readback.
+type PendingClearance struct {
+	Callsign     types.AircraftID
+	Instructions string   // canonical command text, e.g. "H 270 A 12000"
+	Phrases      []string // as transmitted, and as the pilot will read them back
+	IssuedAt     float64
+	ReadbackAt   float64
+
+	// ReadbackError is set when the pilot misheard, Callsign, Instructions
+	// and Phrases are then what was misheard.
+	ReadbackError *ReadbackError `json:",omitempty"`
+}
+
+// isClearance reports whether the pilot reads the instruction back and flies
+// it, as opposed to coordination between controllers.
+func isClearance(cmd command.Command) bool {
+	switch cmd.(type) {
+	case command.Heading, command.Altitude, command.Speed, command.DirectTo, command.Land, command.Handoff, command.FrequencyChange, command.Approve,
+		command.SID, command.STAR, command.ClimbVia, command.DescendVia,
+		command.Reroute, command.InsertFix, command.DeleteFix, command.ResumeNavigation, command.ChangeDestination:
+		return true
+	}
+	return false
+}
+
+// validateClearance checks what can be checked before the clearance is
+// transmitted, so the controller hears about it straight away.
+func (s *Simulation) validateClearance(ac *aircraft.Aircraft, cmd command.Command) error {
+	switch c := cmd.(type) {
+	case command.DirectTo:
+		if _, ok := s.Airspace.Waypoints[c.Waypoint]; !ok {
+			return reject(RejectUnknownWaypoint, "waypoint %s not found", c.Waypoint)
+		}
+	case command.Land:
+		if s.findRunway(c.Runway) == nil {
+			return reject(RejectUnknownRunway, "runway %s not found", c.Runway)
+		}
+	case command.Handoff:
+		if !handoffReady(ac) {
+			return reject(RejectNotReady, "%s is not ready for handoff", ac.ID)
+		}
+	case command.FrequencyChange:
+		return s.checkFrequencyChange(ac, c.Frequency)
+	case command.Approve:
+		return s.validateApproval(ac)
+	case command.SID:
+		_, _, err := s.checkAssignProcedure(ac, airspace.SID, c.Procedure)
+		return err
+	case command.STAR:
+		_, _, err := s.checkAssignProcedure(ac, airspace.STAR, c.Procedure)
+		return err
+	case command.Reroute:
+		_, err := s.checkReroute(ac, c.Route)
+		return err
+	case command.InsertFix:
+		_, err := s.checkInsertFix(ac, c.Waypoint, c.After)
+		return err
+	case command.DeleteFix:
+		_, err := s.checkDeleteFix(ac, c.Waypoint)
+		return err
+	case command.ChangeDestination:
+		_, err := s.checkDestination(ac, c.Destination)
+		return err
+	}
+	return nil
+}
+
+// transmitClearance puts the controller's call on the radio and schedules the
+// pilot's readback after the pilot response latency. In realism mode the
+// pilot may mishear it, see garbleClearance.
+func (s *Simulation) transmitClearance(ac *aircraft.Aircraft, clearances []command.Command) {
+	clearances = s.approvedClearances(ac, clearances)
+	if len(clearances) == 0 {
+		return
+	}
+	phrases := s.phrase(ac, clearances)
+	if !s.AddATCMessage(ac.ID, phraseology.Transmission(string(ac.ID), phrases), false) {
+		return // blocked, the pilot heard nothing
+	}
+	s.checkHearback(ac, clearances)
+	s.grantRequests(ac, clearances)
+
+	reader, heard, kind, garbled := s.garbleClearance(ac, clearances)
+	texts := make([]string, len(heard))
+	for i, c := range heard {
+		texts[i] = c.String()
+	}
+
+	// Pilots take a little more or less than the nominal latency to answer.
+	latency := s.PilotLatencySeconds * (0.75 + 0.5*s.rng.Float64())
+	pc := &PendingClearance{
+		Callsign:     reader.ID,
+		Instructions: strings.Join(texts, " "),
+		Phrases:      phrases,
+		IssuedAt:     s.GameTimeSeconds,
+		ReadbackAt:   s.GameTimeSeconds + latency,
+	}
+	if garbled {
+		pc.Phrases = s.phrase(ac, heard)
+		pc.ReadbackError = &ReadbackError{
+			Kind:      kind,
+			Addressee: ac.ID,
+			Callsign:  reader.ID,
+			Expected:  strings.Join(phrases, ", "),
+			ReadBack:  strings.Join(pc.Phrases, ", "),
+			Wrong:     wrongValue(kind, heard),
+		}
+	}
+	s.PendingClearances = append(s.PendingClearances, pc)
+}
+
+// updateClearances reads back and carries out clearances whose latency has
+// passed, in the order they were issued.
+func (s *Simulation) updateClearances() {
+	remaining := s.PendingClearances[:0]
+	for _, pc := range s.PendingClearances {
+		if pc.ReadbackAt > s.GameTimeSeconds {
+			remaining = append(remaining, pc)
+			continue
+		}
+		if ac, ok := s.Aircrafts[pc.Callsign]; ok {
+			s.readBack(ac, pc)
+		}
+	}
+	s.PendingClearances = remaining
+}
+
+// readBack queues the pilot's readback. The clearance is carried out once the
+// readback is on the air.
+func (s *Simulation) readBack(ac *aircraft.Aircraft, pc *PendingClearance) {
+	call := s.queueCall(ac.ID, phraseology.Readback(string(ac.ID), pc.Phrases), false)
+	call.Clearance = pc
+}
+
+func (s *Simulation) carryOut(ac *aircraft.Aircraft, pc *PendingClearance) {
+	if rb := pc.ReadbackError; rb != nil {
+		rb.ReadAt = s.GameTimeSeconds
+		rb.Deadline = s.GameTimeSeconds + s.HearbackWindowSeconds
+		s.ReadbackErrors = append(s.ReadbackErrors, rb)
+		log.Printf("HEARBACK: %s read back %q, cleared %q", ac.ID, rb.ReadBack, rb.Expected)
+	}
+
+	line, err := command.Parse(pc.Instructions)
+	if err != nil {
+		log.Printf("READBACK: cannot parse %q for %s: %v", pc.Instructions, ac.ID, err)
+		return
+	}
+	for _, c := range line.Commands {
+		if err := s.executeInstruction(nil, ac, c); err != nil {
+			// Things changed while the pilot was answering.
+			log.Printf("READBACK: %s unable to %s: %v", ac.ID, c, err)
+			s.respondToRejection(ac, err)
+			return
+		}
+	}
+	s.predict(ac)
+}
+
+// SetPilotLatency sets the nominal time pilots take to read back a clearance.
+func (s *Simulation) SetPilotLatency(seconds float64) error {
+	if math.IsNaN(seconds) || seconds < 0 || seconds > 30 {
+		return fmt.Errorf("invalid pilot latency %.1f, must be between 0 and 30 seconds", seconds)
+	}
+	s.PilotLatencySeconds = seconds
+	log.Printf("INSTRUCTOR: pilot latency set to %.1fs", seconds)
+	return nil
+}
@@ -0,0 +1,151 @@
+package simulation
+
+import (
+	"atc-simulator/pkg/types"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"time"
+)
+
+const keyframeIntervalSeconds = 30
+
+type EventKind string
+
+const (
+	EventCommand    EventKind = "command"
+	EventInstructor EventKind = "instructor"
+	EventStaff      EventKind = "staff"
+	EventUnstaff    EventKind = "unstaff"
+)
+
+// RecordingHeader opens every recording.
+type RecordingHeader struct {
+	Version   int
+	Seed      uint64
+	Scenario  string
+	TickRate  float64
+	StartedAt time.Time // wall clock, for the debrief only
+}
+
+// RecordedEvent is an input to the simulation. An event recorded at tick N
+// was applied after N updates and before update N+1.
+type RecordedEvent struct {
+	Tick            int
+	GameTimeSeconds float64
+	Kind            EventKind
+	Positions       []string         `json:",omitempty"`
+	Selected        types.AircraftID `json:",omitempty"`
+	Text            string           `json:",omitempty"`
+}
+
+// recordLine is one line of a recording file. Exactly one field is set.
+type recordLine struct {
+	Header   *RecordingHeader `json:",omitempty"`
+	Event    *RecordedEvent   `json:",omitempty"`
+	Keyframe *Snapshot        `json:",omitempty"`
+}
+
+// Recorder writes a session as JSON lines: a header, then keyframes and
+// events in the order they happened.
+type Recorder struct {
+	w            *bufio.Writer
+	closer       io.Closer
+	enc          *json.Encoder
+	lastKeyframe float64
+	err          error
+}
+
+func (r *Recorder) write(line recordLine) {
+	if r.err != nil {
+		return
+	}
+	if err := r.enc.Encode(line); err != nil {
+		r.err = err
+	} else {
+		r.err = r.w.Flush()
+	}
+	if r.err != nil {
+		log.Printf("RECORDER: write failed, recording stopped: %v", r.err)
+	}
+}
+
+// StartRecording writes the session header and an initial keyframe to w, then
+// records every input and a keyframe every 30 seconds of game time.
+func (s *Simulation) StartRecording(w io.WriteCloser) error {
+	if s.recorder != nil {
+		return fmt.Errorf("already recording")
+	}
+
+	bw := bufio.NewWriter(w)
+	r := &Recorder{w: bw, closer: w, enc: json.NewEncoder(bw)}
+	r.write(recordLine{Header: &RecordingHeader{
+		Version:   snapshotVersion,
+		Seed:      s.Seed,
+		Scenario:  s.Scenario,
+		TickRate:  s.TickRate,
+		StartedAt: time.Now(),
+	}})
+	if r.err != nil {
+		return r.err
+	}
+
+	s.recorder = r
+	s.recordKeyframe()
+	log.Printf("RECORDER: recording started at tick %d", s.Ticks)
+	return r.err
+}
+
+// StopRecording writes a final keyframe, marking the end of the session, and
+// closes the recording.
+func (s *Simulation) StopRecording() error {
+	r := s.recorder
+	if r == nil {
+		return nil
+	}
+	s.recordKeyframe()
+	s.recorder = nil
+
+	if err := r.closer.Close(); r.err == nil {
+		r.err = err
+	}
+	log.Printf("RECORDER: recording stopped at tick %d", s.Ticks)
+	return r.err
+}
+
+func (s *Simulation) IsRecording() bool {
+	return s.recorder != nil
+}
+
+func (s *Simulation) recordEvent(kind EventKind, positions []string, selected types.AircraftID, text string) {
+	if s.recorder == nil {
+		return
+	}
+	s.recorder.write(recordLine{Event: &RecordedEvent{
+		Tick:            s.Ticks,
+		GameTimeSeconds: s.GameTimeSeconds,
+		Kind:            kind,
+		Positions:       positions,
+		Selected:        selected,
+		Text:            text,
+	}})
+}
+
+func (s *Simulation) recordKeyframe() {
+	snap, err := s.Snapshot()
+	if err != nil {
+		log.Printf("RECORDER: failed to snapshot tick %d: %v", s.Ticks, err)
+		return
+	}
+	s.recorder.write(recordLine{Keyframe: snap})
+	s.recorder.lastKeyframe = s.GameTimeSeconds
+}
+
+func (s *Simulation) recordKeyframeIfDue() {
+	if s.recorder == nil || s.GameTimeSeconds-s.recorder.lastKeyframe < keyframeIntervalSeconds {
+		return
+	}
+	s.recordKeyframe()
+}
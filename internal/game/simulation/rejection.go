@@ -0,0 +1,83 @@
+package simulation
+
+import (
+	"atc-simulator/internal/game/aircraft"
+	"atc-simulator/internal/game/command"
+	"errors"
+	"fmt"
+)
+
+type RejectReason int
+
+const (
+	RejectSyntax RejectReason = iota
+	RejectUnknownAircraft
+	RejectNotInControl
+	RejectInvalidValue
+	RejectUnknownWaypoint
+	RejectUnknownRunway
+	RejectNotReady
+	RejectNoRequest
+	RejectUnknownProcedure
+	RejectInvalidRoute
+)
+
+var RejectReasonStringMap = map[RejectReason]string{
+	RejectSyntax:           "SYNTAX",
+	RejectUnknownAircraft:  "UNKNOWN_AIRCRAFT",
+	RejectNotInControl:     "NOT_IN_CONTROL",
+	RejectInvalidValue:     "INVALID_VALUE",
+	RejectUnknownWaypoint:  "UNKNOWN_WAYPOINT",
+	RejectUnknownRunway:    "UNKNOWN_RUNWAY",
+	RejectNotReady:         "NOT_READY",
+	RejectNoRequest:        "NO_REQUEST",
+	RejectUnknownProcedure: "UNKNOWN_PROCEDURE",
+	RejectInvalidRoute:     "INVALID_ROUTE",
+}
+
+// Rejection is the error returned when an instruction cannot be carried out.
+type Rejection struct {
+	Reason  RejectReason
+	Message string
+}
+
+func (r *Rejection) Error() string {
+	return r.Message
+}
+
+func reject(reason RejectReason, format string, args ...any) *Rejection {
+	return &Rejection{Reason: reason, Message: fmt.Sprintf(format, args...)}
+}
+
+// RejectReasonOf classifies an error returned by ExecuteCommand. Parse errors
+// are RejectSyntax; errors that are not rejections report false.
+func RejectReasonOf(err error) (RejectReason, bool) {
+	var rej *Rejection
+	if errors.As(err, &rej) {
+		return rej.Reason, true
+	}
+	if errors.Is(err, command.ErrInvalidValue) {
+		return RejectInvalidValue, true
+	}
+	var cmdErr *command.Error
+	if errors.As(err, &cmdErr) && cmdErr.Err == nil {
+		return RejectSyntax, true
+	}
+	return 0, false
+}
+
+// respondToRejection has the pilot answer an instruction that could not be
+// carried out: garbled or impossible values get a "say again", instructions
+// the aircraft is not in a position to follow an "unable".
+func (s *Simulation) respondToRejection(ac *aircraft.Aircraft, err error) {
+	reason, ok := RejectReasonOf(err)
+	if !ok {
+		return
+	}
+	switch reason {
+	case RejectSyntax, RejectInvalidValue, RejectUnknownWaypoint, RejectUnknownRunway, RejectNoRequest, RejectUnknownProcedure, RejectInvalidRoute:
+		s.AddRadioMessage(ac.ID, fmt.Sprintf("Say again, %s.", ac.ID), false)
+	case RejectNotReady:
+		s.AddRadioMessage(ac.ID, fmt.Sprintf("Unable, %s.", ac.ID), false)
+	}
+}
@@ -0,0 +1,182 @@
+package simulation
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Recording is a session loaded back from a recorder's output.
+type Recording struct {
+	Header    RecordingHeader
+	Events    []RecordedEvent
+	keyframes []keyframe
+}
+
+// keyframe keeps the encoded snapshot so every seek restores a fresh copy.
+// events counts the events recorded before it, which the snapshot already
+// includes.
+type keyframe struct {
+	tick   int
+	events int
+	data   json.RawMessage
+}
+
+func LoadRecording(r io.Reader) (*Recording, error) {
+	rec := &Recording{}
+	sawHeader := false
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		var line struct {
+			Header   *RecordingHeader
+			Event    *RecordedEvent
+			Keyframe json.RawMessage
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+
+		switch {
+		case line.Header != nil:
+			if sawHeader {
+				return nil, fmt.Errorf("line %d: duplicate header", lineNo)
+			}
+			if line.Header.Version != snapshotVersion {
+				return nil, fmt.Errorf("unsupported recording version %d", line.Header.Version)
+			}
+			rec.Header = *line.Header
+			sawHeader = true
+		case !sawHeader:
+			return nil, fmt.Errorf("line %d: recording does not start with a header", lineNo)
+		case line.Event != nil:
+			rec.Events = append(rec.Events, *line.Event)
+		case line.Keyframe != nil:
+			var tick struct{ Ticks int }
+			if err := json.Unmarshal(line.Keyframe, &tick); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			rec.keyframes = append(rec.keyframes, keyframe{tick: tick.Ticks, events: len(rec.Events), data: line.Keyframe})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(rec.keyframes) == 0 {
+		return nil, fmt.Errorf("recording has no keyframes")
+	}
+	return rec, nil
+}
+
+func (rec *Recording) StartTick() int {
+	return rec.keyframes[0].tick
+}
+
+// EndTick is the last tick the recording covers.
+func (rec *Recording) EndTick() int {
+	end := rec.keyframes[len(rec.keyframes)-1].tick
+	if len(rec.Events) > 0 {
+		end = max(end, rec.Events[len(rec.Events)-1].Tick)
+	}
+	return end
+}
+
+// Replay re-runs a recording by restoring keyframes and feeding the recorded
+// events back into the simulation at the ticks they were issued.
+type Replay struct {
+	rec       *Recording
+	sim       *Simulation
+	nextEvent int
+}
+
+func NewReplay(rec *Recording) (*Replay, error) {
+	r := &Replay{rec: rec}
+	if err := r.Seek(rec.StartTick()); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Simulation returns the simulation being replayed. It is replaced on every
+// seek, so callers must not hold on to it.
+func (r *Replay) Simulation() *Simulation {
+	return r.sim
+}
+
+func (r *Replay) Recording() *Recording {
+	return r.rec
+}
+
+func (r *Replay) Tick() int {
+	return r.sim.Ticks
+}
+
+func (r *Replay) Done() bool {
+	return r.sim.Ticks >= r.rec.EndTick()
+}
+
+// Step applies the events due at the current tick and advances the simulation
+// by one tick. It returns false once the recording is exhausted.
+func (r *Replay) Step() bool {
+	if r.Done() {
+		return false
+	}
+	r.applyEvents()
+	if r.sim.Paused {
+		// Paused with nothing left to apply at this tick: the session sat
+		// paused until the recording ended.
+		return false
+	}
+	r.sim.Update(1.0 / r.sim.TickRate)
+	return true
+}
+
+func (r *Replay) applyEvents() {
+	for r.nextEvent < len(r.rec.Events) && r.rec.Events[r.nextEvent].Tick <= r.sim.Ticks {
+		ev := r.rec.Events[r.nextEvent]
+		r.nextEvent++
+
+		// Rejected commands were rejected in the original session too, the
+		// error is part of what is being replayed.
+		switch ev.Kind {
+		case EventCommand:
+			r.sim.ExecuteCommand(ev.Positions, ev.Selected, ev.Text)
+		case EventInstructor:
+			r.sim.ExecuteInstructorCommand(ev.Text)
+		case EventStaff:
+			r.sim.StaffPosition(ev.Text)
+		case EventUnstaff:
+			r.sim.UnstaffPosition(ev.Text)
+		}
+	}
+}
+
+// Seek moves the replay to tick, restoring the nearest keyframe at or before
+// it and replaying forward from there.
+func (r *Replay) Seek(tick int) error {
+	tick = min(max(tick, r.rec.StartTick()), r.rec.EndTick())
+
+	i := sort.Search(len(r.rec.keyframes), func(i int) bool {
+		return r.rec.keyframes[i].tick > tick
+	}) - 1
+
+	kf := r.rec.keyframes[i]
+
+	var snap Snapshot
+	if err := json.Unmarshal(kf.data, &snap); err != nil {
+		return fmt.Errorf("keyframe at tick %d: %w", kf.tick, err)
+	}
+	sim, err := RestoreSimulation(&snap)
+	if err != nil {
+		return fmt.Errorf("keyframe at tick %d: %w", snap.Ticks, err)
+	}
+
+	r.sim = sim
+	r.nextEvent = kf.events
+	for r.sim.Ticks < tick && r.Step() {
+	}
+	return nil
+}
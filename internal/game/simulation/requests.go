@@ -0,0 +1,414 @@
+package simulation
+
+import (
+	"atc-simulator/internal/game/aircraft"
+	"atc-simulator/internal/game/command"
+	"atc-simulator/internal/game/phraseology"
+	"atc-simulator/pkg/types"
+	"fmt"
+	"log"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+)
+
+type RequestKind int
+
+const (
+	RequestHigher RequestKind = iota
+	RequestLower
+	RequestDirect
+	RequestWeatherDeviation
+	RequestRunway
+	RequestSpeed
+	RequestLanding
+)
+
+var RequestKindStringMap = map[RequestKind]string{
+	RequestHigher:           "HIGHER",
+	RequestLower:            "LOWER",
+	RequestDirect:           "DIRECT",
+	RequestWeatherDeviation: "WEATHER",
+	RequestRunway:           "RUNWAY",
+	RequestSpeed:            "SPEED",
+	RequestLanding:          "LANDING",
+}
+
+type RequestState int
+
+const (
+	RequestPending RequestState = iota
+	RequestApproved
+	RequestDenied
+	RequestUnable
+)
+
+var RequestStateStringMap = map[RequestState]string{
+	RequestPending:  "PENDING",
+	RequestApproved: "APPROVED",
+	RequestDenied:   "DENIED",
+	RequestUnable:   "UNABLE",
+}
+
+const (
+	requestIntervalSeconds = 150 // mean time between requests from one aircraft
+	requestCooldownSeconds = 60  // after an answer before the crew asks again
+	requestPatienceSeconds = 30  // before an unanswered request is repeated
+	maxRequestReminders    = 2   // then the crew gives up, as if denied
+
+	satisfactionApproved = 5
+	satisfactionUnable   = -2
+	satisfactionDenied   = -5
+	satisfactionIgnored  = -10
+)
+
+// PilotRequest is something a crew has asked their controller for.
+// Clearance is what approving it clears the aircraft for, in command syntax.
+type PilotRequest struct {
+	ID         int
+	Callsign   types.AircraftID
+	Sector     string // the request is withdrawn when the aircraft leaves it
+	Kind       RequestKind
+	Clearance  string // e.g. "A 26000"
+	Phrase     string // e.g. "climb to flight level two six zero"
+	State      RequestState
+	RaisedAt   float64
+	AnsweredAt float64
+	Reminders  int
+}
+
+// PendingRequest returns the aircraft's open request, if any.
+func (s *Simulation) PendingRequest(aircraftID types.AircraftID) *PilotRequest {
+	for _, req := range s.PilotRequests {
+		if req.Callsign == aircraftID && req.State == RequestPending {
+			return req
+		}
+	}
+	return nil
+}
+
+// updateRequests raises new requests from aircraft worked by controllers,
+// repeats ignored ones and withdraws those of aircraft that left the sector.
+// Requests are forgotten once the aircraft is gone.
+func (s *Simulation) updateRequests(dt float64) {
+	requests := s.PilotRequests[:0]
+	for _, req := range s.PilotRequests {
+		ac, ok := s.Aircrafts[req.Callsign]
+		if !ok || req.State == RequestPending && (ac.ControllingSector != req.Sector || ac.State == aircraft.LANDED) {
+			continue
+		}
+		requests = append(requests, req)
+		if req.State == RequestPending && s.GameTimeSeconds-req.RaisedAt > float64(req.Reminders+1)*requestPatienceSeconds {
+			s.remind(ac, req)
+		}
+	}
+	s.PilotRequests = requests
+
+	for _, id := range s.aircraftIDs() {
+		ac := s.Aircrafts[id]
+		if ac.State == aircraft.LANDED || !s.IsSectorStaffed(ac.ControllingSector) || !s.readyToRequest(ac) {
+			continue
+		}
+		if req := s.landingRequest(ac); req != nil {
+			s.raiseRequest(ac, req)
+			continue
+		}
+		if s.rng.Float64() < dt/requestIntervalSeconds {
+			if req := s.randomRequest(ac); req != nil {
+				s.raiseRequest(ac, req)
+			}
+		}
+	}
+}
+
+// readyToRequest reports whether the crew has nothing open and has not been
+// answered too recently.
+func (s *Simulation) readyToRequest(ac *aircraft.Aircraft) bool {
+	for _, req := range slices.Backward(s.PilotRequests) {
+		if req.Callsign != ac.ID {
+			continue
+		}
+		return req.State != RequestPending && s.GameTimeSeconds-req.AnsweredAt > requestCooldownSeconds
+	}
+	return true
+}
+
+// landingRequest asks for landing clearance once established inbound to the
+// runway.
+func (s *Simulation) landingRequest(ac *aircraft.Aircraft) *PilotRequest {
+	if ac.State != aircraft.APPROACH || ac.ClearedForLanding || ac.LandingRunway == nil {
+		return nil
+	}
+	headingDiff := math.Abs(ac.Heading - ac.LandingRunway.Heading)
+	if headingDiff > 180 {
+		headingDiff = 360 - headingDiff
+	}
+	if ac.Altitude >= 5000 || headingDiff >= 30 {
+		return nil
+	}
+	runway := s.runwayKey(ac)
+	return &PilotRequest{
+		Kind:      RequestLanding,
+		Clearance: "LAND " + runway,
+		Phrase:    "landing clearance " + phraseology.Runway(runway),
+	}
+}
+
+// randomRequest picks one of the requests that make sense for the aircraft
+// right now.
+func (s *Simulation) randomRequest(ac *aircraft.Aircraft) *PilotRequest {
+	var candidates []*PilotRequest
+	level := ac.TargetAltitude == ac.Altitude
+	onApproach := ac.State == aircraft.APPROACH || ac.ClearedForLanding
+
+	if level && !onApproach && ac.Altitude >= 10000 && ac.Altitude <= 37000 {
+		feet := math.Round(ac.Altitude/1000)*1000 + 2000
+		candidates = append(candidates, &PilotRequest{
+			Kind:      RequestHigher,
+			Clearance: fmt.Sprintf("A %.0f", feet),
+			Phrase:    "climb to " + phraseology.Level(feet),
+		})
+	}
+	if level && !onApproach && ac.Altitude >= 8000 {
+		feet := math.Round(ac.Altitude/1000)*1000 - 2000
+		candidates = append(candidates, &PilotRequest{
+			Kind:      RequestLower,
+			Clearance: fmt.Sprintf("A %.0f", feet),
+			Phrase:    "descent to " + phraseology.Level(feet),
+		})
+	}
+	if fp := ac.FlightPlan; fp != nil && !onApproach && fp.CurrentSegmentIndex+1 < len(fp.Route) {
+		if last := fp.Route[len(fp.Route)-1]; last.WaypointName != "" {
+			if _, ok := s.Airspace.Waypoints[last.WaypointName]; ok {
+				candidates = append(candidates, &PilotRequest{
+					Kind:      RequestDirect,
+					Clearance: "D " + last.WaypointName,
+					Phrase:    "direct " + last.WaypointName,
+				})
+			}
+		}
+	}
+	if !onApproach {
+		offset := 20.0
+		side := "right"
+		if s.rng.IntN(2) == 0 {
+			offset, side = -offset, "left"
+		}
+		heading := math.Mod(math.Round(ac.Heading/10)*10+offset+360, 360)
+		candidates = append(candidates, &PilotRequest{
+			Kind:      RequestWeatherDeviation,
+			Clearance: fmt.Sprintf("H %03.0f", heading),
+			Phrase:    fmt.Sprintf("two zero degrees %s for weather, heading %s", side, phraseology.Digits(int(heading), 3)),
+		})
+
+		knots := math.Round(ac.Speed/10)*10 + float64(20*(1+s.rng.IntN(2)))
+		if s.rng.IntN(2) == 0 && ac.Speed > 220 {
+			knots = math.Round(ac.Speed/10)*10 - 20
+		}
+		candidates = append(candidates, &PilotRequest{
+			Kind:      RequestSpeed,
+			Clearance: fmt.Sprintf("S %.0f", knots),
+			Phrase:    fmt.Sprintf("speed %s knots", phraseology.Digits(int(knots), 3)),
+		})
+	}
+	if ac.LandingRunway != nil && !ac.ClearedForLanding {
+		if runway := s.otherRunway(ac); runway != "" {
+			candidates = append(candidates, &PilotRequest{
+				Kind:      RequestRunway,
+				Clearance: "LAND " + runway,
+				Phrase:    phraseology.Runway(runway),
+			})
+		}
+	}
+
+	candidates = slices.DeleteFunc(candidates, func(req *PilotRequest) bool {
+		return s.refused(ac.ID, req.Kind)
+	})
+	if len(candidates) == 0 {
+		return nil
+	}
+	return candidates[s.rng.IntN(len(candidates))]
+}
+
+// refused reports whether the controller has already said no outright to a
+// request of this kind from the aircraft.
+func (s *Simulation) refused(aircraftID types.AircraftID, kind RequestKind) bool {
+	return slices.ContainsFunc(s.PilotRequests, func(req *PilotRequest) bool {
+		return req.Callsign == aircraftID && req.Kind == kind && req.State == RequestDenied
+	})
+}
+
+// runwayKey returns the name the aircraft's landing runway is known by.
+func (s *Simulation) runwayKey(ac *aircraft.Aircraft) string {
+	if airport, ok := s.Airspace.Airports[ac.LandingRunway.AirportID]; ok {
+		for _, name := range slices.Sorted(maps.Keys(airport.Runways)) {
+			if airport.Runways[name] == ac.LandingRunway {
+				return name
+			}
+		}
+	}
+	return ac.LandingRunway.Name
+}
+
+// otherRunway returns another runway at the airport the aircraft is landing
+// at, or "".
+func (s *Simulation) otherRunway(ac *aircraft.Aircraft) string {
+	airport, ok := s.Airspace.Airports[ac.LandingRunway.AirportID]
+	if !ok {
+		return ""
+	}
+	for _, name := range slices.Sorted(maps.Keys(airport.Runways)) {
+		if airport.Runways[name] != ac.LandingRunway {
+			return name
+		}
+	}
+	return ""
+}
+
+func (s *Simulation) raiseRequest(ac *aircraft.Aircraft, req *PilotRequest) {
+	s.nextRequestID++
+	req.ID = s.nextRequestID
+	req.Callsign = ac.ID
+	req.Sector = ac.ControllingSector
+	req.State = RequestPending
+	req.RaisedAt = s.GameTimeSeconds
+	s.PilotRequests = append(s.PilotRequests, req)
+
+	s.AddRadioMessage(ac.ID, fmt.Sprintf("%s, request %s.", ac.ID, req.Phrase), false)
+	log.Printf("REQUEST: %s requests %s (%s)", ac.ID, RequestKindStringMap[req.Kind], req.Clearance)
+}
+
+// remind has the crew chase an unanswered request, and give up on it after
+// a few tries.
+func (s *Simulation) remind(ac *aircraft.Aircraft, req *PilotRequest) {
+	req.Reminders++
+	adjustSatisfaction(ac, satisfactionIgnored)
+	if req.Reminders > maxRequestReminders {
+		s.closeRequest(req, RequestDenied)
+		log.Printf("REQUEST: %s gave up on %s", ac.ID, RequestKindStringMap[req.Kind])
+		return
+	}
+	s.AddRadioMessage(ac.ID, fmt.Sprintf("%s, still waiting on %s.", ac.ID, req.Phrase), false)
+}
+
+func (s *Simulation) closeRequest(req *PilotRequest, state RequestState) {
+	req.State = state
+	req.AnsweredAt = s.GameTimeSeconds
+}
+
+func adjustSatisfaction(ac *aircraft.Aircraft, delta float64) {
+	ac.Satisfaction = max(0, min(100, ac.Satisfaction+delta))
+}
+
+// approvedClearances replaces APPROVE with the clearance the pending request
+// asked for.
+func (s *Simulation) approvedClearances(ac *aircraft.Aircraft, clearances []command.Command) []command.Command {
+	var expanded []command.Command
+	for _, c := range clearances {
+		if _, ok := c.(command.Approve); !ok {
+			expanded = append(expanded, c)
+			continue
+		}
+		req := s.PendingRequest(ac.ID)
+		if req == nil {
+			continue
+		}
+		line, err := command.Parse(req.Clearance)
+		if err != nil {
+			log.Printf("REQUEST: cannot parse %q for %s: %v", req.Clearance, ac.ID, err)
+			continue
+		}
+		expanded = append(expanded, line.Commands...)
+	}
+	return expanded
+}
+
+// validateApproval checks that there is a request to approve and that its
+// clearance can be given.
+func (s *Simulation) validateApproval(ac *aircraft.Aircraft) error {
+	req := s.PendingRequest(ac.ID)
+	if req == nil {
+		return reject(RejectNoRequest, "%s has no pending request", ac.ID)
+	}
+	line, err := command.Parse(req.Clearance)
+	if err != nil {
+		return err
+	}
+	for _, c := range line.Commands {
+		if err := s.validateClearance(ac, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// grantRequests marks the aircraft's pending request approved when the
+// controller has cleared it for what it asked, whether with APPROVE or not.
+func (s *Simulation) grantRequests(ac *aircraft.Aircraft, clearances []command.Command) {
+	req := s.PendingRequest(ac.ID)
+	if req == nil {
+		return
+	}
+	given := make([]string, len(clearances))
+	for i, c := range clearances {
+		given[i] = c.String()
+	}
+	line, err := command.Parse(req.Clearance)
+	if err != nil {
+		return
+	}
+	for _, c := range line.Commands {
+		if !slices.Contains(given, c.String()) {
+			return
+		}
+	}
+	s.closeRequest(req, RequestApproved)
+	adjustSatisfaction(ac, satisfactionApproved)
+	log.Printf("REQUEST: %s %s approved", ac.ID, RequestKindStringMap[req.Kind])
+}
+
+// AnswerRequest turns down the aircraft's pending request, with UNABLE for
+// now or DENY for good.
+func (s *Simulation) AnswerRequest(aircraftID types.AircraftID, state RequestState) error {
+	ac, err := s.controlledAircraft(aircraftID)
+	if err != nil {
+		return err
+	}
+	req := s.PendingRequest(aircraftID)
+	if req == nil {
+		return reject(RejectNoRequest, "%s has no pending request", aircraftID)
+	}
+
+	answer := fmt.Sprintf("%s, unable %s.", ac.ID, req.Phrase)
+	delta := float64(satisfactionUnable)
+	if state == RequestDenied {
+		answer = fmt.Sprintf("%s, negative, %s not available.", ac.ID, req.Phrase)
+		delta = satisfactionDenied
+	}
+	if !s.AddATCMessage(ac.ID, answer, false) {
+		return nil // blocked, the request stays open
+	}
+	s.AddRadioMessage(ac.ID, fmt.Sprintf("Roger, %s.", ac.ID), false)
+
+	s.closeRequest(req, state)
+	adjustSatisfaction(ac, delta)
+	log.Printf("REQUEST: %s %s %s", ac.ID, RequestKindStringMap[req.Kind], strings.ToLower(RequestStateStringMap[state]))
+	return nil
+}
+
+// PilotSatisfaction is the average satisfaction of the crews in the air,
+// 100 when there are none.
+func (s *Simulation) PilotSatisfaction() float64 {
+	total, n := 0.0, 0
+	for _, ac := range s.Aircrafts {
+		if ac.State != aircraft.LANDED {
+			total += ac.Satisfaction
+			n++
+		}
+	}
+	if n == 0 {
+		return 100
+	}
+	return total / float64(n)
+}
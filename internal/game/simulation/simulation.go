@@ -5,43 +5,86 @@ import (
 	"atc-simulator/internal/game/airspace"
 	"atc-simulator/internal/game/conflict"
 	"atc-simulator/internal/game/flightplan"
+	"atc-simulator/internal/game/trajectory"
 	"atc-simulator/pkg/types"
 	"fmt"
 	"log"
-	"math/rand"
+	"maps"
+	"math/rand/v2"
 	"slices"
 	"time"
-
-	"github.com/hajimehoshi/ebiten/v2"
 )
 
 type Simulation struct {
 	Aircrafts       map[types.AircraftID]*aircraft.Aircraft
 	Airspace        *airspace.Airspace
+	Scenario        string
 	TickRate        float64
 	TimeOfDay       time.Time
 	GameTimeSeconds float64
+	Ticks           int
+	Paused          bool
+	Weather         Weather
+
+	Seed      uint64
+	rngSource *rand.PCG
+	rng       *rand.Rand
+	recorder  *Recorder
 
 	HandOffs       int
 	MissedHandoffs int
 	Conflicts      int
 	Landings       int
 
-	RadioLog        []RadioMessage
-	maxRadioLogSize int
-
-	lastSpawnTime        time.Time
-	spawnInterval        time.Duration
-	nextAircraftID       int
-	maxAircraftsOnScreen int
-	landingProbability   float64
-
-	WorldToScreen func(wx float64, wy float64) (sx float64, sy float64)
-	ScreenToWorld func(wx float64, wy float64) (sx float64, sy float64)
+	SectorTransfers int
+	HandoffOffers   map[types.AircraftID]*HandoffOffer
+	PointOuts       []*PointOut
+
+	staffedPositions        map[string]bool
+	handoffLookaheadSeconds float64
+	autoCoordinationSeconds float64
+	handoffRetrySeconds     float64
+
+	PendingClearances   []*PendingClearance
+	PilotLatencySeconds float64
+
+	ReadbackErrorRate     float64
+	HearbackWindowSeconds float64
+	ReadbackErrors        []*ReadbackError
+
+	PilotRequests []*PilotRequest
+	nextRequestID int
+
+	RadioLog             []RadioMessage
+	nextRadioMessageID   int
+	RadioQueue           []*RadioCall
+	Channels             map[string]*Channel
+	BlockedTransmissions int
+
+	// Predicted trajectories, see updatePredictions, and the landing
+	// sequences drawn from them, see sequenceArrivals.
+	Trajectories     map[types.AircraftID]*trajectory.Trajectory
+	ArrivalSequences []ArrivalSequence
+
+	// Departures waiting for a takeoff slot, see updateDepartures, and the
+	// wait of those that have taken off.
+	DepartureQueue           []*Departure
+	recentTakeoffs           []Departure
+	Departures               int
+	DepartureDelaySeconds    float64
+	MaxDepartureDelaySeconds float64
+
+	// What the traffic generator spawns, and for each flow how much of its
+	// rate is still to pass before its next aircraft, see generateTraffic.
+	Traffic     TrafficConfig
+	flowHazards map[string]float64
 }
 
-func NewSimulation(tickRate float64) *Simulation {
-	simpleAirspace := airspace.NewAirspace()
+// NewSimulation creates a simulation over a world of the given size in pixels.
+// All randomness is drawn from seed, so two simulations with the same seed fed
+// the same commands at the same ticks evolve identically.
+func NewSimulation(tickRate, worldWidth, worldHeight float64, seed uint64) *Simulation {
+	simpleAirspace := airspace.NewAirspace(worldWidth, worldHeight)
 
 	kiaAirport := airspace.Airport{
 		ID:       "KBLR",
@@ -49,7 +92,7 @@ func NewSimulation(tickRate float64) *Simulation {
 		Position: types.NewVec2(512, 384),
 		Runways: map[string]*airspace.Runway{
 			"RWY09": {Name: "RWY09", Threshold: types.NewVec2(200, 384), Heading: 90},
-			"RWY27": {Name: "RWY09", Threshold: types.NewVec2(824, 384), Heading: 270},
+			"RWY27": {Name: "RWY27", Threshold: types.NewVec2(824, 384), Heading: 270},
 		},
 	}
 
@@ -57,34 +100,67 @@ func NewSimulation(tickRate float64) *Simulation {
 		*kiaAirport.Runways["RWY09"],
 		*kiaAirport.Runways["RWY27"],
 	})
+	addKempegowdaProcedures(simpleAirspace)
+
+	rngSource := rand.NewPCG(seed, seed^0x9e3779b97f4a7c15)
 
 	s := &Simulation{
-		Aircrafts: make(map[types.AircraftID]*aircraft.Aircraft),
-		Airspace:  simpleAirspace,
-		TickRate:  tickRate,
-		TimeOfDay: time.Now(),
-
-		lastSpawnTime:        time.Now(),
-		spawnInterval:        20 * time.Second,
-		nextAircraftID:       100,
-		maxAircraftsOnScreen: 5,
-		maxRadioLogSize:      50,
-		landingProbability:   0.8,
+		Aircrafts:    make(map[types.AircraftID]*aircraft.Aircraft),
+		Trajectories: make(map[types.AircraftID]*trajectory.Trajectory),
+		Airspace:     simpleAirspace,
+		Scenario:     "default",
+		TickRate:     tickRate,
+		TimeOfDay:    time.Now(),
+
+		Seed:      seed,
+		rngSource: rngSource,
+		rng:       rand.New(rngSource),
+
+		Weather:     Weather{WindDirection: 270, WindSpeed: 0, VisibilityKm: 10},
+		Traffic:     DefaultTraffic(),
+		flowHazards: make(map[string]float64),
 
 		HandOffs:       0,
 		MissedHandoffs: 0,
 		Conflicts:      0,
+
+		Channels:                make(map[string]*Channel),
+		HandoffOffers:           make(map[types.AircraftID]*HandoffOffer),
+		staffedPositions:        make(map[string]bool),
+		handoffLookaheadSeconds: 90,
+		autoCoordinationSeconds: 8,
+		handoffRetrySeconds:     30,
+
+		PilotLatencySeconds:   2.5,
+		HearbackWindowSeconds: 15,
 	}
 
-	s.SpawnRandomAircraft()
+	// Something to work from the start, before the flows bring more.
+	if arrivals := s.Traffic.Arrivals; len(arrivals) > 0 {
+		s.spawnFlow(arrivals[s.rng.IntN(len(arrivals))], true)
+	}
 	return s
 }
 
 func (s *Simulation) Update(dt float64) {
+	if s.Paused {
+		return
+	}
+
 	s.GameTimeSeconds += dt
-	for id, ac := range s.Aircrafts {
+	s.Ticks++
+	s.updateClearances()
+	s.updateRadio()
+	s.updateHearback()
+	drift := s.Weather.windDrift(dt)
+	for _, id := range s.aircraftIDs() {
+		ac := s.Aircrafts[id]
 		ac.Update(dt)
 		ac.IsConflicting = false
+		if ac.State != aircraft.LANDED {
+			ac.Position.X += drift.X
+			ac.Position.Y += drift.Y
+		}
 
 		if ac.FlightPlan != nil && ac.FlightPlan.CurrentSegmentIndex >= len(ac.FlightPlan.Route) {
 			if ac.State == aircraft.LANDED {
@@ -121,44 +197,37 @@ func (s *Simulation) Update(dt float64) {
 			continue
 		}
 	}
+	s.updateHandoffs()
+	s.updateRequests(dt)
 	s.CheckForConflicts()
-	s.TimeOfDay = s.TimeOfDay.Add(time.Duration(dt*float64(time.Second)) * time.Second)
-
-	if len(s.Aircrafts) < s.maxAircraftsOnScreen {
-		s.lastSpawnTime = s.lastSpawnTime.Add(time.Duration(dt) * time.Second)
-		if time.Since(s.lastSpawnTime) > s.spawnInterval {
-			s.SpawnRandomAircraft()
-			s.lastSpawnTime = time.Now()
-		}
-	}
+	s.TimeOfDay = s.TimeOfDay.Add(time.Duration(dt * float64(time.Second)))
 
+	s.generateTraffic(dt)
 	s.CleanupAircraft()
+	s.updateDepartures()
+	s.updatePredictions(dt)
+	s.recordKeyframeIfDue()
 }
 
-func (s *Simulation) ClearLanding(aircraftID types.AircraftID, runwayName string) bool {
-	ac, ok := s.Aircrafts[aircraftID]
-	if !ok {
-		log.Printf("ClearLanding: Aircraft %s not found.", aircraftID)
-		return false
-	}
+// aircraftIDs returns the IDs of all aircraft in a stable order, so that
+// updates happen in the same sequence on every run.
+func (s *Simulation) aircraftIDs() []types.AircraftID {
+	return slices.Sorted(maps.Keys(s.Aircrafts))
+}
 
-	var targetRunway *airspace.Runway
-	for _, airport := range s.Airspace.Airports {
-		if rwy, found := airport.Runways[runwayName]; found {
-			targetRunway = rwy
-			break
-		}
+func (s *Simulation) ClearLanding(aircraftID types.AircraftID, runwayName string) error {
+	ac, err := s.controlledAircraft(aircraftID)
+	if err != nil {
+		return err
 	}
 
+	targetRunway := s.findRunway(runwayName)
 	if targetRunway == nil {
-		s.AddRadioMessage("ATC", fmt.Sprintf("Negative, %s, runway %s is not valid.", ac.ID, runwayName), true)
-		return false // Runway not found
+		return reject(RejectUnknownRunway, "runway %s not found", runwayName)
 	}
 
 	if ac.ClearedForLanding {
-		// Already cleared, confirm it
-		s.AddRadioMessage("ATC", fmt.Sprintf("Confirming landing clearance for %s on %s.", ac.ID, runwayName), false)
-		return true
+		return nil // Already cleared
 	}
 
 	landingSegment := flightplan.FlightPlanSegment{
@@ -171,14 +240,23 @@ func (s *Simulation) ClearLanding(aircraftID types.AircraftID, runwayName string
 
 	ac.FlightPlan.Route = []flightplan.FlightPlanSegment{landingSegment}
 	ac.FlightPlan.CurrentSegmentIndex = 0 // Reset to start new plan
+	ac.FlightPlan.DestinationAirportID = targetRunway.AirportID
 
 	ac.LandingRunway = targetRunway
 	ac.ClearedForLanding = true
-	ac.PreviousAltitudeRequest = false
-	ac.PreviousSpeedRequest = false
+	ac.Vectored = false
+	return nil
+}
 
-	s.AddRadioMessage("ATC", fmt.Sprintf("%s, cleared for ILS approach runway %s.", ac.ID, runwayName), false)
-	return true
+// findRunway returns the named runway of the first airport, in ID order, that
+// has one.
+func (s *Simulation) findRunway(runwayName string) *airspace.Runway {
+	for _, airportID := range slices.Sorted(maps.Keys(s.Airspace.Airports)) {
+		if rwy, found := s.Airspace.Airports[airportID].Runways[runwayName]; found {
+			return rwy
+		}
+	}
+	return nil
 }
 
 func (s *Simulation) LandAircraft(aircraftID types.AircraftID) {
@@ -199,23 +277,23 @@ func (s *Simulation) HandOffAircraft(aircraftID types.AircraftID) {
 
 func (s *Simulation) randomFloatInRange(minF, maxF float64) float64 {
 	fRange := maxF - minF
-	return minF + rand.Float64()*fRange
+	return minF + s.rng.Float64()*fRange
 }
 
-func (s *Simulation) SpawnRandomAircraft() {
-	// Define spawn points (e.g., edges of your 1024x768 screen)
-	screenWidth, screenHeight := ebiten.WindowSize()
-	minX, maxX := 100.0, float64(screenWidth)-100.0
-	minY, maxY := 100.0, float64(screenHeight)-100.0
+// spawnArrival brings an aircraft of the fleet type in from the edge of the
+// world through the entry fix: to land at a random airport, or to overfly
+// and leave by another fix.
+func (s *Simulation) spawnArrival(acID types.AircraftID, entryWpName string, landing bool, ft FleetType) {
+	// Define spawn points (e.g., edges of the world)
+	minX, maxX := 100.0, s.Airspace.Width-100.0
+	minY, maxY := 100.0, s.Airspace.Height-100.0
 
 	var startPos types.Vec2
-	acID := types.AircraftID(fmt.Sprintf("%s%03d", getRandomAirlinePrefix(), s.nextAircraftID))
-	s.nextAircraftID++
-	targetAlt := (float64(rand.Intn(20)) + 10) * 1000.0 // 10,000 to 30,000 ft
-	startSpeed := 200.0 + rand.Float64()*100.0          // 200-300 knots
+	targetAlt := (float64(s.rng.IntN(20)) + 10) * 1000.0 // 10,000 to 30,000 ft
+	startSpeed := ft.Speed
 
 	// Randomly choose an edge to spawn from
-	edge := rand.Intn(4) // 0: Top, 1: Right, 2: Bottom, 3: Left
+	edge := s.rng.IntN(4) // 0: Top, 1: Right, 2: Bottom, 3: Left
 	switch edge {
 	case 0: // Top
 		startPos = types.NewVec2(s.randomFloatInRange(minX, maxX), minY)
@@ -228,24 +306,18 @@ func (s *Simulation) SpawnRandomAircraft() {
 	}
 
 	var initialHeading float64
-	var entryWpName, exitWpName string
+	var exitWpName string
 
-	if len(s.Airspace.EntryWaypoints) == 0 {
-		log.Println("WARNING: No entry waypoints defined, spawining at generic location")
+	if entryWp, ok := s.Airspace.Waypoints[entryWpName]; !ok {
+		log.Printf("WARNING: entry waypoint %s not found, spawning at generic location", entryWpName)
+		initialHeading = s.randomFloatInRange(0.0, 360.0)
 	} else {
-		entryWpName = s.Airspace.EntryWaypoints[rand.Intn(len(s.Airspace.EntryWaypoints))]
-		entryWp, ok := s.Airspace.Waypoints[entryWpName]
-		if !ok {
-			log.Printf("WARNING: No entry waypoints defined, spawining at generic location")
-			initialHeading = s.randomFloatInRange(0.0, 360.0)
-		} else {
-			initialHeading = startPos.HeadingTo(entryWp.Position)
-		}
+		initialHeading = startPos.HeadingTo(entryWp.Position)
 	}
 
 	if len(s.Airspace.ExitWaypoints) > 0 {
 		for {
-			exitWpName = s.Airspace.ExitWaypoints[rand.Intn(len(s.Airspace.ExitWaypoints))]
+			exitWpName = s.Airspace.ExitWaypoints[s.rng.IntN(len(s.Airspace.ExitWaypoints))]
 			if exitWpName != entryWpName || len(s.Airspace.ExitWaypoints) == 1 {
 				break
 			}
@@ -262,16 +334,21 @@ func (s *Simulation) SpawnRandomAircraft() {
 		},
 	}
 
-	isLandingAircraft := rand.Float64() < s.landingProbability
-	if isLandingAircraft {
-		waypointNames := make([]string, 0, len(s.Airspace.Waypoints))
-		addedWaypoints := make([]string, 0)
-		for k := range s.Airspace.Waypoints {
-			waypointNames = append(waypointNames, k)
+	isLandingAircraft := landing
+	if !isLandingAircraft {
+		// Overflights file the airway joining their entry and exit, if any.
+		if aw := s.Airspace.AirwayBetween(entryWpName, exitWpName, targetAlt); aw != nil {
+			route := fmt.Sprintf("%s %s %s", entryWpName, aw.Name, exitWpName)
+			if segments, err := s.Airspace.ParseRoute(route, targetAlt, startSpeed); err == nil {
+				flightPlanSegments = segments[:len(segments)-1]
+			}
 		}
+	} else {
+		waypointNames := slices.Sorted(maps.Keys(s.Airspace.Waypoints))
+		addedWaypoints := make([]string, 0)
 		retries := 8
 		for len(flightPlanSegments) < 2 {
-			wpName := waypointNames[rand.Intn(len(waypointNames))]
+			wpName := waypointNames[s.rng.IntN(len(waypointNames))]
 			if wpName == entryWpName || wpName == exitWpName || slices.Contains(addedWaypoints, wpName) {
 				retries--
 				if retries <= 0 {
@@ -282,8 +359,8 @@ func (s *Simulation) SpawnRandomAircraft() {
 
 			flightPlanSegments = append(flightPlanSegments, flightplan.FlightPlanSegment{
 				WaypointName:   wpName,
-				TargetAltitude: targetAlt * (rand.Float64()/2 + 0.75),
-				TargetSpeed:    startSpeed * (rand.Float64()/2 + 0.75),
+				TargetAltitude: targetAlt * (s.rng.Float64()/2 + 0.75),
+				TargetSpeed:    startSpeed * (s.rng.Float64()/2 + 0.75),
 			})
 			addedWaypoints = append(addedWaypoints, wpName)
 		}
@@ -291,25 +368,27 @@ func (s *Simulation) SpawnRandomAircraft() {
 
 	fpLastSegment := flightplan.FlightPlanSegment{
 		WaypointName:   exitWpName,
-		TargetAltitude: targetAlt * (rand.Float64()/2 + 0.75),
-		TargetSpeed:    startSpeed * (rand.Float64()/2 + 0.75),
+		TargetAltitude: targetAlt * (s.rng.Float64()/2 + 0.75),
+		TargetSpeed:    startSpeed * (s.rng.Float64()/2 + 0.75),
 	}
 
 	if isLandingAircraft && len(s.Airspace.Airports) > 0 {
-		airportIDs := make([]string, 0, len(s.Airspace.Airports))
-		for id := range s.Airspace.Airports {
-			airportIDs = append(airportIDs, id)
-		}
+		airportIDs := slices.Sorted(maps.Keys(s.Airspace.Airports))
 
-		targetAirportID := airportIDs[rand.Intn(len(airportIDs))]
+		targetAirportID := airportIDs[s.rng.IntN(len(airportIDs))]
 		targetAirport := s.Airspace.Airports[targetAirportID]
 
-		runwaysNames := make([]string, 0, len(targetAirport.Runways))
-		for name := range targetAirport.Runways {
-			runwaysNames = append(runwaysNames, name)
+		runwaysNames := slices.Sorted(maps.Keys(targetAirport.Runways))
+		targetRunwayName := runwaysNames[s.rng.IntN(len(runwaysNames))]
+
+		// Arrivals that have a STAR from their entry fly it instead of the
+		// random waypoints.
+		if star := s.arrivalProcedure(targetAirportID, entryWpName); star != nil {
+			flightPlanSegments = star.Segments(0, targetAlt, startSpeed)
+			targetRunwayName = star.Runway
 		}
-		targetRunwayName := runwaysNames[rand.Intn(len(runwaysNames))]
 
+		fpLastSegment.WaypointName = ""
 		fpLastSegment.RunwayName = targetRunwayName
 		fpLastSegment.AirportID = targetAirportID
 		fpLastSegment.Type = flightplan.SegmentTypeLanding
@@ -320,11 +399,15 @@ func (s *Simulation) SpawnRandomAircraft() {
 	flightPlanSegments = append(flightPlanSegments, fpLastSegment)
 
 	flightPlan := &flightplan.FlightPlan{
-		OriginAirportID:      "RANDOM",
-		DestinationAirportID: fpLastSegment.WaypointName,
-		Callsign:             acID,
-		Route:                flightPlanSegments,
-		CurrentSegmentIndex:  0,
+		OriginAirportID:     "RANDOM",
+		Callsign:            acID,
+		Route:               flightPlanSegments,
+		CurrentSegmentIndex: 0,
+	}
+	flightPlan.DestinationAirportID = flightPlan.Destination()
+	if err := flightPlan.Validate(s.Airspace); err != nil {
+		log.Printf("WARNING: not spawning %s, invalid flight plan: %v", acID, err)
+		return
 	}
 
 	ac := aircraft.NewAircraft(
@@ -338,19 +421,19 @@ func (s *Simulation) SpawnRandomAircraft() {
 		s.Airspace,
 		s.AddRadioMessage,
 	)
+	ac.Type = ft.Type
+	ac.Wake, _ = wakeCategory(ft.Wake)
+	if sec := s.Airspace.SectorAt(startPos, targetAlt); sec != nil {
+		s.assignSector(ac, sec)
+	}
 	s.Aircrafts[acID] = ac
-	log.Printf("Spawned aircraft %s (Filed for %s) at %v, heading %.0f, speed %.0f, altitude %.0f", ac.ID, exitWpName, ac.Position, ac.Heading, ac.Speed, ac.Altitude)
-}
-
-func getRandomAirlinePrefix() string {
-	prefixes := []string{"AAL", "SWA", "DAL", "UAL", "JBU", "ASA", "FFT", "AI", "JAL"}
-	return prefixes[rand.Intn(len(prefixes))]
+	log.Printf("Spawned aircraft %s (Filed for %s) at %v, heading %.0f, speed %.0f, altitude %.0f", ac.ID, flightPlan.DestinationAirportID, ac.Position, ac.Heading, ac.Speed, ac.Altitude)
 }
 
 func (s *Simulation) CheckForConflicts() {
 	aircraftSlice := []*aircraft.Aircraft{}
-	for _, ac := range s.Aircrafts {
-		aircraftSlice = append(aircraftSlice, ac)
+	for _, id := range s.aircraftIDs() {
+		aircraftSlice = append(aircraftSlice, s.Aircrafts[id])
 	}
 
 	for i := 0; i < len(aircraftSlice); i++ {
@@ -367,24 +450,21 @@ func (s *Simulation) CheckForConflicts() {
 }
 
 func (s *Simulation) CleanupAircraft() {
-	screenWidth, screenHeight := ebiten.WindowSize()
 	buffer := 100.0
 
-	worldLeft, worldTop := s.ScreenToWorld(0, 0)
-	worldRight, worldBottom := s.ScreenToWorld(float64(screenWidth), float64(screenHeight))
-
-	worldMinX := worldLeft - buffer
-	worldMinY := worldRight + buffer
-	worldMaxX := worldTop - buffer
-	worldMaxY := worldBottom + buffer
+	worldMinX := -buffer
+	worldMinY := -buffer
+	worldMaxX := s.Airspace.Width + buffer
+	worldMaxY := s.Airspace.Height + buffer
 
-	for id, ac := range s.Aircrafts {
-		if time.Since(ac.SpawnTime) < time.Minute || ac.DirectToWaypoint != nil {
+	for _, id := range s.aircraftIDs() {
+		ac := s.Aircrafts[id]
+		if ac.AgeSeconds < 60 || ac.DirectToWaypoint != nil {
 			// skip cleanup for first 1 minute of ops (avoids unnecessary checks)
 			continue
 		}
 
-		if ac.Position.X < worldMinX || ac.Position.X > worldMaxX || ac.Position.Y < worldMinY || ac.Position.Y > worldMaxY || ac.State != aircraft.LANDED {
+		if ac.Position.X < worldMinX || ac.Position.X > worldMaxX || ac.Position.Y < worldMinY || ac.Position.Y > worldMaxY {
 			// Only count as missed handoff if it wasn't already handed off
 			// You'll need a mechanism to check if it was 'expected' to be handed off.
 			// For simplicity, for now, any exit without HandOffAircraft call is a "missed".
@@ -401,74 +481,101 @@ func (s *Simulation) CleanupAircraft() {
 	}
 }
 
+// controlledAircraft returns the aircraft if it exists and is worked by a
+// staffed sector.
+func (s *Simulation) controlledAircraft(aircraftID types.AircraftID) (*aircraft.Aircraft, error) {
+	ac, ok := s.Aircrafts[aircraftID]
+	if !ok {
+		return nil, reject(RejectUnknownAircraft, "aircraft %s not found", aircraftID)
+	}
+	if err := s.checkControl(ac); err != nil {
+		return nil, err
+	}
+	return ac, nil
+}
+
 func (s *Simulation) IssueHeading(aircraftID types.AircraftID, heading float64) error {
-	if ac, ok := s.Aircrafts[aircraftID]; ok {
-		ac.SetHeading(heading)
-		if ac.DirectToWaypoint != nil {
-			ac.DirectToWaypoint = nil
-		}
-		return nil
+	ac, err := s.controlledAircraft(aircraftID)
+	if err != nil {
+		return err
+	}
+	if heading < 0 || heading >= 360 {
+		return reject(RejectInvalidValue, "invalid heading value: %.0f. Must be 0-359", heading)
+	}
+	ac.SetHeading(heading)
+	if ac.DirectToWaypoint != nil {
+		ac.DirectToWaypoint = nil
 	}
-	return fmt.Errorf("aircraft %s not found", aircraftID)
+	ac.Vectored = true
+	ac.Via = false
+	return nil
 }
 
 func (s *Simulation) IssueAltitude(aircraftID types.AircraftID, altitude float64) error {
-	if ac, ok := s.Aircrafts[aircraftID]; ok {
-		ac.SetAltitude(altitude)
-		return nil
+	ac, err := s.controlledAircraft(aircraftID)
+	if err != nil {
+		return err
+	}
+	if altitude < 0 {
+		return reject(RejectInvalidValue, "invalid altitude value: %.0f. Must be positive", altitude)
 	}
-	return fmt.Errorf("aircraft %s not found", aircraftID)
+	ac.SetAltitude(altitude)
+	ac.AltitudeAssigned, ac.Via = true, false
+	return nil
 }
 
 func (s *Simulation) IssueSpeed(aircraftID types.AircraftID, speed float64) error {
-	if ac, ok := s.Aircrafts[aircraftID]; ok {
-		ac.SetSpeed(speed)
-		return nil
+	ac, err := s.controlledAircraft(aircraftID)
+	if err != nil {
+		return err
+	}
+	if speed < 0 {
+		return reject(RejectInvalidValue, "invalid speed value: %.0f. Must be positive", speed)
 	}
-	return fmt.Errorf("aircraft %s not found", aircraftID)
+	ac.SetSpeed(speed)
+	ac.SpeedAssigned = true
+	return nil
 }
 
 func (s *Simulation) IssueDirectTo(aircraftID types.AircraftID, wp *types.Waypoint) error {
-	if ac, ok := s.Aircrafts[aircraftID]; ok {
-		if ac.FlightPlan != nil && ac.FlightPlan.CurrentSegmentIndex < len(ac.FlightPlan.Route) {
-			wpIdx := -1
-			for i, r := range ac.FlightPlan.Route {
-				if r.WaypointName == wp.Name {
-					wpIdx = i
-					break
-				}
-			}
-
-			if wpIdx != -1 {
-				ac.FlightPlan.CurrentSegmentIndex = wpIdx
-			}
+	ac, err := s.controlledAircraft(aircraftID)
+	if err != nil {
+		return err
+	}
+	if wp == nil {
+		return reject(RejectUnknownWaypoint, "no waypoint given")
+	}
+	if ac.FlightPlan != nil && ac.FlightPlan.CurrentSegmentIndex < len(ac.FlightPlan.Route) {
+		// A fix ahead on the route skips the ones before it, any other
+		// becomes the next fix.
+		if i := ac.FlightPlan.RouteIndex(wp.Name); i >= 0 {
+			ac.FlightPlan.CurrentSegmentIndex = i
+		} else {
+			ac.FlightPlan.Insert(ac.FlightPlan.CurrentSegmentIndex, wp.Name)
 		}
-
-		ac.SetDirectTo(wp)
-		return nil
 	}
-	return fmt.Errorf("aircraft %s not found", aircraftID)
-}
 
-func (s *Simulation) ClearHandoff(aircraftID types.AircraftID) bool {
-	ac, ok := s.Aircrafts[aircraftID]
-	if !ok {
-		log.Printf("ClearHandoff: Aircraft %s not found.", aircraftID)
-		return false
-	}
+	ac.SetDirectTo(wp)
+	ac.Vectored = false
+	return nil
+}
 
-	if ac.FlightPlan == nil || ac.FlightPlan.CurrentSegmentIndex < len(ac.FlightPlan.Route) {
-		s.AddRadioMessage("ATC", fmt.Sprintf("Negative, %s, you are not ready for handoff.", ac.ID), true)
-		return false // Not at end of flight plan yet
+func (s *Simulation) ClearHandoff(aircraftID types.AircraftID) error {
+	ac, err := s.controlledAircraft(aircraftID)
+	if err != nil {
+		return err
 	}
 
-	if ac.ClearedForHandoff {
-		s.AddRadioMessage("ATC", fmt.Sprintf("Confirming handoff clearance for %s, you are already cleared.", ac.ID), false)
-		return true // Already cleared, no change
+	if !handoffReady(ac) {
+		return reject(RejectNotReady, "%s is not ready for handoff", ac.ID)
 	}
 
 	ac.ClearedForHandoff = true
+	return nil
+}
 
-	s.AddRadioMessage("ATC", fmt.Sprintf("%s, contact departure, good day.", ac.ID), false)
-	return true
+// handoffReady reports whether the aircraft has flown its whole plan and may
+// be cleared to leave the airspace.
+func handoffReady(ac *aircraft.Aircraft) bool {
+	return ac.FlightPlan != nil && ac.FlightPlan.CurrentSegmentIndex >= len(ac.FlightPlan.Route)
 }
@@ -0,0 +1,373 @@
+package simulation
+
+import (
+	"atc-simulator/internal/game/aircraft"
+	"atc-simulator/internal/game/airspace"
+	"atc-simulator/internal/game/flightplan"
+	"atc-simulator/internal/game/trajectory"
+	"atc-simulator/pkg/types"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+const snapshotVersion = 1
+
+// Snapshot is the complete, serializable state of a Simulation. Restoring a
+// snapshot and feeding it the same commands reproduces the original run.
+type Snapshot struct {
+	Version         int
+	Scenario        string
+	TickRate        float64
+	Ticks           int
+	GameTimeSeconds float64
+	TimeOfDay       time.Time
+	Paused          bool
+	Weather         Weather
+	Seed            uint64
+	RNGState        []byte
+
+	Airspace *airspace.Airspace
+	Aircraft []AircraftSnapshot
+
+	HandOffs         int
+	MissedHandoffs   int
+	Conflicts        int
+	Landings         int
+	SectorTransfers  int
+	HandoffOffers    []HandoffOffer
+	PointOuts        []PointOut
+	StaffedPositions []string
+
+	PendingClearances   []PendingClearance
+	PilotLatencySeconds float64
+
+	ReadbackErrorRate     float64
+	HearbackWindowSeconds float64
+	ReadbackErrors        []ReadbackError
+
+	PilotRequests []PilotRequest
+	NextRequestID int
+
+	RadioLog             []RadioMessage
+	NextRadioMessageID   int
+	RadioQueue           []RadioCall
+	Channels             map[string]Channel
+	BlockedTransmissions int
+
+	Trajectories []*trajectory.Trajectory
+
+	DepartureQueue           []Departure
+	RecentTakeoffs           []Departure
+	Departures               int
+	DepartureDelaySeconds    float64
+	MaxDepartureDelaySeconds float64
+
+	Traffic     *TrafficConfig // nil in snapshots from before the traffic generator
+	FlowHazards map[string]float64
+
+	HandoffLookaheadSeconds float64
+	AutoCoordinationSeconds float64
+	HandoffRetrySeconds     float64
+}
+
+// AircraftSnapshot is an aircraft with its pointers into the airspace replaced
+// by names, so it can be rewired on restore.
+type AircraftSnapshot struct {
+	*aircraft.Aircraft
+	LandingAirportID  string `json:",omitempty"`
+	LandingRunwayName string `json:",omitempty"`
+}
+
+// Snapshot captures the current state. The result shares no mutable data with
+// the simulation except the airspace, which does not change during a session.
+func (s *Simulation) Snapshot() (*Snapshot, error) {
+	rngState, err := s.rngSource.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	traffic := s.Traffic // replaced, never changed
+	snap := &Snapshot{
+		Version:         snapshotVersion,
+		Scenario:        s.Scenario,
+		TickRate:        s.TickRate,
+		Ticks:           s.Ticks,
+		GameTimeSeconds: s.GameTimeSeconds,
+		TimeOfDay:       s.TimeOfDay,
+		Paused:          s.Paused,
+		Weather:         s.Weather,
+		Seed:            s.Seed,
+		RNGState:        rngState,
+
+		Airspace: s.Airspace,
+
+		HandOffs:         s.HandOffs,
+		MissedHandoffs:   s.MissedHandoffs,
+		Conflicts:        s.Conflicts,
+		Landings:         s.Landings,
+		SectorTransfers:  s.SectorTransfers,
+		StaffedPositions: slices.Sorted(maps.Keys(s.staffedPositions)),
+
+		RadioLog:             slices.Clone(s.RadioLog),
+		NextRadioMessageID:   s.nextRadioMessageID,
+		Channels:             make(map[string]Channel),
+		BlockedTransmissions: s.BlockedTransmissions,
+
+		RecentTakeoffs:           slices.Clone(s.recentTakeoffs),
+		Departures:               s.Departures,
+		DepartureDelaySeconds:    s.DepartureDelaySeconds,
+		MaxDepartureDelaySeconds: s.MaxDepartureDelaySeconds,
+
+		Traffic:     &traffic,
+		FlowHazards: maps.Clone(s.flowHazards),
+
+		HandoffLookaheadSeconds: s.handoffLookaheadSeconds,
+		AutoCoordinationSeconds: s.autoCoordinationSeconds,
+		HandoffRetrySeconds:     s.handoffRetrySeconds,
+
+		PilotLatencySeconds:   s.PilotLatencySeconds,
+		ReadbackErrorRate:     s.ReadbackErrorRate,
+		HearbackWindowSeconds: s.HearbackWindowSeconds,
+		NextRequestID:         s.nextRequestID,
+	}
+
+	for _, id := range s.aircraftIDs() {
+		snap.Aircraft = append(snap.Aircraft, snapshotAircraft(s.Aircrafts[id]))
+		if tr, ok := s.Trajectories[id]; ok {
+			snap.Trajectories = append(snap.Trajectories, tr) // replaced, never changed
+		}
+	}
+	for _, id := range slices.Sorted(maps.Keys(s.HandoffOffers)) {
+		snap.HandoffOffers = append(snap.HandoffOffers, *s.HandoffOffers[id])
+	}
+	for _, po := range s.PointOuts {
+		snap.PointOuts = append(snap.PointOuts, *po)
+	}
+	for _, pc := range s.PendingClearances {
+		clone := *pc
+		clone.Phrases = slices.Clone(pc.Phrases)
+		if pc.ReadbackError != nil {
+			rb := *pc.ReadbackError
+			clone.ReadbackError = &rb
+		}
+		snap.PendingClearances = append(snap.PendingClearances, clone)
+	}
+	for _, rb := range s.ReadbackErrors {
+		snap.ReadbackErrors = append(snap.ReadbackErrors, *rb)
+	}
+	for _, req := range s.PilotRequests {
+		snap.PilotRequests = append(snap.PilotRequests, *req)
+	}
+	for _, call := range s.RadioQueue {
+		snap.RadioQueue = append(snap.RadioQueue, *call)
+	}
+	for _, dep := range s.DepartureQueue {
+		snap.DepartureQueue = append(snap.DepartureQueue, *dep)
+	}
+	for frequency, ch := range s.Channels {
+		clone := *ch
+		if ch.Call != nil {
+			call := *ch.Call
+			clone.Call = &call
+		}
+		snap.Channels[frequency] = clone
+	}
+	return snap, nil
+}
+
+func snapshotAircraft(ac *aircraft.Aircraft) AircraftSnapshot {
+	clone := *ac
+	if ac.FlightPlan != nil {
+		clone.FlightPlan = ac.FlightPlan.Clone()
+	}
+	if ac.DirectToWaypoint != nil {
+		wp := *ac.DirectToWaypoint
+		clone.DirectToWaypoint = &wp
+	}
+
+	snap := AircraftSnapshot{Aircraft: &clone}
+	if ac.LandingRunway != nil {
+		snap.LandingAirportID = ac.LandingRunway.AirportID
+		snap.LandingRunwayName = ac.LandingRunway.Name
+	}
+	return snap
+}
+
+// RestoreSimulation builds a simulation from a snapshot, which it takes
+// ownership of. Aircraft are rewired to the restored airspace and radio log.
+func RestoreSimulation(snap *Snapshot) (*Simulation, error) {
+	if snap.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", snap.Version)
+	}
+	if snap.Airspace == nil {
+		return nil, fmt.Errorf("snapshot has no airspace")
+	}
+
+	rngSource := &rand.PCG{}
+	if err := rngSource.UnmarshalBinary(snap.RNGState); err != nil {
+		return nil, fmt.Errorf("invalid RNG state: %w", err)
+	}
+
+	s := &Simulation{
+		Aircrafts:       make(map[types.AircraftID]*aircraft.Aircraft),
+		Airspace:        snap.Airspace,
+		Scenario:        snap.Scenario,
+		TickRate:        snap.TickRate,
+		TimeOfDay:       snap.TimeOfDay,
+		GameTimeSeconds: snap.GameTimeSeconds,
+		Ticks:           snap.Ticks,
+		Paused:          snap.Paused,
+		Weather:         snap.Weather,
+
+		Seed:      snap.Seed,
+		rngSource: rngSource,
+		rng:       rand.New(rngSource),
+
+		HandOffs:        snap.HandOffs,
+		MissedHandoffs:  snap.MissedHandoffs,
+		Conflicts:       snap.Conflicts,
+		Landings:        snap.Landings,
+		SectorTransfers: snap.SectorTransfers,
+		HandoffOffers:   make(map[types.AircraftID]*HandoffOffer),
+
+		staffedPositions:        make(map[string]bool),
+		handoffLookaheadSeconds: snap.HandoffLookaheadSeconds,
+		autoCoordinationSeconds: snap.AutoCoordinationSeconds,
+		handoffRetrySeconds:     snap.HandoffRetrySeconds,
+
+		PilotLatencySeconds:   snap.PilotLatencySeconds,
+		ReadbackErrorRate:     snap.ReadbackErrorRate,
+		HearbackWindowSeconds: snap.HearbackWindowSeconds,
+		nextRequestID:         snap.NextRequestID,
+
+		RadioLog:             snap.RadioLog,
+		nextRadioMessageID:   snap.NextRadioMessageID,
+		Channels:             make(map[string]*Channel),
+		BlockedTransmissions: snap.BlockedTransmissions,
+
+		Trajectories: make(map[types.AircraftID]*trajectory.Trajectory),
+
+		recentTakeoffs:           snap.RecentTakeoffs,
+		Departures:               snap.Departures,
+		DepartureDelaySeconds:    snap.DepartureDelaySeconds,
+		MaxDepartureDelaySeconds: snap.MaxDepartureDelaySeconds,
+
+		Traffic:     DefaultTraffic(),
+		flowHazards: make(map[string]float64),
+	}
+	if snap.Traffic != nil {
+		s.Traffic = *snap.Traffic
+	}
+	maps.Copy(s.flowHazards, snap.FlowHazards)
+
+	for _, positionID := range snap.StaffedPositions {
+		s.staffedPositions[positionID] = true
+	}
+	for i := range snap.HandoffOffers {
+		offer := snap.HandoffOffers[i]
+		s.HandoffOffers[offer.Callsign] = &offer
+	}
+	for i := range snap.PointOuts {
+		po := snap.PointOuts[i]
+		s.PointOuts = append(s.PointOuts, &po)
+	}
+	for i := range snap.PendingClearances {
+		pc := snap.PendingClearances[i]
+		s.PendingClearances = append(s.PendingClearances, &pc)
+	}
+	for i := range snap.ReadbackErrors {
+		rb := snap.ReadbackErrors[i]
+		s.ReadbackErrors = append(s.ReadbackErrors, &rb)
+	}
+	for i := range snap.PilotRequests {
+		req := snap.PilotRequests[i]
+		s.PilotRequests = append(s.PilotRequests, &req)
+	}
+	for i := range snap.RadioQueue {
+		call := snap.RadioQueue[i]
+		s.RadioQueue = append(s.RadioQueue, &call)
+	}
+	for i := range snap.DepartureQueue {
+		dep := snap.DepartureQueue[i]
+		s.DepartureQueue = append(s.DepartureQueue, &dep)
+	}
+	for _, tr := range snap.Trajectories {
+		s.Trajectories[tr.Callsign] = tr
+	}
+	for frequency, ch := range snap.Channels {
+		s.Channels[frequency] = &ch
+	}
+
+	for _, as := range snap.Aircraft {
+		if as.Aircraft == nil {
+			return nil, fmt.Errorf("snapshot contains an empty aircraft")
+		}
+		ac := as.Aircraft
+		if err := s.rewireAircraft(ac, as.LandingAirportID, as.LandingRunwayName); err != nil {
+			return nil, err
+		}
+		s.Aircrafts[ac.ID] = ac
+	}
+	return s, nil
+}
+
+// WriteSnapshot saves the complete simulation to w.
+func (s *Simulation) WriteSnapshot(w io.Writer) error {
+	snap, err := s.Snapshot()
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(snap)
+}
+
+// ReadSnapshot restores a simulation saved with WriteSnapshot.
+func ReadSnapshot(r io.Reader) (*Simulation, error) {
+	var snap Snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("invalid snapshot: %w", err)
+	}
+	return RestoreSimulation(&snap)
+}
+
+// rewireAircraft points a restored aircraft back at this simulation's
+// airspace, runway and radio.
+func (s *Simulation) rewireAircraft(ac *aircraft.Aircraft, landingAirportID, landingRunwayName string) error {
+	ac.Airspace = s.Airspace
+	ac.AddRadioMessageFunc = s.AddRadioMessage
+
+	if ac.FlightPlan == nil {
+		ac.FlightPlan = &flightplan.FlightPlan{Callsign: ac.ID}
+	} else if len(ac.FlightPlan.Route) > 0 {
+		if err := ac.FlightPlan.Validate(s.Airspace); err != nil {
+			return fmt.Errorf("%s has an invalid flight plan: %w", ac.ID, err)
+		}
+	}
+
+	if landingAirportID != "" {
+		airport, ok := s.Airspace.Airports[landingAirportID]
+		if !ok {
+			return fmt.Errorf("%s lands at unknown airport %s", ac.ID, landingAirportID)
+		}
+		runway, ok := airport.Runways[landingRunwayName]
+		if !ok {
+			return fmt.Errorf("%s lands on unknown runway %s at %s", ac.ID, landingRunwayName, landingAirportID)
+		}
+		ac.LandingRunway = runway
+	}
+
+	// Direct-to targets that are airspace waypoints share the airspace's copy,
+	// runway thresholds stay standalone.
+	if ac.DirectToWaypoint != nil {
+		if wp, ok := s.Airspace.Waypoints[ac.DirectToWaypoint.Name]; ok && wp.Position == ac.DirectToWaypoint.Position {
+			ac.DirectToWaypoint = wp
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package simulation
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// advance runs the simulation for n ticks.
+func advance(s *Simulation, n int) {
+	for range n {
+		s.Update(1 / s.TickRate)
+	}
+}
+
+func writeSnapshot(t *testing.T, s *Simulation) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := s.WriteSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	s := NewSimulation(30, 1024, 768, 7)
+	s.StaffPosition("BLR_N_CTR")
+	advance(s, 30*120)
+
+	saved := writeSnapshot(t, s)
+	restored, err := ReadSnapshot(bytes.NewReader(saved))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored.Aircrafts) == 0 {
+		t.Fatal("no traffic after two minutes")
+	}
+	if again := writeSnapshot(t, restored); !bytes.Equal(saved, again) {
+		t.Error("restored simulation saves a different snapshot")
+	}
+
+	// Restored from the same state, with the same random numbers to come,
+	// both run on identically.
+	advance(s, 30*60)
+	advance(restored, 30*60)
+	if !bytes.Equal(writeSnapshot(t, s), writeSnapshot(t, restored)) {
+		t.Error("restored simulation diverged")
+	}
+}
+
+func TestReadSnapshotErrors(t *testing.T) {
+	saved := string(writeSnapshot(t, NewSimulation(30, 1024, 768, 1)))
+	version := fmt.Sprintf(`"Version": %d,`, snapshotVersion)
+	edit := func(old, new string) string {
+		if !strings.Contains(saved, old) {
+			t.Fatalf("snapshot has no %s", old)
+		}
+		return strings.Replace(saved, old, new, 1)
+	}
+	tests := []struct {
+		name     string
+		snapshot string
+	}{
+		{"not JSON", "{"},
+		{"old version", edit(version, `"Version": 0,`)},
+		{"no airspace", edit(`"Airspace": {`, `"Airspace": null, "Unused": {`)},
+		{"bad RNG state", edit(`"RNGState": "`, `"RNGState": "AAAA`)},
+	}
+	for _, tt := range tests {
+		if _, err := ReadSnapshot(strings.NewReader(tt.snapshot)); err == nil {
+			t.Errorf("%s: snapshot accepted", tt.name)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package simulation
+
+import (
+	"atc-simulator/internal/game/aircraft"
+	"atc-simulator/pkg/types"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"time"
+)
+
+const (
+	callsignAttempts = 100
+	maxFlowRate      = 600.0 // aircraft an hour from one fix or airport
+	maxProfileFactor = 10.0
+	maxSpawnsPerTick = 10 // from one flow
+)
+
+// Flow is a stream of traffic entering the airspace at a fix, or departing
+// an airport, at random times but on average Rate aircraft an hour.
+type Flow struct {
+	From string  // entry fix for arrivals, airport ID for departures
+	Rate float64 // aircraft an hour, before the time of day profile
+}
+
+// FleetType is an aircraft type in the fleet mix.
+type FleetType struct {
+	Type   string  // ICAO type designator, e.g. "A320"
+	Wake   string  // L, M, H or J
+	Speed  float64 // knots, as it enters the airspace
+	Weight float64 // share of the traffic, relative to the other types
+}
+
+// Airline is a callsign pool: the airline's flights are numbered at random
+// from First to Last.
+type Airline struct {
+	Prefix      string // e.g. "AAL"
+	First, Last int
+	Weight      float64 // share of the traffic, relative to the other airlines
+}
+
+// TrafficConfig is what the traffic generator spawns. Every flow is a Poisson
+// process, so aircraft come at random and now and then bunch up, the way
+// real traffic does.
+type TrafficConfig struct {
+	Arrivals     []Flow  // by entry fix
+	Departures   []Flow  // by airport
+	LandingShare float64 // of arrivals land, the rest overfly to an exit fix
+	Fleet        []FleetType
+	Airlines     []Airline
+
+	// Profile scales every rate by the local hour of day of the simulation:
+	// Profile[7] applies from 07:00 to 07:59. Empty is 1 all day.
+	Profile []float64 `json:",omitempty"`
+
+	// MaxTraffic caps the aircraft in the air and at holding points; an
+	// aircraft due beyond it does not come. 0 is no cap.
+	MaxTraffic int `json:",omitempty"`
+}
+
+// DefaultTraffic is the traffic of a Kempegowda day: a morning bank, a quiet
+// afternoon, an evening peak and little at night.
+func DefaultTraffic() TrafficConfig {
+	return TrafficConfig{
+		Arrivals: []Flow{
+			{From: "APIPO", Rate: 6},
+			{From: "BISKET", Rate: 5},
+			{From: "EMETI", Rate: 6},
+			{From: "FILKA", Rate: 5},
+		},
+		Departures:   []Flow{{From: "KBLR", Rate: 10}},
+		LandingShare: 0.8,
+		Fleet: []FleetType{
+			{Type: "A320", Wake: "M", Speed: 250, Weight: 40},
+			{Type: "B738", Wake: "M", Speed: 260, Weight: 30},
+			{Type: "AT76", Wake: "M", Speed: 210, Weight: 8},
+			{Type: "C208", Wake: "L", Speed: 170, Weight: 3},
+			{Type: "B788", Wake: "H", Speed: 280, Weight: 10},
+			{Type: "B77W", Wake: "H", Speed: 290, Weight: 7},
+			{Type: "A388", Wake: "J", Speed: 290, Weight: 2},
+		},
+		Airlines: []Airline{
+			{Prefix: "AAL", First: 100, Last: 999, Weight: 1},
+			{Prefix: "SWA", First: 100, Last: 999, Weight: 1},
+			{Prefix: "DAL", First: 100, Last: 999, Weight: 1},
+			{Prefix: "UAL", First: 100, Last: 999, Weight: 1},
+			{Prefix: "JBU", First: 100, Last: 999, Weight: 1},
+			{Prefix: "ASA", First: 100, Last: 999, Weight: 1},
+			{Prefix: "FFT", First: 100, Last: 999, Weight: 1},
+			{Prefix: "AI", First: 100, Last: 999, Weight: 1},
+			{Prefix: "JAL", First: 100, Last: 999, Weight: 1},
+		},
+		Profile: []float64{
+			0.3, 0.2, 0.2, 0.2, 0.3, 0.6, // night
+			1.2, 1.8, 1.6, 1.2, 1.0, 1.0, // morning bank
+			0.9, 0.8, 0.8, 0.9, 1.1, 1.5, // afternoon
+			1.7, 1.5, 1.2, 0.9, 0.6, 0.4, // evening peak
+		},
+		MaxTraffic: 20,
+	}
+}
+
+// ReadTrafficConfig reads a traffic configuration written as JSON.
+func ReadTrafficConfig(r io.Reader) (TrafficConfig, error) {
+	var cfg TrafficConfig
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return TrafficConfig{}, fmt.Errorf("invalid traffic configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// wakeCategory parses a wake category letter.
+func wakeCategory(letter string) (aircraft.WakeCategory, bool) {
+	for wake, l := range aircraft.WakeCategoryStringMap {
+		if l == letter {
+			return wake, true
+		}
+	}
+	return aircraft.WakeMedium, false
+}
+
+// validate checks the configuration against the airspace: arrivals come in
+// by its entry fixes and depart from its airports.
+func (cfg *TrafficConfig) validate(s *Simulation) error {
+	for _, f := range cfg.Arrivals {
+		if !slices.Contains(s.Airspace.EntryWaypoints, f.From) {
+			return fmt.Errorf("%s is not an entry fix", f.From)
+		}
+		if !types.Finite(f.Rate) || f.Rate < 0 || f.Rate > maxFlowRate {
+			return fmt.Errorf("invalid rate %.1f from %s, must be between 0 and %.0f an hour", f.Rate, f.From, maxFlowRate)
+		}
+	}
+	for _, f := range cfg.Departures {
+		if _, ok := s.Airspace.Airports[f.From]; !ok {
+			return fmt.Errorf("airport %s not found", f.From)
+		}
+		if !types.Finite(f.Rate) || f.Rate < 0 || f.Rate > maxFlowRate {
+			return fmt.Errorf("invalid rate %.1f from %s, must be between 0 and %.0f an hour", f.Rate, f.From, maxFlowRate)
+		}
+	}
+	if !types.Finite(cfg.LandingShare) || cfg.LandingShare < 0 || cfg.LandingShare > 1 {
+		return fmt.Errorf("invalid landing share %.2f, must be between 0 and 1", cfg.LandingShare)
+	}
+
+	fleetWeight := 0.0
+	for _, ft := range cfg.Fleet {
+		if _, ok := wakeCategory(ft.Wake); !ok {
+			return fmt.Errorf("invalid wake category %q of %s, must be L, M, H or J", ft.Wake, ft.Type)
+		}
+		if !types.Finite(ft.Speed) || !types.Finite(ft.Weight) || ft.Speed <= 0 || ft.Weight < 0 {
+			return fmt.Errorf("invalid speed or weight of %s", ft.Type)
+		}
+		fleetWeight += ft.Weight
+	}
+	if fleetWeight <= 0 {
+		return fmt.Errorf("fleet mix is empty")
+	}
+
+	airlineWeight := 0.0
+	for _, al := range cfg.Airlines {
+		if al.Prefix == "" || al.First < 0 || al.Last < al.First || !types.Finite(al.Weight) || al.Weight < 0 {
+			return fmt.Errorf("invalid callsign pool %q %d-%d", al.Prefix, al.First, al.Last)
+		}
+		airlineWeight += al.Weight
+	}
+	if airlineWeight <= 0 {
+		return fmt.Errorf("no airlines to draw callsigns from")
+	}
+
+	if len(cfg.Profile) != 0 && len(cfg.Profile) != 24 {
+		return fmt.Errorf("time of day profile has %d hours, must have 24", len(cfg.Profile))
+	}
+	for hour, factor := range cfg.Profile {
+		if !types.Finite(factor) || factor < 0 || factor > maxProfileFactor {
+			return fmt.Errorf("invalid factor %.2f at %02d:00, must be between 0 and %.0f", factor, hour, maxProfileFactor)
+		}
+	}
+	if cfg.MaxTraffic < 0 {
+		return fmt.Errorf("invalid traffic cap %d, must not be negative", cfg.MaxTraffic)
+	}
+	return nil
+}
+
+// factorAt is how busy the profile makes the hour of t.
+func (cfg *TrafficConfig) factorAt(t time.Time) float64 {
+	if len(cfg.Profile) == 0 {
+		return 1
+	}
+	return cfg.Profile[t.Hour()]
+}
+
+// SetTraffic replaces what the traffic generator spawns.
+func (s *Simulation) SetTraffic(cfg TrafficConfig) error {
+	if err := cfg.validate(s); err != nil {
+		return err
+	}
+	s.Traffic = cfg
+	s.flowHazards = make(map[string]float64)
+	log.Printf("TRAFFIC: set to %.0f aircraft an hour at %02d:00", s.FlowRate(), s.TimeOfDay.Hour())
+	return nil
+}
+
+// SetFlowRate changes the rate of the arrivals from an entry fix or the
+// departures from an airport, adding the flow if there is none.
+func (s *Simulation) SetFlowRate(from string, rate float64) error {
+	cfg := s.Traffic
+	cfg.Arrivals, cfg.Departures = slices.Clone(cfg.Arrivals), slices.Clone(cfg.Departures)
+	flows := &cfg.Arrivals
+	if _, ok := s.Airspace.Airports[from]; ok {
+		flows = &cfg.Departures
+	}
+	if i := slices.IndexFunc(*flows, func(f Flow) bool { return f.From == from }); i >= 0 {
+		(*flows)[i].Rate = rate
+	} else {
+		*flows = append(*flows, Flow{From: from, Rate: rate})
+	}
+	return s.SetTraffic(cfg)
+}
+
+// FlowRate is the total of all flows at the current time of day, in aircraft
+// an hour.
+func (s *Simulation) FlowRate() float64 {
+	total := 0.0
+	for _, f := range slices.Concat(s.Traffic.Arrivals, s.Traffic.Departures) {
+		total += f.Rate
+	}
+	return total * s.Traffic.factorAt(s.TimeOfDay)
+}
+
+// generateTraffic spawns the aircraft the flows bring this tick. Each flow
+// draws how much of its rate must pass, in aircraft, before its next aircraft
+// comes from an exponential distribution, so that the time between aircraft
+// stays exponential even as the profile changes the rate.
+func (s *Simulation) generateTraffic(dt float64) {
+	factor := s.Traffic.factorAt(s.TimeOfDay)
+	spawn := func(key string, f Flow, arrival bool) {
+		hazard, ok := s.flowHazards[key]
+		if !ok {
+			hazard = s.rng.ExpFloat64()
+		}
+		hazard -= f.Rate * factor / 3600 * dt
+		for spawned := 0; hazard <= 0; hazard += s.rng.ExpFloat64() {
+			if spawned == maxSpawnsPerTick {
+				// A long tick: drop the backlog rather than flood the airspace.
+				hazard = s.rng.ExpFloat64()
+				break
+			}
+			s.spawnFlow(f, arrival)
+			spawned++
+		}
+		s.flowHazards[key] = hazard
+	}
+	for _, f := range s.Traffic.Arrivals {
+		spawn("ARR "+f.From, f, true)
+	}
+	for _, f := range s.Traffic.Departures {
+		spawn("DEP "+f.From, f, false)
+	}
+}
+
+// spawnFlow spawns the next aircraft of a flow, of a random type and airline,
+// unless traffic is at its cap.
+func (s *Simulation) spawnFlow(f Flow, arrival bool) {
+	acID, ok := s.randomCallsign()
+	if !ok {
+		log.Printf("WARNING: no free callsign for traffic from %s", f.From)
+		return
+	}
+	if traffic := len(s.Aircrafts) + len(s.DepartureQueue); s.Traffic.MaxTraffic > 0 && traffic >= s.Traffic.MaxTraffic {
+		log.Printf("TRAFFIC: %s from %s not spawned, %d aircraft already", acID, f.From, traffic)
+		return
+	}
+	ft := s.randomFleetType()
+	if !arrival {
+		s.spawnDeparture(acID, f.From, ft)
+		return
+	}
+	s.spawnArrival(acID, f.From, s.rng.Float64() < s.Traffic.LandingShare, ft)
+}
+
+// randomFleetType draws a type from the fleet mix.
+func (s *Simulation) randomFleetType() FleetType {
+	weights := make([]float64, len(s.Traffic.Fleet))
+	for i, ft := range s.Traffic.Fleet {
+		weights[i] = ft.Weight
+	}
+	return s.Traffic.Fleet[s.weightedIndex(weights)]
+}
+
+// randomCallsign draws a callsign from an airline's pool that no aircraft in
+// the air or at a holding point has. It gives up after callsignAttempts
+// draws, should the pools be all but used up.
+func (s *Simulation) randomCallsign() (types.AircraftID, bool) {
+	weights := make([]float64, len(s.Traffic.Airlines))
+	for i, al := range s.Traffic.Airlines {
+		weights[i] = al.Weight
+	}
+	for range callsignAttempts {
+		al := s.Traffic.Airlines[s.weightedIndex(weights)]
+		acID := types.AircraftID(fmt.Sprintf("%s%03d", al.Prefix, al.First+s.rng.IntN(al.Last-al.First+1)))
+		if _, flying := s.Aircrafts[acID]; !flying && !slices.ContainsFunc(s.DepartureQueue, func(d *Departure) bool { return d.Callsign == acID }) {
+			return acID, true
+		}
+	}
+	return "", false
+}
+
+// weightedIndex draws an index with probability proportional to its weight.
+func (s *Simulation) weightedIndex(weights []float64) int {
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	r := s.rng.Float64() * total
+	for i, w := range weights {
+		if r < w {
+			return i
+		}
+		r -= w
+	}
+	return len(weights) - 1
+}
@@ -0,0 +1,58 @@
+package simulation
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSetTrafficValidates(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(cfg *TrafficConfig)
+		ok   bool
+	}{
+		{"default", func(cfg *TrafficConfig) {}, true},
+		{"busiest flow", func(cfg *TrafficConfig) { cfg.Arrivals[0].Rate = maxFlowRate }, true},
+		{"unknown entry fix", func(cfg *TrafficConfig) { cfg.Arrivals[0].From = "NOWHERE" }, false},
+		{"unknown airport", func(cfg *TrafficConfig) { cfg.Departures[0].From = "NOWHERE" }, false},
+		{"negative rate", func(cfg *TrafficConfig) { cfg.Arrivals[0].Rate = -1 }, false},
+		{"rate too high", func(cfg *TrafficConfig) { cfg.Departures[0].Rate = maxFlowRate + 1 }, false},
+		{"NaN rate", func(cfg *TrafficConfig) { cfg.Arrivals[0].Rate = math.NaN() }, false},
+		{"infinite rate", func(cfg *TrafficConfig) { cfg.Departures[0].Rate = math.Inf(1) }, false},
+		{"NaN landing share", func(cfg *TrafficConfig) { cfg.LandingShare = math.NaN() }, false},
+		{"landing share over 1", func(cfg *TrafficConfig) { cfg.LandingShare = 1.5 }, false},
+		{"NaN fleet weight", func(cfg *TrafficConfig) { cfg.Fleet[0].Weight = math.NaN() }, false},
+		{"infinite fleet speed", func(cfg *TrafficConfig) { cfg.Fleet[0].Speed = math.Inf(1) }, false},
+		{"unknown wake", func(cfg *TrafficConfig) { cfg.Fleet[0].Wake = "X" }, false},
+		{"NaN airline weight", func(cfg *TrafficConfig) { cfg.Airlines[0].Weight = math.NaN() }, false},
+		{"short profile", func(cfg *TrafficConfig) { cfg.Profile = cfg.Profile[:23] }, false},
+		{"NaN factor", func(cfg *TrafficConfig) { cfg.Profile[3] = math.NaN() }, false},
+		{"factor too high", func(cfg *TrafficConfig) { cfg.Profile[3] = maxProfileFactor + 1 }, false},
+	}
+	for _, tt := range tests {
+		s := newTestSimulation(t)
+		cfg := DefaultTraffic()
+		tt.edit(&cfg)
+		if err := s.SetTraffic(cfg); (err == nil) != tt.ok {
+			t.Errorf("%s: error %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestGenerateTrafficCapsLongTicks(t *testing.T) {
+	s := newTestSimulation(t)
+	cfg := DefaultTraffic()
+	cfg.Arrivals = []Flow{{From: "APIPO", Rate: maxFlowRate}}
+	cfg.Departures = nil
+	cfg.Profile = nil
+	cfg.MaxTraffic = 0
+	if err := s.SetTraffic(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	before := len(s.Aircrafts)
+	s.generateTraffic(3600) // an hour in one tick brings some 600 aircraft
+	if spawned := len(s.Aircrafts) - before; spawned == 0 || spawned > maxSpawnsPerTick {
+		t.Errorf("spawned %d aircraft, want 1 to %d", spawned, maxSpawnsPerTick)
+	}
+}
@@ -0,0 +1,124 @@
+package trajectory
+
+import (
+	"atc-simulator/internal/game/aircraft"
+	"atc-simulator/internal/game/flightplan"
+	"atc-simulator/pkg/types"
+)
+
+const (
+	stepSeconds   = 1.0  // the aircraft is flown ahead in steps this long
+	sampleSeconds = 10.0 // points are kept this often between fixes
+)
+
+// Point is where an aircraft is predicted to be at a time, in game seconds.
+// Fix names the fix, or the runway, passed there.
+type Point struct {
+	Time     float64
+	Position types.Vec2
+	Altitude float64
+	Speed    float64
+	Fix      string `json:",omitempty"`
+}
+
+// Trajectory is the predicted 4D path of an aircraft, starting where it is.
+type Trajectory struct {
+	Callsign types.AircraftID
+	Points   []Point
+
+	// TopOfDescent is where the aircraft starts to descend, or nil if it
+	// does not, or already is.
+	TopOfDescent *Point `json:",omitempty"`
+}
+
+// Predict flies a ghost of the aircraft ahead from now, in game seconds, for
+// horizon seconds: along its flight plan or heading, turning, climbing,
+// descending and changing speed the way the aircraft itself will. Wind moves
+// it by wind pixels a second. The prediction ends once the aircraft lands.
+func Predict(ac *aircraft.Aircraft, now, horizon float64, wind types.Vec2) *Trajectory {
+	ghost := ac.Ghost()
+	t := &Trajectory{Callsign: ac.ID}
+	t.Points = append(t.Points, point(ghost, now, ""))
+
+	lastSample, descending := now, ghost.ClimbRate < 0
+	for elapsed := stepSeconds; elapsed <= horizon; elapsed += stepSeconds {
+		index, passed := progress(ghost)
+		ghost.Update(stepSeconds)
+		if ghost.State != aircraft.LANDED {
+			ghost.Position.X += wind.X * stepSeconds
+			ghost.Position.Y += wind.Y * stepSeconds
+		}
+		time := now + elapsed
+
+		if next, _ := progress(ghost); next == index {
+			passed = ""
+		}
+		if ghost.ClimbRate < 0 && !descending && t.TopOfDescent == nil {
+			tod := point(ghost, time, "")
+			t.TopOfDescent = &tod
+		}
+		descending = ghost.ClimbRate < 0
+
+		if passed != "" || time-lastSample >= sampleSeconds || ghost.State == aircraft.LANDED {
+			t.Points = append(t.Points, point(ghost, time, passed))
+			lastSample = time
+		}
+		if ghost.State == aircraft.LANDED {
+			break
+		}
+	}
+	return t
+}
+
+// progress returns the index of the segment the aircraft flies and what it
+// passes on leaving it: the fix, or the runway of an approach.
+func progress(ac *aircraft.Aircraft) (int, string) {
+	seg := ac.FlightPlan.CurrentSegment()
+	switch {
+	case seg == nil:
+		return -1, ""
+	case seg.Type == flightplan.SegmentTypeLanding:
+		return ac.FlightPlan.CurrentSegmentIndex, seg.RunwayName
+	}
+	return ac.FlightPlan.CurrentSegmentIndex, seg.WaypointName
+}
+
+func point(ac *aircraft.Aircraft, time float64, fix string) Point {
+	return Point{Time: time, Position: ac.Position, Altitude: ac.Altitude, Speed: ac.Speed, Fix: fix}
+}
+
+// ETA returns the game time the aircraft passes fix, if it does within the
+// trajectory.
+func (t *Trajectory) ETA(fix string) (float64, bool) {
+	for _, p := range t.Points {
+		if p.Fix == fix {
+			return p.Time, true
+		}
+	}
+	return 0, false
+}
+
+// At returns where the aircraft is predicted to be at a game time, between
+// the first and last point of the trajectory.
+func (t *Trajectory) At(time float64) (Point, bool) {
+	if len(t.Points) == 0 || time < t.Points[0].Time || time > t.Points[len(t.Points)-1].Time {
+		return Point{}, false
+	}
+	for i := 1; i < len(t.Points); i++ {
+		a, b := t.Points[i-1], t.Points[i]
+		if time > b.Time {
+			continue
+		}
+		f := 0.0
+		if b.Time > a.Time {
+			f = (time - a.Time) / (b.Time - a.Time)
+		}
+		return Point{
+			Time:     time,
+			Position: types.NewVec2(a.Position.X+(b.Position.X-a.Position.X)*f, a.Position.Y+(b.Position.Y-a.Position.Y)*f),
+			Altitude: a.Altitude + (b.Altitude-a.Altitude)*f,
+			Speed:    a.Speed + (b.Speed-a.Speed)*f,
+		}, true
+	}
+	return t.Points[0], true
+}
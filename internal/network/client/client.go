@@ -0,0 +1,279 @@
+package client
+
+import (
+	"atc-simulator/internal/game/airspace"
+	"atc-simulator/internal/game/simulation"
+	"atc-simulator/internal/game/trajectory"
+	"atc-simulator/internal/network/protocol"
+	"atc-simulator/pkg/types"
+	"fmt"
+	"log"
+	"net"
+	"slices"
+	"sync"
+)
+
+const (
+	maxCommandLogSize = 20
+	resultQueueSize   = 16
+)
+
+// State is the client's mirror of the server simulation.
+type State struct {
+	Name            string
+	Role            protocol.Role
+	Positions       []string
+	Controllers     map[string]string
+	Airspace        *airspace.Airspace
+	Aircrafts       map[types.AircraftID]*protocol.AircraftView
+	Trajectories    map[types.AircraftID]*trajectory.Trajectory
+	RadioLog        []simulation.RadioMessage
+	HandoffOffers   []simulation.HandoffOffer
+	PointOuts       []simulation.PointOut
+	Requests        []simulation.PilotRequest
+	Arrivals        []simulation.ArrivalSequence
+	Departures      []simulation.Departure
+	StaffedSectors  []string
+	Stats           protocol.Stats
+	GameTimeSeconds float64
+	Paused          bool
+	Weather         simulation.Weather
+	CommandLog      []protocol.CommandLogEntry
+	Replay          *protocol.ReplayStatus // nil unless watching a recording
+}
+
+func (st *State) IsObserver() bool {
+	return st.Role == protocol.RoleSpectator || st.Role == protocol.RoleInstructor
+}
+
+func (st *State) IsSectorStaffed(sectorName string) bool {
+	return slices.Contains(st.StaffedSectors, sectorName)
+}
+
+// OwnsSector reports whether the sector belongs to one of this client's positions.
+func (st *State) OwnsSector(sectorName string) bool {
+	sec, ok := st.Airspace.Sectors[sectorName]
+	return ok && slices.Contains(st.Positions, sec.Controller)
+}
+
+// Arrival returns the place of an aircraft in its landing sequence, and the
+// runway it lands on, if it has one.
+func (st *State) Arrival(aircraftID types.AircraftID) (simulation.Arrival, string, bool) {
+	for _, seq := range st.Arrivals {
+		for _, a := range seq.Arrivals {
+			if a.Callsign == aircraftID {
+				return a, seq.Runway, true
+			}
+		}
+	}
+	return simulation.Arrival{}, "", false
+}
+
+func (st *State) HandoffOffer(aircraftID types.AircraftID) (simulation.HandoffOffer, bool) {
+	for _, offer := range st.HandoffOffers {
+		if offer.Callsign == aircraftID {
+			return offer, true
+		}
+	}
+	return simulation.HandoffOffer{}, false
+}
+
+func (st *State) apply(delta *protocol.Delta) {
+	st.GameTimeSeconds = delta.GameTimeSeconds
+	st.Paused = delta.Paused
+	st.Weather = delta.Weather
+	st.HandoffOffers = delta.HandoffOffers
+	st.PointOuts = delta.PointOuts
+	st.Requests = delta.Requests
+	st.Arrivals = delta.Arrivals
+	st.Departures = delta.Departures
+	st.StaffedSectors = delta.StaffedSectors
+	st.Controllers = delta.Controllers
+	st.Stats = delta.Stats
+	st.Replay = delta.Replay
+
+	for _, view := range delta.Updated {
+		st.Aircrafts[view.ID] = &view
+	}
+	for _, id := range delta.Removed {
+		delete(st.Aircrafts, id)
+		delete(st.Trajectories, id)
+	}
+	for _, tr := range delta.Trajectories {
+		st.Trajectories[tr.Callsign] = tr
+	}
+
+	if delta.ResetRadio {
+		st.RadioLog = nil
+	}
+	lastID := 0
+	if len(st.RadioLog) > 0 {
+		lastID = st.RadioLog[len(st.RadioLog)-1].ID
+	}
+	for _, msg := range delta.Radio {
+		if msg.ID > lastID {
+			st.RadioLog = append(st.RadioLog, msg)
+		}
+	}
+
+	st.CommandLog = append(st.CommandLog, delta.Commands...)
+	if len(st.CommandLog) > maxCommandLogSize {
+		st.CommandLog = st.CommandLog[len(st.CommandLog)-maxCommandLogSize:]
+	}
+}
+
+// Client is a connection to a simulation server.
+type Client struct {
+	conn    *protocol.Conn
+	results chan protocol.CommandResult
+	probes  chan protocol.ProbeResult
+
+	mu    sync.Mutex
+	state *State
+	err   error
+}
+
+// Dial connects to the server at addr, introducing itself with hello, and
+// waits for the initial state.
+func Dial(addr string, hello protocol.Hello) (*Client, error) {
+	nc, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		conn:    protocol.NewConn(nc),
+		results: make(chan protocol.CommandResult, resultQueueSize),
+		probes:  make(chan protocol.ProbeResult, resultQueueSize),
+		state: &State{
+			Aircrafts:    make(map[types.AircraftID]*protocol.AircraftView),
+			Trajectories: make(map[types.AircraftID]*trajectory.Trajectory),
+		},
+	}
+
+	if err := c.conn.Send(protocol.MsgHello, hello); err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+
+	env, err := c.conn.Receive()
+	if err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+	if env.Type == protocol.MsgReject {
+		c.conn.Close()
+		var reject protocol.Reject
+		if err := env.Decode(&reject); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("server refused connection: %s", reject.Reason)
+	}
+	if env.Type != protocol.MsgWelcome {
+		c.conn.Close()
+		return nil, fmt.Errorf("expected %s from server, got %s", protocol.MsgWelcome, env.Type)
+	}
+
+	var welcome protocol.Welcome
+	if err := env.Decode(&welcome); err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+	c.state.Name = welcome.Name
+	c.state.Role = welcome.Role
+	c.state.Positions = welcome.Positions
+	c.state.Airspace = welcome.Airspace
+	c.state.apply(&welcome.Snapshot)
+
+	go c.receiveLoop()
+	return c, nil
+}
+
+func (c *Client) receiveLoop() {
+	for {
+		env, err := c.conn.Receive()
+		if err != nil {
+			c.mu.Lock()
+			c.err = err
+			c.mu.Unlock()
+			return
+		}
+
+		switch env.Type {
+		case protocol.MsgDelta:
+			var delta protocol.Delta
+			if err := env.Decode(&delta); err != nil {
+				log.Printf("CLIENT: bad delta: %v", err)
+				continue
+			}
+			c.mu.Lock()
+			c.state.apply(&delta)
+			c.mu.Unlock()
+		case protocol.MsgCommandResult:
+			var result protocol.CommandResult
+			if err := env.Decode(&result); err != nil {
+				log.Printf("CLIENT: bad command result: %v", err)
+				continue
+			}
+			if result.Error != "" {
+				log.Printf("Command %q failed: %s", result.Text, result.Error)
+			}
+			select {
+			case c.results <- result:
+			default:
+				log.Printf("CLIENT: dropping result of %q, nobody is reading results", result.Text)
+			}
+		case protocol.MsgProbeResult:
+			var result protocol.ProbeResult
+			if err := env.Decode(&result); err != nil {
+				log.Printf("CLIENT: bad probe result: %v", err)
+				continue
+			}
+			select {
+			case c.probes <- result:
+			default:
+				log.Printf("CLIENT: dropping probe of %q, nobody is reading probes", result.Text)
+			}
+		default:
+			log.Printf("CLIENT: unexpected %s message", env.Type)
+		}
+	}
+}
+
+// Results delivers the server's answer to every command sent.
+func (c *Client) Results() <-chan protocol.CommandResult {
+	return c.results
+}
+
+// Probes delivers the server's answer to every probe sent.
+func (c *Client) Probes() <-chan protocol.ProbeResult {
+	return c.probes
+}
+
+// View calls fn with the current state. fn must not keep references to it.
+func (c *Client) View(fn func(st *State)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fn(c.state)
+}
+
+func (c *Client) SendCommand(selected types.AircraftID, text string) error {
+	return c.conn.Send(protocol.MsgCommand, protocol.CommandRequest{Selected: selected, Text: text})
+}
+
+// SendProbe asks the server what the command would lead to if it were issued
+// now, without issuing it.
+func (c *Client) SendProbe(selected types.AircraftID, text string) error {
+	return c.conn.Send(protocol.MsgProbe, protocol.CommandRequest{Selected: selected, Text: text})
+}
+
+// Err returns the error that ended the connection, if any.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,55 @@
+package protocol
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"sync"
+)
+
+// Conn exchanges Envelopes as newline delimited JSON over a stream connection.
+type Conn struct {
+	conn net.Conn
+	dec  *json.Decoder
+
+	writeMu sync.Mutex
+	enc     *json.Encoder
+}
+
+func NewConn(conn net.Conn) *Conn {
+	return &Conn{
+		conn: conn,
+		dec:  json.NewDecoder(bufio.NewReader(conn)),
+		enc:  json.NewEncoder(conn),
+	}
+}
+
+func (c *Conn) Send(t MessageType, payload any) error {
+	env, err := NewEnvelope(t, payload)
+	if err != nil {
+		return err
+	}
+	return c.WriteEnvelope(env)
+}
+
+func (c *Conn) WriteEnvelope(env *Envelope) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.enc.Encode(env)
+}
+
+func (c *Conn) Receive() (*Envelope, error) {
+	var env Envelope
+	if err := c.dec.Decode(&env); err != nil {
+		return nil, err
+	}
+	return &env, nil
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
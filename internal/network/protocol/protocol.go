@@ -0,0 +1,224 @@
+package protocol
+
+import (
+	"atc-simulator/internal/game/aircraft"
+	"atc-simulator/internal/game/airspace"
+	"atc-simulator/internal/game/flightplan"
+	"atc-simulator/internal/game/simulation"
+	"atc-simulator/internal/game/trajectory"
+	"atc-simulator/pkg/types"
+	"encoding/json"
+	"strings"
+)
+
+type MessageType string
+
+const (
+	MsgHello         MessageType = "hello"          // client -> server
+	MsgWelcome       MessageType = "welcome"        // server -> client
+	MsgReject        MessageType = "reject"         // server -> client
+	MsgDelta         MessageType = "delta"          // server -> client
+	MsgCommand       MessageType = "command"        // client -> server
+	MsgCommandResult MessageType = "command_result" // server -> client
+	MsgProbe         MessageType = "probe"          // client -> server
+	MsgProbeResult   MessageType = "probe_result"   // server -> client
+)
+
+// Envelope is a single newline delimited JSON message on the wire.
+type Envelope struct {
+	Type    MessageType     `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+func NewEnvelope(t MessageType, payload any) (*Envelope, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{Type: t, Payload: data}, nil
+}
+
+func (e *Envelope) Decode(v any) error {
+	return json.Unmarshal(e.Payload, v)
+}
+
+type Role string
+
+const (
+	RoleController Role = "controller"
+	RoleSpectator  Role = "spectator"  // read-only, sees all traffic and every controller's input
+	RoleInstructor Role = "instructor" // spectator that may also run instructor commands
+)
+
+type Hello struct {
+	Name      string   `json:"name"`
+	Role      Role     `json:"role,omitempty"` // defaults to RoleController
+	Positions []string `json:"positions"`
+}
+
+// Reject is sent instead of Welcome when the server refuses a client, for
+// example because a requested position is already staffed.
+type Reject struct {
+	Reason string `json:"reason"`
+}
+
+// Welcome carries the static airspace and the full state at the moment of joining.
+type Welcome struct {
+	Name      string             `json:"name"`
+	Role      Role               `json:"role"`
+	Positions []string           `json:"positions"`
+	Airspace  *airspace.Airspace `json:"airspace"`
+	Snapshot  Delta              `json:"snapshot"`
+}
+
+type Stats struct {
+	Traffic         int `json:"traffic"`
+	HandOffs        int `json:"handoffs"`
+	MissedHandoffs  int `json:"missed_handoffs"`
+	Conflicts       int `json:"conflicts"`
+	Landings        int `json:"landings"`
+	SectorTransfers int `json:"sector_transfers"`
+	ReadbacksCaught int `json:"readbacks_caught"`
+	ReadbacksMissed int `json:"readbacks_missed"`
+
+	FlowRate float64 `json:"flow_rate"` // aircraft an hour the traffic generator brings now
+
+	Departures        int     `json:"departures"`      // taken off
+	DepartureQueue    int     `json:"departure_queue"` // waiting at holding points
+	DepartureDelay    float64 `json:"departure_delay"` // mean wait, seconds
+	MaxDepartureDelay float64 `json:"max_departure_delay"`
+
+	PilotSatisfaction float64 `json:"pilot_satisfaction"` // 0 to 100
+}
+
+// Delta describes what changed since the previous broadcast. Aircraft in
+// Updated replace the client's copy entirely. Radio only carries messages on
+// the frequencies of the receiving client's sectors, and Commands is only
+// sent to spectators and instructors.
+type Delta struct {
+	GameTimeSeconds float64                      `json:"game_time"`
+	Paused          bool                         `json:"paused"`
+	Weather         simulation.Weather           `json:"weather"`
+	Updated         []AircraftView               `json:"updated,omitempty"`
+	Removed         []types.AircraftID           `json:"removed,omitempty"`
+	Trajectories    []*trajectory.Trajectory     `json:"trajectories,omitempty"` // predicted anew since the last delta
+	Radio           []simulation.RadioMessage    `json:"radio,omitempty"`
+	HandoffOffers   []simulation.HandoffOffer    `json:"handoff_offers"`
+	PointOuts       []simulation.PointOut        `json:"point_outs"`
+	Requests        []simulation.PilotRequest    `json:"requests"` // pending pilot requests
+	Arrivals        []simulation.ArrivalSequence `json:"arrivals,omitempty"`
+	Departures      []simulation.Departure       `json:"departures,omitempty"`
+	StaffedSectors  []string                     `json:"staffed_sectors"`
+	Controllers     map[string]string            `json:"controllers"` // position ID -> controller name
+	Stats           Stats                        `json:"stats"`
+	Commands        []CommandLogEntry            `json:"commands,omitempty"`
+	Replay          *ReplayStatus                `json:"replay,omitempty"`
+	ResetRadio      bool                         `json:"reset_radio,omitempty"` // replace the radio log instead of appending
+}
+
+// ReplayStatus describes playback when the server is replaying a recording.
+type ReplayStatus struct {
+	Tick      int     `json:"tick"`
+	StartTick int     `json:"start_tick"`
+	EndTick   int     `json:"end_tick"`
+	TickRate  float64 `json:"tick_rate"`
+	Speed     float64 `json:"speed"`
+	Playing   bool    `json:"playing"`
+}
+
+// CommandLogEntry records a command issued by any controller or instructor.
+type CommandLogEntry struct {
+	GameTimeSeconds float64  `json:"game_time"`
+	Controller      string   `json:"controller"`
+	Role            Role     `json:"role"`
+	Positions       []string `json:"positions,omitempty"`
+	Text            string   `json:"text"`
+	Error           string   `json:"error,omitempty"`
+}
+
+type CommandRequest struct {
+	Selected types.AircraftID `json:"selected,omitempty"`
+	Text     string           `json:"text"`
+}
+
+// IsSessionCommand reports whether a command line is handled by the server
+// itself (SAVE, LOAD) rather than by the simulation.
+func IsSessionCommand(text string) bool {
+	parts := strings.Fields(strings.ToUpper(text))
+	return len(parts) > 0 && (parts[0] == "SAVE" || parts[0] == "LOAD")
+}
+
+type CommandResult struct {
+	Text        string `json:"text"`
+	Error       string `json:"error,omitempty"`
+	ErrorColumn int    `json:"error_column,omitempty"` // 1-based column the error points at, 0 if none
+	Reason      string `json:"reason,omitempty"`       // simulation.RejectReasonStringMap value for rejected instructions
+}
+
+// ProbeResult answers a probe, a CommandRequest tried out without being
+// issued. Probe is nil when the command could not be probed.
+type ProbeResult struct {
+	CommandResult
+	Probe *simulation.Probe `json:"probe,omitempty"`
+}
+
+type AircraftView struct {
+	ID                types.AircraftID       `json:"id"`
+	Position          types.Vec2             `json:"position"`
+	Altitude          float64                `json:"altitude"`
+	Heading           float64                `json:"heading"`
+	Speed             float64                `json:"speed"`
+	ClimbRate         float64                `json:"climb_rate"`
+	TargetAltitude    float64                `json:"target_altitude"`
+	TargetSpeed       float64                `json:"target_speed"`
+	TargetHeading     float64                `json:"target_heading"`
+	DirectTo          *types.Waypoint        `json:"direct_to,omitempty"`
+	State             string                 `json:"state"`
+	Type              string                 `json:"type,omitempty"`
+	Wake              string                 `json:"wake"`
+	IsConflicting     bool                   `json:"is_conflicting"`
+	Emergency         string                 `json:"emergency,omitempty"`
+	ControllingSector string                 `json:"controlling_sector"`
+	Frequency         string                 `json:"frequency"`
+	Satisfaction      float64                `json:"satisfaction"`
+	Via               bool                   `json:"via,omitempty"`
+	Vectored          bool                   `json:"vectored,omitempty"`
+	ConstraintWarning string                 `json:"constraint_warning,omitempty"`
+	FlightPlan        *flightplan.FlightPlan `json:"flight_plan,omitempty"`
+}
+
+func NewAircraftView(ac *aircraft.Aircraft) AircraftView {
+	view := AircraftView{
+		ID:                ac.ID,
+		Position:          ac.Position,
+		Altitude:          ac.Altitude,
+		Heading:           ac.Heading,
+		Speed:             ac.Speed,
+		ClimbRate:         ac.ClimbRate,
+		TargetAltitude:    ac.TargetAltitude,
+		TargetSpeed:       ac.TargetSpeed,
+		TargetHeading:     ac.TargetHeading,
+		State:             aircraft.StateStringMap[ac.State],
+		Type:              ac.Type,
+		Wake:              aircraft.WakeCategoryStringMap[ac.Wake],
+		IsConflicting:     ac.IsConflicting,
+		Emergency:         ac.Emergency,
+		ControllingSector: ac.ControllingSector,
+		Frequency:         ac.Frequency,
+		Satisfaction:      ac.Satisfaction,
+		Via:               ac.Via,
+		Vectored:          ac.Vectored,
+		ConstraintWarning: ac.ConstraintWarning(),
+	}
+
+	if ac.DirectToWaypoint != nil {
+		wp := *ac.DirectToWaypoint
+		view.DirectTo = &wp
+	}
+	if ac.FlightPlan != nil {
+		fp := *ac.FlightPlan
+		fp.Route = append([]flightplan.FlightPlanSegment(nil), ac.FlightPlan.Route...)
+		view.FlightPlan = &fp
+	}
+	return view
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"atc-simulator/internal/game/simulation"
+	"atc-simulator/internal/network/protocol"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const maxReplaySpeed = 32
+
+// NewReplay returns a server that plays a recording back to spectators
+// instead of running a live simulation.
+func NewReplay(replay *simulation.Replay) *Server {
+	s := New(replay.Simulation(), replay.Recording().Header.TickRate)
+	s.replay = replay
+	s.replaySpeed = 1
+	s.replayPlaying = true
+	return s
+}
+
+// advanceReplay moves playback on by one server tick.
+func (s *Server) advanceReplay(broadcastTick bool) {
+	if !s.replayPlaying {
+		return
+	}
+
+	if s.replaySpeed < 0 {
+		// Rewinding restores a keyframe every time, so only do it as often as
+		// clients get to see the result.
+		if broadcastTick {
+			step := int(math.Round(-s.replaySpeed * s.tickRate / broadcastRateHz))
+			s.seekReplay(s.replay.Tick() - step)
+			if s.replay.Tick() <= s.replay.Recording().StartTick() {
+				s.replayPlaying = false
+			}
+		}
+		return
+	}
+
+	s.replayCredit += s.replaySpeed
+	for ; s.replayCredit >= 1; s.replayCredit-- {
+		if !s.replay.Step() {
+			s.replayPlaying = false
+			s.replayCredit = 0
+			log.Printf("SERVER: replay finished at tick %d", s.replay.Tick())
+			break
+		}
+	}
+	s.sim = s.replay.Simulation()
+}
+
+func (s *Server) seekReplay(tick int) error {
+	if err := s.replay.Seek(tick); err != nil {
+		return err
+	}
+	s.sim = s.replay.Simulation()
+	s.lastRadioID = 0
+	s.resetRadio = true
+	return nil
+}
+
+// executeReplayCommand applies a playback control:
+//
+//	PLAY | PAUSE
+//	SPEED <factor>   negative factors rewind
+//	SEEK <seconds>   from the start of the recording
+//	FF <seconds> | REW <seconds>
+func (s *Server) executeReplayCommand(text string) error {
+	parts := strings.Fields(strings.ToUpper(text))
+	if len(parts) < 1 {
+		return fmt.Errorf("empty replay command")
+	}
+	seconds := func() (float64, error) {
+		if len(parts) < 2 {
+			return 0, fmt.Errorf("usage: %s <seconds>", parts[0])
+		}
+		v, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid seconds %s", parts[1])
+		}
+		return v, nil
+	}
+	ticks := func(seconds float64) int {
+		return int(math.Round(seconds * s.tickRate))
+	}
+
+	switch parts[0] {
+	case "PLAY":
+		if s.replaySpeed < 0 {
+			s.replaySpeed = 1
+		}
+		s.replayPlaying = true
+	case "PAUSE":
+		s.replayPlaying = false
+	case "SPEED":
+		if len(parts) < 2 {
+			return fmt.Errorf("usage: SPEED <factor>")
+		}
+		speed, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || speed == 0 || math.Abs(speed) > maxReplaySpeed {
+			return fmt.Errorf("invalid speed %s, must be non-zero and at most %d either way", parts[1], maxReplaySpeed)
+		}
+		s.replaySpeed = speed
+		s.replayPlaying = true
+	case "SEEK":
+		v, err := seconds()
+		if err != nil {
+			return err
+		}
+		return s.seekReplay(s.replay.Recording().StartTick() + ticks(v))
+	case "FF":
+		v, err := seconds()
+		if err != nil {
+			return err
+		}
+		return s.seekReplay(s.replay.Tick() + ticks(v))
+	case "REW":
+		v, err := seconds()
+		if err != nil {
+			return err
+		}
+		return s.seekReplay(s.replay.Tick() - ticks(v))
+	default:
+		return fmt.Errorf("unknown replay command: %s", parts[0])
+	}
+	return nil
+}
+
+func (s *Server) replayStatus() *protocol.ReplayStatus {
+	rec := s.replay.Recording()
+	return &protocol.ReplayStatus{
+		Tick:      s.replay.Tick(),
+		StartTick: rec.StartTick(),
+		EndTick:   rec.EndTick(),
+		TickRate:  s.tickRate,
+		Speed:     s.replaySpeed,
+		Playing:   s.replayPlaying,
+	}
+}
@@ -0,0 +1,570 @@
+package server
+
+import (
+	"atc-simulator/internal/game/command"
+	"atc-simulator/internal/game/simulation"
+	"atc-simulator/internal/game/trajectory"
+	"atc-simulator/internal/network/protocol"
+	"atc-simulator/pkg/types"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"slices"
+	"time"
+)
+
+const (
+	clientQueueSize   = 256
+	broadcastRateHz   = 10
+	commandLogBacklog = 100
+)
+
+// Server runs the authoritative simulation and streams its state to clients.
+// The simulation is only ever touched from the run loop goroutine.
+type Server struct {
+	sim      *simulation.Simulation
+	tickRate float64
+
+	// SnapshotDir is where SAVE and LOAD keep snapshots.
+	SnapshotDir string
+
+	join     chan *client
+	leave    chan *client
+	commands chan queuedCommand
+	shutdown chan chan struct{}
+
+	clients     map[*client]bool
+	lastViews   map[types.AircraftID][]byte
+	lastTracks  map[types.AircraftID]*trajectory.Trajectory
+	lastRadioID int
+	ticks       int
+
+	commandLog      []protocol.CommandLogEntry
+	pendingCommands []protocol.CommandLogEntry
+
+	replay        *simulation.Replay
+	replaySpeed   float64
+	replayPlaying bool
+	replayCredit  float64
+	resetRadio    bool
+}
+
+type client struct {
+	conn        *protocol.Conn
+	out         chan *protocol.Envelope
+	joined      chan bool     // answers the join, false if the client was rejected
+	written     chan struct{} // closed once out is drained and the connection closed
+	name        string
+	role        protocol.Role
+	positions   []string
+	frequencies []string
+	dropped     bool // too slow and closed, waiting for its reader to leave
+}
+
+// isObserver reports whether the client sees everything: all frequencies and
+// every controller's input.
+func (c *client) isObserver() bool {
+	return c.role == protocol.RoleSpectator || c.role == protocol.RoleInstructor
+}
+
+type queuedCommand struct {
+	client  *client
+	request protocol.CommandRequest
+	probe   bool // only try the command out, see probe
+}
+
+func New(sim *simulation.Simulation, tickRate float64) *Server {
+	return &Server{
+		sim:      sim,
+		tickRate: tickRate,
+
+		SnapshotDir: "snapshots",
+
+		join:     make(chan *client),
+		leave:    make(chan *client),
+		commands: make(chan queuedCommand),
+		shutdown: make(chan chan struct{}),
+
+		clients:    make(map[*client]bool),
+		lastViews:  make(map[types.AircraftID][]byte),
+		lastTracks: make(map[types.AircraftID]*trajectory.Trajectory),
+	}
+}
+
+// ListenAndServe accepts clients on a TCP address. There is no WebSocket
+// transport.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve starts the simulation and accepts clients on l until it fails.
+func (s *Server) Serve(l net.Listener) error {
+	log.Printf("SERVER: listening on %s", l.Addr())
+	go s.run()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(nc net.Conn) {
+	conn := protocol.NewConn(nc)
+	defer conn.Close()
+
+	env, err := conn.Receive()
+	if err != nil || env.Type != protocol.MsgHello {
+		log.Printf("SERVER: %s did not say hello, dropping", nc.RemoteAddr())
+		return
+	}
+	var hello protocol.Hello
+	if err := env.Decode(&hello); err != nil {
+		log.Printf("SERVER: bad hello from %s: %v", nc.RemoteAddr(), err)
+		return
+	}
+
+	c := &client{
+		conn:      conn,
+		out:       make(chan *protocol.Envelope, clientQueueSize),
+		joined:    make(chan bool, 1),
+		written:   make(chan struct{}),
+		name:      hello.Name,
+		role:      hello.Role,
+		positions: hello.Positions,
+	}
+	if c.role == "" {
+		c.role = protocol.RoleController
+	}
+	if c.name == "" {
+		c.name = nc.RemoteAddr().String()
+	}
+	go c.writeLoop()
+	s.join <- c
+	if !<-c.joined {
+		// Stop reading, so that nothing more reaches the run loop, but let
+		// the reject go out first.
+		<-c.written
+		return
+	}
+
+	for {
+		env, err := conn.Receive()
+		if err != nil {
+			break
+		}
+
+		switch env.Type {
+		case protocol.MsgCommand:
+			var req protocol.CommandRequest
+			if err := env.Decode(&req); err != nil {
+				log.Printf("SERVER: bad command from %s: %v", nc.RemoteAddr(), err)
+				continue
+			}
+			s.commands <- queuedCommand{client: c, request: req}
+		case protocol.MsgProbe:
+			var req protocol.CommandRequest
+			if err := env.Decode(&req); err != nil {
+				log.Printf("SERVER: bad probe from %s: %v", nc.RemoteAddr(), err)
+				continue
+			}
+			s.commands <- queuedCommand{client: c, request: req, probe: true}
+		default:
+			log.Printf("SERVER: unexpected %s message from %s", env.Type, nc.RemoteAddr())
+		}
+	}
+	s.leave <- c
+}
+
+// writeLoop drains the outgoing queue until it is closed, then closes the
+// connection so the reader side notices.
+func (c *client) writeLoop() {
+	defer close(c.written)
+	defer c.conn.Close()
+	for env := range c.out {
+		if err := c.conn.WriteEnvelope(env); err != nil {
+			c.conn.Close()
+		}
+	}
+}
+
+func (c *client) send(t protocol.MessageType, payload any) {
+	env, err := protocol.NewEnvelope(t, payload)
+	if err != nil {
+		log.Printf("SERVER: failed to encode %s: %v", t, err)
+		return
+	}
+	c.sendEnvelope(env)
+}
+
+// sendEnvelope queues a message without ever blocking the run loop. Clients
+// that cannot keep up are disconnected once and sent nothing more until their
+// reader notices and they leave. Only the run loop may call it.
+func (c *client) sendEnvelope(env *protocol.Envelope) {
+	if c.dropped {
+		return
+	}
+	select {
+	case c.out <- env:
+	default:
+		log.Printf("SERVER: %s is too slow, disconnecting", c.conn.RemoteAddr())
+		c.dropped = true
+		c.conn.Close()
+	}
+}
+
+func (s *Server) run() {
+	dt := 1.0 / s.tickRate
+	broadcastEvery := max(1, int(s.tickRate/broadcastRateHz))
+
+	ticker := time.NewTicker(time.Duration(dt * float64(time.Second)))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.ticks++
+			if s.replay != nil {
+				s.advanceReplay(s.ticks%broadcastEvery == 0)
+			} else {
+				s.sim.Update(dt)
+			}
+			if s.ticks%broadcastEvery == 0 {
+				s.broadcast()
+			}
+		case c := <-s.join:
+			s.addClient(c)
+		case c := <-s.leave:
+			s.removeClient(c)
+		case cmd := <-s.commands:
+			if cmd.probe {
+				s.probe(cmd)
+			} else {
+				s.execute(cmd)
+			}
+		case done := <-s.shutdown:
+			if err := s.sim.StopRecording(); err != nil {
+				log.Printf("SERVER: failed to finish recording: %v", err)
+			}
+			close(done)
+			return
+		}
+	}
+}
+
+// Shutdown stops the simulation and finishes any recording in progress. The
+// server must be serving.
+func (s *Server) Shutdown() {
+	done := make(chan struct{})
+	s.shutdown <- done
+	<-done
+}
+
+func (s *Server) execute(cmd queuedCommand) {
+	c := cmd.client
+	if !s.clients[c] {
+		return // rejected or gone, and its queue closed
+	}
+	result := protocol.CommandResult{Text: cmd.request.Text}
+
+	var err error
+	switch {
+	case protocol.IsSessionCommand(cmd.request.Text):
+		err = s.executeSessionCommand(c, cmd.request.Text)
+	case s.replay != nil:
+		err = s.executeReplayCommand(cmd.request.Text)
+	case c.role == protocol.RoleController:
+		err = s.sim.ExecuteCommand(c.positions, cmd.request.Selected, cmd.request.Text)
+	case c.role == protocol.RoleInstructor:
+		err = s.sim.ExecuteInstructorCommand(cmd.request.Text)
+	default:
+		err = fmt.Errorf("%s clients cannot issue commands", c.role)
+	}
+	setError(&result, err)
+	c.send(protocol.MsgCommandResult, result)
+
+	if c.role == protocol.RoleSpectator || s.replay != nil {
+		return
+	}
+	entry := protocol.CommandLogEntry{
+		GameTimeSeconds: s.sim.GameTimeSeconds,
+		Controller:      c.name,
+		Role:            c.role,
+		Positions:       c.positions,
+		Text:            cmd.request.Text,
+		Error:           result.Error,
+	}
+	s.pendingCommands = append(s.pendingCommands, entry)
+	s.commandLog = append(s.commandLog, entry)
+	if len(s.commandLog) > commandLogBacklog {
+		s.commandLog = s.commandLog[len(s.commandLog)-commandLogBacklog:]
+	}
+}
+
+// setError fills in why a command failed, if it did.
+func setError(result *protocol.CommandResult, err error) {
+	if err == nil {
+		return
+	}
+	result.Error = err.Error()
+	var cmdErr *command.Error
+	if errors.As(err, &cmdErr) {
+		result.ErrorColumn = cmdErr.Pos + 1
+	}
+	if reason, ok := simulation.RejectReasonOf(err); ok {
+		result.Reason = simulation.RejectReasonStringMap[reason]
+	}
+}
+
+// probe answers a controller trying a command out. Nothing changes, so the
+// probe is neither logged nor recorded.
+func (s *Server) probe(cmd queuedCommand) {
+	c := cmd.client
+	result := protocol.ProbeResult{CommandResult: protocol.CommandResult{Text: cmd.request.Text}}
+
+	var err error
+	switch {
+	case s.replay != nil:
+		err = fmt.Errorf("cannot probe while replaying")
+	case c.role != protocol.RoleController:
+		err = fmt.Errorf("%s clients cannot probe commands", c.role)
+	default:
+		result.Probe, err = s.sim.ProbeCommand(c.positions, cmd.request.Selected, cmd.request.Text)
+	}
+	setError(&result.CommandResult, err)
+	c.send(protocol.MsgProbeResult, result)
+}
+
+func (s *Server) addClient(c *client) {
+	if s.replay != nil {
+		// Everybody watches a replay.
+		c.role = protocol.RoleSpectator
+	}
+	if err := s.claimPositions(c); err != nil {
+		log.Printf("SERVER: rejecting %s: %v", c.name, err)
+		c.send(protocol.MsgReject, protocol.Reject{Reason: err.Error()})
+		close(c.out)
+		c.joined <- false
+		return
+	}
+
+	for _, positionID := range c.positions {
+		s.sim.StaffPosition(positionID)
+	}
+	for _, name := range s.sim.Airspace.SectorNames() {
+		if sec := s.sim.Airspace.Sectors[name]; s.sim.SectorOwnedBy(name, c.positions) {
+			c.frequencies = append(c.frequencies, sec.Frequency)
+		}
+	}
+	s.clients[c] = true
+	c.joined <- true
+	log.Printf("SERVER: %s joined as %s %v", c.name, c.role, c.positions)
+
+	snapshot := s.stateDelta()
+	for _, ac := range s.sim.Aircrafts {
+		snapshot.Updated = append(snapshot.Updated, protocol.NewAircraftView(ac))
+	}
+	for _, tr := range s.sim.Trajectories {
+		snapshot.Trajectories = append(snapshot.Trajectories, tr)
+	}
+	snapshot.Radio = c.filterRadio(s.sim.RadioLog)
+	if c.isObserver() {
+		snapshot.Commands = s.commandLog
+	}
+
+	c.send(protocol.MsgWelcome, protocol.Welcome{
+		Name:      c.name,
+		Role:      c.role,
+		Positions: c.positions,
+		Airspace:  s.sim.Airspace,
+		Snapshot:  snapshot,
+	})
+}
+
+// claimPositions checks that every position c asks for exists and is not
+// already worked by another client. Observers never hold positions.
+func (s *Server) claimPositions(c *client) error {
+	switch c.role {
+	case protocol.RoleSpectator, protocol.RoleInstructor:
+		c.positions = nil
+		return nil
+	case protocol.RoleController:
+	default:
+		return fmt.Errorf("unknown role %s", c.role)
+	}
+
+	if len(c.positions) == 0 {
+		return fmt.Errorf("no controller position requested")
+	}
+	for _, positionID := range c.positions {
+		if _, ok := s.sim.Airspace.Positions[positionID]; !ok {
+			return fmt.Errorf("controller position %s not found", positionID)
+		}
+		if holder := s.positionHolder(positionID); holder != nil {
+			return fmt.Errorf("controller position %s is already staffed by %s", positionID, holder.name)
+		}
+	}
+	return nil
+}
+
+func (s *Server) removeClient(c *client) {
+	if !s.clients[c] {
+		return
+	}
+	delete(s.clients, c)
+	close(c.out)
+
+	for _, positionID := range c.positions {
+		s.sim.UnstaffPosition(positionID)
+	}
+	log.Printf("SERVER: %s left, %v handed back to the simulation", c.name, c.positions)
+}
+
+func (s *Server) positionHolder(positionID string) *client {
+	for c := range s.clients {
+		if slices.Contains(c.positions, positionID) {
+			return c
+		}
+	}
+	return nil
+}
+
+// filterRadio returns the messages the client can hear. Messages without a
+// frequency are heard by everyone, observers hear every frequency.
+func (c *client) filterRadio(messages []simulation.RadioMessage) []simulation.RadioMessage {
+	if c.isObserver() {
+		return messages
+	}
+
+	heard := []simulation.RadioMessage{}
+	for _, msg := range messages {
+		if msg.Frequency == "" || slices.Contains(c.frequencies, msg.Frequency) {
+			heard = append(heard, msg)
+		}
+	}
+	return heard
+}
+
+// stateDelta returns a Delta holding everything except aircraft and radio changes.
+func (s *Server) stateDelta() protocol.Delta {
+	caught, missed, _ := s.sim.HearbackScore()
+	meanDelay, maxDelay := s.sim.DepartureDelay()
+	delta := protocol.Delta{
+		GameTimeSeconds: s.sim.GameTimeSeconds,
+		Paused:          s.sim.Paused,
+		Weather:         s.sim.Weather,
+		HandoffOffers:   []simulation.HandoffOffer{},
+		PointOuts:       []simulation.PointOut{},
+		Requests:        []simulation.PilotRequest{},
+		StaffedSectors:  []string{},
+		Controllers:     make(map[string]string),
+		Arrivals:        s.sim.ArrivalSequences,
+		Stats: protocol.Stats{
+			Traffic:         len(s.sim.Aircrafts),
+			HandOffs:        s.sim.HandOffs,
+			MissedHandoffs:  s.sim.MissedHandoffs,
+			Conflicts:       s.sim.Conflicts,
+			Landings:        s.sim.Landings,
+			SectorTransfers: s.sim.SectorTransfers,
+			ReadbacksCaught: caught,
+			ReadbacksMissed: missed,
+
+			FlowRate: s.sim.FlowRate(),
+
+			Departures:        s.sim.Departures,
+			DepartureQueue:    len(s.sim.DepartureQueue),
+			DepartureDelay:    meanDelay,
+			MaxDepartureDelay: maxDelay,
+
+			PilotSatisfaction: s.sim.PilotSatisfaction(),
+		},
+	}
+
+	for _, dep := range s.sim.DepartureQueue {
+		delta.Departures = append(delta.Departures, *dep)
+	}
+	for _, offer := range s.sim.HandoffOffers {
+		delta.HandoffOffers = append(delta.HandoffOffers, *offer)
+	}
+	for _, po := range s.sim.PointOuts {
+		delta.PointOuts = append(delta.PointOuts, *po)
+	}
+	for _, req := range s.sim.PilotRequests {
+		if req.State == simulation.RequestPending {
+			delta.Requests = append(delta.Requests, *req)
+		}
+	}
+	for _, name := range s.sim.Airspace.SectorNames() {
+		if s.sim.IsSectorStaffed(name) {
+			delta.StaffedSectors = append(delta.StaffedSectors, name)
+		}
+	}
+	for c := range s.clients {
+		for _, positionID := range c.positions {
+			delta.Controllers[positionID] = c.name
+		}
+	}
+	if s.replay != nil {
+		delta.Replay = s.replayStatus()
+	}
+	return delta
+}
+
+func (s *Server) broadcast() {
+	delta := s.stateDelta()
+
+	for id, ac := range s.sim.Aircrafts {
+		view := protocol.NewAircraftView(ac)
+		data, err := json.Marshal(view)
+		if err != nil {
+			log.Printf("SERVER: failed to encode %s: %v", id, err)
+			continue
+		}
+		if !bytes.Equal(s.lastViews[id], data) {
+			delta.Updated = append(delta.Updated, view)
+			s.lastViews[id] = data
+		}
+	}
+	for id := range s.lastViews {
+		if _, ok := s.sim.Aircrafts[id]; !ok {
+			delta.Removed = append(delta.Removed, id)
+			delete(s.lastViews, id)
+		}
+	}
+	for id, tr := range s.sim.Trajectories {
+		if s.lastTracks[id] != tr {
+			delta.Trajectories = append(delta.Trajectories, tr)
+			s.lastTracks[id] = tr
+		}
+	}
+	for id := range s.lastTracks {
+		if _, ok := s.sim.Trajectories[id]; !ok {
+			delete(s.lastTracks, id)
+		}
+	}
+
+	radio := s.sim.RadioMessagesSince(s.lastRadioID)
+	if len(radio) > 0 {
+		s.lastRadioID = radio[len(radio)-1].ID
+	}
+	delta.ResetRadio, s.resetRadio = s.resetRadio, false
+
+	for c := range s.clients {
+		delta.Radio = c.filterRadio(radio)
+		delta.Commands = nil
+		if c.isObserver() {
+			delta.Commands = s.pendingCommands
+		}
+		c.send(protocol.MsgDelta, delta)
+	}
+	s.pendingCommands = nil
+}
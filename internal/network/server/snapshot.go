@@ -0,0 +1,104 @@
+package server
+
+import (
+	"atc-simulator/internal/game/simulation"
+	"atc-simulator/internal/network/protocol"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var snapshotNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// executeSessionCommand saves or loads a snapshot in the snapshot directory:
+//
+//	SAVE <name>   controllers, instructors, and anybody watching a replay
+//	LOAD <name>   instructors only
+func (s *Server) executeSessionCommand(c *client, text string) error {
+	parts := strings.Fields(text)
+	verb := strings.ToUpper(parts[0])
+	if len(parts) != 2 || !snapshotNamePattern.MatchString(parts[1]) {
+		return fmt.Errorf("usage: %s <name>, names may only use letters, digits, - and _", verb)
+	}
+	path := filepath.Join(s.SnapshotDir, parts[1]+".json")
+
+	switch verb {
+	case "SAVE":
+		if c.role == protocol.RoleSpectator && s.replay == nil {
+			return fmt.Errorf("spectators cannot save snapshots")
+		}
+		return s.saveSnapshot(path)
+	default:
+		if c.role != protocol.RoleInstructor {
+			return fmt.Errorf("only instructors can load snapshots")
+		}
+		if s.replay != nil {
+			return fmt.Errorf("cannot load a snapshot while replaying")
+		}
+		if s.sim.IsRecording() {
+			return fmt.Errorf("cannot load a snapshot while recording")
+		}
+		sim, err := LoadSnapshotFile(path)
+		if err != nil {
+			return err
+		}
+		s.replaceSimulation(sim)
+		log.Printf("SERVER: %s loaded snapshot %s at tick %d", c.name, path, sim.Ticks)
+		return nil
+	}
+}
+
+func (s *Server) saveSnapshot(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := s.sim.WriteSnapshot(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	log.Printf("SERVER: saved snapshot %s at tick %d", path, s.sim.Ticks)
+	return nil
+}
+
+// LoadSnapshotFile restores a simulation saved with SAVE or WriteSnapshot.
+// Positions are handed back to the simulation until controllers join.
+func LoadSnapshotFile(path string) (*simulation.Simulation, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sim, err := simulation.ReadSnapshot(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for positionID := range sim.Airspace.Positions {
+		sim.UnstaffPosition(positionID)
+	}
+	return sim, nil
+}
+
+// replaceSimulation swaps in a loaded simulation, staffed by whoever is
+// connected now.
+func (s *Server) replaceSimulation(sim *simulation.Simulation) {
+	for c := range s.clients {
+		for _, positionID := range c.positions {
+			sim.StaffPosition(positionID)
+		}
+	}
+
+	s.sim = sim
+	s.lastRadioID = 0
+	s.resetRadio = true
+}
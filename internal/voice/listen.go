@@ -0,0 +1,172 @@
+package voice
+
+import (
+	"atc-simulator/internal/game/phraseology"
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultRecorder records 16 kHz mono WAV from the default microphone with
+// ALSA. The file to write is appended to the command.
+const DefaultRecorder = "arecord -q -f S16_LE -r 16000 -c 1 -t wav"
+
+// Recognizer transcribes a WAV recording, constrained by a GBNF grammar.
+type Recognizer interface {
+	Transcribe(wavPath, grammar string) (string, error)
+}
+
+// Whisper runs whisper.cpp's command line tool, which works without a network
+// connection once a model has been downloaded.
+type Whisper struct {
+	Command string
+	Model   string
+}
+
+// NewWhisper returns the recogniser for command and model, or an error if
+// either is missing.
+func NewWhisper(command, model string) (*Whisper, error) {
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return nil, fmt.Errorf("speech recogniser %s not found: %w", command, err)
+	}
+	if _, err := os.Stat(model); err != nil {
+		return nil, fmt.Errorf("speech model: %w", err)
+	}
+	return &Whisper{Command: path, Model: model}, nil
+}
+
+func (w *Whisper) Transcribe(wavPath, grammar string) (string, error) {
+	grammarPath := wavPath + ".gbnf"
+	if err := os.WriteFile(grammarPath, []byte(grammar), 0o644); err != nil {
+		return "", err
+	}
+
+	cmd := exec.Command(w.Command,
+		"-m", w.Model,
+		"-f", wavPath,
+		"-l", "en",
+		"-nt", "-np",
+		"--grammar", grammarPath,
+		"--grammar-rule", "root")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w: %s", w.Command, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.Join(strings.Fields(string(out)), " "), nil
+}
+
+// Heard is what the controller said on one push of the talk button.
+type Heard struct {
+	Speech  string
+	Command string // command line, empty if Err is set
+	Err     error
+}
+
+// Listener records the controller while push-to-talk is held and turns what
+// was said into a command line.
+type Listener struct {
+	record     []string
+	recognizer Recognizer
+	dir        string
+
+	recording *exec.Cmd
+	file      string
+	takes     int
+	heard     chan Heard
+}
+
+// NewListener records with the record command line, see DefaultRecorder.
+func NewListener(record string, recognizer Recognizer) (*Listener, error) {
+	args := strings.Fields(record)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no recorder")
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return nil, fmt.Errorf("recorder %s not found: %w", args[0], err)
+	}
+	dir, err := os.MkdirTemp("", "atc-voice-")
+	if err != nil {
+		return nil, err
+	}
+	return &Listener{
+		record:     args,
+		recognizer: recognizer,
+		dir:        dir,
+		heard:      make(chan Heard, 4),
+	}, nil
+}
+
+// Recording reports whether the talk button is held.
+func (l *Listener) Recording() bool {
+	return l.recording != nil
+}
+
+// Start begins recording.
+func (l *Listener) Start() error {
+	if l.recording != nil {
+		return nil
+	}
+	l.takes++
+	l.file = filepath.Join(l.dir, fmt.Sprintf("take%d.wav", l.takes))
+	cmd := exec.Command(l.record[0], append(l.record[1:], l.file)...)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	l.recording = cmd
+	return nil
+}
+
+// Stop ends recording and recognises it against the vocabulary. It returns
+// at once, so as not to hold up the game loop: the recorder is waited for and
+// the recording recognised in the background, and the outcome arrives on
+// Heard.
+func (l *Listener) Stop(v phraseology.Vocabulary) {
+	cmd, file := l.recording, l.file
+	if cmd == nil {
+		return
+	}
+	l.recording = nil
+
+	go func() {
+		// An interrupt lets the recorder finish the file; not every
+		// platform can send one.
+		if err := cmd.Process.Signal(os.Interrupt); err != nil {
+			cmd.Process.Kill()
+		}
+		cmd.Wait()
+
+		defer os.Remove(file)
+		defer os.Remove(file + ".gbnf")
+
+		speech, err := l.recognizer.Transcribe(file, phraseology.Grammar(v))
+		if err != nil {
+			log.Printf("VOICE: %v", err)
+			l.heard <- Heard{Err: err}
+			return
+		}
+		line, err := phraseology.Recognize(speech, v)
+		log.Printf("VOICE: heard %q as %q", speech, line)
+		l.heard <- Heard{Speech: speech, Command: line, Err: err}
+	}()
+}
+
+// Heard delivers what was recognised.
+func (l *Listener) Heard() <-chan Heard {
+	return l.heard
+}
+
+// Close stops any recording and removes the recordings.
+func (l *Listener) Close() {
+	if l.recording != nil {
+		l.recording.Process.Kill()
+		l.recording.Wait()
+	}
+	os.RemoveAll(l.dir)
+}
@@ -0,0 +1,85 @@
+package voice
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand/v2"
+)
+
+// The audio is 16 bit little endian stereo.
+const (
+	SampleRate     = 44100
+	bytesPerSample = 4
+)
+
+// VHF radio sound: a narrow voice band, an overdriven transmitter, a hiss
+// under the voice and a burst of noise as the squelch closes.
+const (
+	lowCutHz       = 300
+	highCutHz      = 3000
+	drive          = 2.0
+	staticLevel    = 0.04
+	squelchSeconds = 0.12
+	squelchLevel   = 0.3
+)
+
+// Two stations keying up together: their carriers beat against each other.
+const (
+	heterodyneHz      = 1000
+	heterodyneBeatHz  = 80
+	heterodyneSeconds = 0.8
+	heterodyneLevel   = 0.25
+)
+
+func sample(pcm []byte, i int) float64 {
+	return float64(int16(binary.LittleEndian.Uint16(pcm[i*bytesPerSample:]))) / 32768
+}
+
+func appendSample(pcm []byte, x float64) []byte {
+	v := int16(math.Round(max(-1, min(1, x)) * 32767))
+	pcm = binary.LittleEndian.AppendUint16(pcm, uint16(v))
+	return binary.LittleEndian.AppendUint16(pcm, uint16(v))
+}
+
+// radioEffect makes clean speech sound like it came over the radio. Only the
+// left channel is used; speech engines speak in mono.
+func radioEffect(pcm []byte) []byte {
+	dt := 1.0 / SampleRate
+	hpRC := 1 / (2 * math.Pi * lowCutHz)
+	lpRC := 1 / (2 * math.Pi * highCutHz)
+	hpA := hpRC / (hpRC + dt)
+	lpA := dt / (lpRC + dt)
+
+	n := len(pcm) / bytesPerSample
+	tail := int(squelchSeconds * SampleRate)
+	out := make([]byte, 0, (n+tail)*bytesPerSample)
+
+	var prev, hp, lp float64
+	for i := 0; i < n; i++ {
+		x := sample(pcm, i)
+		hp = hpA * (hp + x - prev)
+		prev = x
+		lp += lpA * (hp - lp)
+		y := math.Tanh(drive*lp) / math.Tanh(drive)
+		y += staticLevel * (2*rand.Float64() - 1)
+		out = appendSample(out, y)
+	}
+	for i := 0; i < tail; i++ {
+		fade := 1 - float64(i)/float64(tail)
+		out = appendSample(out, squelchLevel*fade*(2*rand.Float64()-1))
+	}
+	return out
+}
+
+// heterodyne is the squeal heard instead of a blocked transmission.
+func heterodyne() []byte {
+	n := int(heterodyneSeconds * SampleRate)
+	out := make([]byte, 0, n*bytesPerSample)
+	for i := 0; i < n; i++ {
+		t := float64(i) / SampleRate
+		x := math.Sin(2*math.Pi*heterodyneHz*t) + math.Sin(2*math.Pi*(heterodyneHz+heterodyneBeatHz)*t)
+		x = heterodyneLevel*x/2 + staticLevel*(2*rand.Float64()-1)
+		out = appendSample(out, x)
+	}
+	return out
+}
@@ -0,0 +1,105 @@
+package voice
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2/audio"
+	"github.com/hajimehoshi/ebiten/v2/audio/wav"
+)
+
+// queueSize bounds how far speech may fall behind the radio log. Anything
+// beyond it is not spoken.
+const queueSize = 16
+
+type utterance struct {
+	callsign string
+	text     string
+	blocked  bool
+}
+
+// Speaker plays transmissions one after another, like a loudspeaker on the
+// frequency.
+type Speaker struct {
+	context *audio.Context
+	engine  Engine
+	static  bool
+	queue   chan utterance
+	done    chan struct{}
+}
+
+// NewSpeaker starts a speaker speaking with engine, through the radio effect
+// if static is set. Ebiten allows one audio context per process.
+func NewSpeaker(engine Engine, static bool) *Speaker {
+	sp := &Speaker{
+		context: audio.NewContext(SampleRate),
+		engine:  engine,
+		static:  static,
+		queue:   make(chan utterance, queueSize),
+		done:    make(chan struct{}),
+	}
+	go sp.run()
+	return sp
+}
+
+// Say queues a transmission by callsign, "ATC" for the controller. A blocked
+// transmission is heard as a squeal.
+func (sp *Speaker) Say(callsign, text string, blocked bool) {
+	select {
+	case sp.queue <- utterance{callsign: callsign, text: text, blocked: blocked}:
+	default:
+		log.Printf("VOICE: falling behind, not speaking %s: %s", callsign, text)
+	}
+}
+
+// Close stops speaking once the transmission being played has finished.
+func (sp *Speaker) Close() {
+	close(sp.queue)
+	<-sp.done
+}
+
+func (sp *Speaker) run() {
+	defer close(sp.done)
+	for u := range sp.queue {
+		pcm, err := sp.render(u)
+		if err != nil {
+			log.Printf("VOICE: %v", err)
+			continue
+		}
+		sp.play(pcm)
+	}
+}
+
+func (sp *Speaker) render(u utterance) ([]byte, error) {
+	if u.blocked {
+		return heterodyne(), nil
+	}
+
+	out, err := sp.engine.Synthesize(Spoken(u.text), VoiceFor(u.callsign))
+	if err != nil {
+		return nil, err
+	}
+	stream, err := wav.DecodeWithSampleRate(SampleRate, bytes.NewReader(out))
+	if err != nil {
+		return nil, err
+	}
+	pcm, err := io.ReadAll(stream)
+	if err != nil {
+		return nil, err
+	}
+	if sp.static {
+		pcm = radioEffect(pcm)
+	}
+	return pcm, nil
+}
+
+func (sp *Speaker) play(pcm []byte) {
+	p := sp.context.NewPlayerFromBytes(pcm)
+	defer p.Close()
+	p.Play()
+	for p.IsPlaying() {
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,114 @@
+// Package voice is the client's radio audio. It speaks transmissions aloud
+// with an offline text-to-speech engine, each aircraft in a voice of its own,
+// and recognises instructions the controller speaks on push-to-talk.
+package voice
+
+import (
+	"atc-simulator/internal/game/phraseology"
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// Voice is how a station sounds.
+type Voice struct {
+	Name  string // engine voice, e.g. "en-us+m3"
+	Pitch int    // 0-99
+	Rate  int    // words per minute
+}
+
+// ATCVoice is the controller's voice, the same for every position.
+var ATCVoice = Voice{Name: "en-us+m1", Pitch: 40, Rate: 170}
+
+var pilotVoices = []string{
+	"en-us+m2", "en-us+m3", "en-us+m4", "en-us+m5", "en-us+m6", "en-us+m7",
+	"en-us+f1", "en-us+f2", "en-us+f3", "en-us+f4",
+	"en-gb+m3", "en-gb+m4", "en-gb+f2",
+}
+
+// VoiceFor returns the voice of a station. A callsign keeps its voice for the
+// whole session, and across sessions.
+func VoiceFor(callsign string) Voice {
+	if callsign == "ATC" {
+		return ATCVoice
+	}
+	h := fnv.New32a()
+	h.Write([]byte(callsign))
+	n := h.Sum32()
+	return Voice{
+		Name:  pilotVoices[n%uint32(len(pilotVoices))],
+		Pitch: 30 + int(n/16%40),
+		Rate:  165 + int(n/1024%35),
+	}
+}
+
+// Engine turns text into WAV audio.
+type Engine interface {
+	Synthesize(text string, v Voice) ([]byte, error)
+}
+
+// Espeak runs espeak-ng, or a compatible command, which works without a
+// network connection.
+type Espeak struct {
+	Command string
+}
+
+// NewEspeak returns the engine for command, or an error if it is not
+// installed.
+func NewEspeak(command string) (*Espeak, error) {
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return nil, fmt.Errorf("speech engine %s not found: %w", command, err)
+	}
+	return &Espeak{Command: path}, nil
+}
+
+func (e *Espeak) Synthesize(text string, v Voice) ([]byte, error) {
+	cmd := exec.Command(e.Command, "--stdout",
+		"-v", v.Name,
+		"-p", fmt.Sprint(v.Pitch),
+		"-s", fmt.Sprint(v.Rate),
+		text)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", e.Command, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+var (
+	callsignPattern  = regexp.MustCompile(`^[A-Z]{2,3}[0-9]{1,4}$`)
+	runwayPattern    = regexp.MustCompile(`^RWY[0-9]{2}[LRC]?$`)
+	levelPattern     = regexp.MustCompile(`^FL[0-9]{2,3}$`)
+	frequencyPattern = regexp.MustCompile(`^1[0-9]{2}\.[0-9]{1,3}$`)
+	numberPattern    = regexp.MustCompile(`^[0-9]+$`)
+)
+
+// Spoken rewrites a radio log line the way it is said on the air: callsigns
+// in telephony, runways, levels and numbers digit by digit.
+func Spoken(text string) string {
+	words := strings.Fields(text)
+	for i, word := range words {
+		core := strings.TrimRight(word, ".,;:!?")
+		punct := word[len(core):]
+		switch {
+		case runwayPattern.MatchString(core):
+			core = phraseology.Runway(core)
+		case levelPattern.MatchString(core):
+			core = "flight level " + phraseology.Spell(core)
+		case callsignPattern.MatchString(core):
+			core = phraseology.Callsign(core)
+		case frequencyPattern.MatchString(core):
+			core = phraseology.Frequency(core)
+		case numberPattern.MatchString(core):
+			core = phraseology.Spell(core)
+		}
+		words[i] = core + punct
+	}
+	return strings.Join(words, " ")
+}
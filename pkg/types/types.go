@@ -37,3 +37,8 @@ type Waypoint struct {
 	Name     string
 	Position Vec2
 }
+
+// Finite reports whether v is neither NaN nor infinite.
+func Finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}